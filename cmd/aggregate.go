@@ -0,0 +1,108 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"google.golang.org/grpc"
+
+	tspb "github.com/golang/protobuf/ptypes/timestamp"
+
+	pb "github.com/bartmika/tstorage-server/proto"
+)
+
+var (
+	aggregation string
+	function    string
+)
+
+func init() {
+	// The following are required.
+	aggregateCmd.Flags().StringVarP(&metric, "metric", "m", "", "The metric to filter by")
+	aggregateCmd.MarkFlagRequired("metric")
+	aggregateCmd.Flags().Int64VarP(&start, "start", "s", 0, "The start timestamp to begin our range")
+	aggregateCmd.MarkFlagRequired("start")
+	aggregateCmd.Flags().Int64VarP(&end, "end", "e", 0, "The end timestamp to finish our range")
+	aggregateCmd.MarkFlagRequired("end")
+
+	// The following are optional and will have defaults placed when missing.
+	aggregateCmd.Flags().StringVarP(&aggregation, "aggregation", "a", "avg", "The aggregation function. Options: min, max, avg, sum, count, stddev, p50, p90 or p99")
+	aggregateCmd.Flags().StringVarP(&function, "function", "f", "none", "The counter function to use instead of the aggregation. Options: none, rate, irate, increase or delta")
+	aggregateCmd.Flags().IntVarP(&port, "port", "p", 50051, "The port of our server.")
+	addClientSecurityFlags(aggregateCmd)
+	rootCmd.AddCommand(aggregateCmd)
+}
+
+func doAggregate() {
+	// Defensive code. Make sure the user selected a supported aggregation
+	// and counter function.
+	agg, ok := pb.Aggregation_value["AGGREGATION_"+strings.ToUpper(aggregation)]
+	if !ok || agg == int32(pb.Aggregation_AGGREGATION_UNSPECIFIED) {
+		log.Fatalf("Aggregation must be either one of the following: min, max, avg, sum, count, stddev, p50, p90 or p99.")
+	}
+
+	fn, ok := pb.Function_value["FUNCTION_"+strings.ToUpper(function)]
+	if !ok {
+		log.Fatalf("Function must be either one of the following: none, rate, irate, increase or delta.")
+	}
+
+	// Set up a direct connection to the gRPC server.
+	conn, err := grpc.Dial(
+		fmt.Sprintf(":%v", port),
+		transportSecurity(),
+		callMetadata(),
+		grpc.WithBlock(),
+	)
+	if err != nil {
+		log.Fatalf("did not connect: %v", err)
+	}
+
+	// Set up our protocol buffer interface.
+	client := pb.NewTStorageClient(conn)
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	// Convert the unix timestamp into the protocal buffers timestamp format.
+	sts := &tspb.Timestamp{
+		Seconds: start,
+		Nanos:   0,
+	}
+	ets := &tspb.Timestamp{
+		Seconds: end,
+		Nanos:   0,
+	}
+
+	// Generate our labels.
+	labels := []*pb.Label{}
+	labels = append(labels, &pb.Label{Name: "Source", Value: "Command"})
+
+	// Perform our gRPC request.
+	res, err := client.Aggregate(ctx, &pb.AggregateRequest{
+		Filter:      &pb.Filter{Labels: labels, Metric: metric, Start: sts, End: ets},
+		Aggregation: pb.Aggregation(agg),
+		Function:    pb.Function(fn),
+	})
+	if err != nil {
+		log.Fatalf("could not aggregate: %v", err)
+	}
+
+	// Print out the gRPC response.
+	for _, series := range res.Series {
+		log.Printf("Server Response: %s", series)
+	}
+}
+
+var aggregateCmd = &cobra.Command{
+	Use:   "aggregate",
+	Short: "Aggregate data",
+	Long:  `Connect to the gRPC server and return a single aggregated value for every series matching a selection filter.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		doAggregate()
+	},
+}
@@ -0,0 +1,115 @@
+package cmd
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"log"
+
+	"github.com/spf13/cobra"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+)
+
+var (
+	tlsCertFile     string
+	tlsKeyFile      string
+	tlsCAFile       string
+	tlsClientCAFile string
+	tlsServerName   string
+	token           string
+	tenant          string
+)
+
+// Function will add the flags used to connect to a server with TLS or
+// authentication enabled to the client command.
+func addClientSecurityFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&tlsCAFile, "tls-ca", "", "The CA certificate used to verify the server. Enables TLS when set.")
+	cmd.Flags().StringVar(&tlsCertFile, "tls-cert", "", "The client certificate to present to a server requiring mutual TLS.")
+	cmd.Flags().StringVar(&tlsKeyFile, "tls-key", "", "The private key of the client certificate.")
+	cmd.Flags().StringVar(&tlsServerName, "tls-server-name", "localhost", "The name the server certificate must be valid for.")
+	cmd.Flags().StringVar(&token, "token", "", "The bearer token to authenticate with.")
+	cmd.Flags().StringVar(&tenant, "tenant", "", "The tenant to access on a server with multi-tenancy enabled.")
+}
+
+// Function returns the dial option for the transport security selected by
+// the TLS flags of the client command, which is no security at all unless
+// the `--tls-ca` flag was set.
+func transportSecurity() grpc.DialOption {
+	if tlsCAFile == "" {
+		if tlsCertFile != "" || tlsKeyFile != "" {
+			log.Fatalf("the --tls-ca flag must be set to use a client certificate")
+		}
+		return grpc.WithInsecure()
+	}
+
+	creds, err := clientCredentials()
+	if err != nil {
+		log.Fatalf("failed to load TLS credentials: %v", err)
+	}
+	return grpc.WithTransportCredentials(creds)
+}
+
+// Function will load the CA certificate, and the client certificate if one
+// was provided, into the TLS credentials of our gRPC client.
+func clientCredentials() (credentials.TransportCredentials, error) {
+	pem, err := ioutil.ReadFile(tlsCAFile)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificates found in %v", tlsCAFile)
+	}
+
+	// DEVELOPERS NOTE:
+	// Our commands always connect to the local port, so the name the server
+	// certificate is verified against must be given separately.
+	config := &tls.Config{
+		RootCAs:    pool,
+		ServerName: tlsServerName,
+		MinVersion: tls.VersionTLS12,
+	}
+	if tlsCertFile != "" || tlsKeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(tlsCertFile, tlsKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+	return credentials.NewTLS(config), nil
+}
+
+// callCredentials sends the token and tenant given with the `--token` and
+// `--tenant` flags along with every call made to the server.
+type callCredentials struct {
+	token  string
+	tenant string
+}
+
+// Function returns the dial option sending the token and tenant of the
+// client command along with every call.
+func callMetadata() grpc.DialOption {
+	return grpc.WithPerRPCCredentials(callCredentials{token: token, tenant: tenant})
+}
+
+func (c callCredentials) GetRequestMetadata(ctx context.Context, uri ...string) (map[string]string, error) {
+	md := map[string]string{}
+	if c.token != "" {
+		md["authorization"] = "Bearer " + c.token
+	}
+	if c.tenant != "" {
+		md["x-tenant-id"] = c.tenant
+	}
+	return md, nil
+}
+
+// DEVELOPERS NOTE:
+// Our commands connect to the local port so we allow sending the token
+// without TLS, although the server should use TLS if it is reachable from
+// the network.
+func (c callCredentials) RequireTransportSecurity() bool {
+	return false
+}
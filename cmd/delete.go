@@ -0,0 +1,81 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/spf13/cobra"
+	"google.golang.org/grpc"
+
+	tspb "github.com/golang/protobuf/ptypes/timestamp"
+
+	pb "github.com/bartmika/tstorage-server/proto"
+)
+
+func init() {
+	// The following are required.
+	deleteCmd.Flags().StringVarP(&metric, "metric", "m", "", "The metric to delete")
+	deleteCmd.MarkFlagRequired("metric")
+	deleteCmd.Flags().Int64VarP(&start, "start", "s", 0, "The start timestamp of the range to delete")
+	deleteCmd.MarkFlagRequired("start")
+	deleteCmd.Flags().Int64VarP(&end, "end", "e", 0, "The end timestamp of the range to delete")
+	deleteCmd.MarkFlagRequired("end")
+
+	// The following are optional and will have defaults placed when missing.
+	deleteCmd.Flags().IntVarP(&port, "port", "p", 50051, "The port of our server.")
+	addClientSecurityFlags(deleteCmd)
+	rootCmd.AddCommand(deleteCmd)
+}
+
+func doDelete() {
+	// Set up a direct connection to the gRPC server.
+	conn, err := grpc.Dial(
+		fmt.Sprintf(":%v", port),
+		transportSecurity(),
+		callMetadata(),
+		grpc.WithBlock(),
+	)
+	if err != nil {
+		log.Fatalf("did not connect: %v", err)
+	}
+
+	// Set up our protocol buffer interface.
+	client := pb.NewTStorageClient(conn)
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	// Convert the unix timestamp into the protocal buffers timestamp format.
+	sts := &tspb.Timestamp{
+		Seconds: start,
+		Nanos:   0,
+	}
+	ets := &tspb.Timestamp{
+		Seconds: end,
+		Nanos:   0,
+	}
+
+	// Generate our labels.
+	labels := []*pb.Label{}
+	labels = append(labels, &pb.Label{Name: "Source", Value: "Command"})
+
+	// Perform our gRPC request.
+	res, err := client.Delete(ctx, &pb.Filter{Labels: labels, Metric: metric, Start: sts, End: ets})
+	if err != nil {
+		log.Fatalf("could not delete: %v", err)
+	}
+
+	log.Printf("Successfully deleted data from %v series", res.Series)
+}
+
+var deleteCmd = &cobra.Command{
+	Use:   "delete",
+	Short: "Delete data",
+	Long:  `Connect to the gRPC server and delete the data within a time range of the series matching a selection filter.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		doDelete()
+	},
+}
@@ -8,7 +8,6 @@ import (
 
 	"github.com/spf13/cobra"
 	"google.golang.org/grpc"
-	// "google.golang.org/grpc/credentials"
 
 	tspb "github.com/golang/protobuf/ptypes/timestamp"
 
@@ -26,6 +25,7 @@ func init() {
 
 	// The following are optional and will have defaults placed when missing.
 	insertRowsCmd.Flags().IntVarP(&port, "port", "p", 50051, "The port of our server.")
+	addClientSecurityFlags(insertRowsCmd)
 	rootCmd.AddCommand(insertRowsCmd)
 }
 
@@ -33,7 +33,8 @@ func doInsertRows() {
 	// Set up a direct connection to the gRPC server.
 	conn, err := grpc.Dial(
 		fmt.Sprintf(":%v", port),
-		grpc.WithInsecure(),
+		transportSecurity(),
+		callMetadata(),
 		grpc.WithBlock(),
 	)
 	if err != nil {
@@ -73,11 +74,14 @@ func doInsertRows() {
 		log.Fatalf("%v.Send(%v) = %v", stream, tsd, err)
 	}
 
-	_, err = stream.CloseAndRecv()
+	res, err := stream.CloseAndRecv()
 	if err != nil {
 		log.Fatalf("%v.CloseAndRecv() got error %v, want %v", stream, err, nil)
 	}
-	log.Printf("Successfully inserted")
+	for _, rowErr := range res.Errors {
+		log.Printf("Row #%v was rejected: %v", rowErr.Index, rowErr.Reason)
+	}
+	log.Printf("Successfully inserted %v rows, rejected %v rows", res.Accepted, res.Rejected)
 }
 
 var insertRowsCmd = &cobra.Command{
@@ -0,0 +1,128 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/golang/protobuf/ptypes"
+	"github.com/spf13/cobra"
+	"google.golang.org/grpc"
+
+	tspb "github.com/golang/protobuf/ptypes/timestamp"
+
+	pb "github.com/bartmika/tstorage-server/proto"
+)
+
+var (
+	step time.Duration
+	fill string
+)
+
+func init() {
+	// The following are required.
+	rangeQueryCmd.Flags().StringVarP(&metric, "metric", "m", "", "The metric to filter by")
+	rangeQueryCmd.MarkFlagRequired("metric")
+	rangeQueryCmd.Flags().Int64VarP(&start, "start", "s", 0, "The start timestamp to begin our range")
+	rangeQueryCmd.MarkFlagRequired("start")
+	rangeQueryCmd.Flags().Int64VarP(&end, "end", "e", 0, "The end timestamp to finish our range")
+	rangeQueryCmd.MarkFlagRequired("end")
+	rangeQueryCmd.Flags().DurationVar(&step, "step", 0, "The width of every bucket, for example 5m")
+	rangeQueryCmd.MarkFlagRequired("step")
+
+	// The following are optional and will have defaults placed when missing.
+	rangeQueryCmd.Flags().StringVarP(&aggregation, "aggregation", "a", "avg", "The aggregation function used on every bucket. Options: min, max, avg, sum, count, stddev, p50, p90 or p99")
+	rangeQueryCmd.Flags().StringVar(&fill, "fill", "none", "How to fill empty buckets. Options: none, null, previous or linear")
+	rangeQueryCmd.Flags().StringVarP(&function, "function", "f", "none", "The counter function to use instead of the aggregation. Options: none, rate, irate, increase or delta")
+	rangeQueryCmd.Flags().IntVarP(&port, "port", "p", 50051, "The port of our server.")
+	addClientSecurityFlags(rangeQueryCmd)
+	rootCmd.AddCommand(rangeQueryCmd)
+}
+
+func doRangeQuery() {
+	// Defensive code. Make sure the user selected a supported aggregation,
+	// fill behaviour and counter function.
+	agg, ok := pb.Aggregation_value["AGGREGATION_"+strings.ToUpper(aggregation)]
+	if !ok || agg == int32(pb.Aggregation_AGGREGATION_UNSPECIFIED) {
+		log.Fatalf("Aggregation must be either one of the following: min, max, avg, sum, count, stddev, p50, p90 or p99.")
+	}
+	fil, ok := pb.Fill_value["FILL_"+strings.ToUpper(fill)]
+	if !ok {
+		log.Fatalf("Fill must be either one of the following: none, null, previous or linear.")
+	}
+
+	fn, ok := pb.Function_value["FUNCTION_"+strings.ToUpper(function)]
+	if !ok {
+		log.Fatalf("Function must be either one of the following: none, rate, irate, increase or delta.")
+	}
+
+	// Set up a direct connection to the gRPC server.
+	conn, err := grpc.Dial(
+		fmt.Sprintf(":%v", port),
+		transportSecurity(),
+		callMetadata(),
+		grpc.WithBlock(),
+	)
+	if err != nil {
+		log.Fatalf("did not connect: %v", err)
+	}
+
+	// Set up our protocol buffer interface.
+	client := pb.NewTStorageClient(conn)
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	// Convert the unix timestamp into the protocal buffers timestamp format.
+	sts := &tspb.Timestamp{
+		Seconds: start,
+		Nanos:   0,
+	}
+	ets := &tspb.Timestamp{
+		Seconds: end,
+		Nanos:   0,
+	}
+
+	// Generate our labels.
+	labels := []*pb.Label{}
+	labels = append(labels, &pb.Label{Name: "Source", Value: "Command"})
+
+	// Perform our gRPC request.
+	stream, err := client.RangeQuery(ctx, &pb.RangeQueryRequest{
+		Filter:      &pb.Filter{Labels: labels, Metric: metric, Start: sts, End: ets},
+		Step:        ptypes.DurationProto(step),
+		Aggregation: pb.Aggregation(agg),
+		Fill:        pb.Fill(fil),
+		Function:    pb.Function(fn),
+	})
+	if err != nil {
+		log.Fatalf("could not query: %v", err)
+	}
+
+	// Handle our stream of series from the server.
+	for {
+		series, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			log.Fatalf("error with stream: %v", err)
+		}
+
+		// Print out the gRPC response.
+		log.Printf("Server Response: %s", series)
+	}
+}
+
+var rangeQueryCmd = &cobra.Command{
+	Use:   "range_query",
+	Short: "List data bucketed into fixed steps",
+	Long:  `Connect to the gRPC server and return one aggregated data point per step for every series matching a selection filter.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		doRangeQuery()
+	},
+}
@@ -9,7 +9,6 @@ import (
 
 	"github.com/spf13/cobra"
 	"google.golang.org/grpc"
-	// "google.golang.org/grpc/credentials"
 
 	tspb "github.com/golang/protobuf/ptypes/timestamp"
 
@@ -32,6 +31,7 @@ func init() {
 
 	// The following are optional and will have defaults placed when missing.
 	selectCmd.Flags().IntVarP(&port, "port", "p", 50051, "The port of our server.")
+	addClientSecurityFlags(selectCmd)
 	rootCmd.AddCommand(selectCmd)
 }
 
@@ -39,7 +39,8 @@ func doSelectRow() {
 	// Set up a direct connection to the gRPC server.
 	conn, err := grpc.Dial(
 		fmt.Sprintf(":%v", port),
-		grpc.WithInsecure(),
+		transportSecurity(),
+		callMetadata(),
 		grpc.WithBlock(),
 	)
 	if err != nil {
@@ -0,0 +1,94 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"time"
+
+	"github.com/spf13/cobra"
+	"google.golang.org/grpc"
+
+	tspb "github.com/golang/protobuf/ptypes/timestamp"
+
+	pb "github.com/bartmika/tstorage-server/proto"
+)
+
+func init() {
+	// The following are required.
+	selectSeriesCmd.Flags().StringVarP(&metric, "metric", "m", "", "The metric to filter by")
+	selectSeriesCmd.MarkFlagRequired("metric")
+	selectSeriesCmd.Flags().Int64VarP(&start, "start", "s", 0, "The start timestamp to begin our range")
+	selectSeriesCmd.MarkFlagRequired("start")
+	selectSeriesCmd.Flags().Int64VarP(&end, "end", "e", 0, "The end timestamp to finish our range")
+	selectSeriesCmd.MarkFlagRequired("end")
+
+	// The following are optional and will have defaults placed when missing.
+	selectSeriesCmd.Flags().IntVarP(&port, "port", "p", 50051, "The port of our server.")
+	addClientSecurityFlags(selectSeriesCmd)
+	rootCmd.AddCommand(selectSeriesCmd)
+}
+
+func doSelectSeries() {
+	// Set up a direct connection to the gRPC server.
+	conn, err := grpc.Dial(
+		fmt.Sprintf(":%v", port),
+		transportSecurity(),
+		callMetadata(),
+		grpc.WithBlock(),
+	)
+	if err != nil {
+		log.Fatalf("did not connect: %v", err)
+	}
+
+	// Set up our protocol buffer interface.
+	client := pb.NewTStorageClient(conn)
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	// Convert the unix timestamp into the protocal buffers timestamp format.
+	sts := &tspb.Timestamp{
+		Seconds: start,
+		Nanos:   0,
+	}
+	ets := &tspb.Timestamp{
+		Seconds: end,
+		Nanos:   0,
+	}
+
+	// Generate our labels.
+	labels := []*pb.Label{}
+	labels = append(labels, &pb.Label{Name: "Source", Value: "Command"})
+
+	// Perform our gRPC request.
+	stream, err := client.SelectSeries(ctx, &pb.Filter{Labels: labels, Metric: metric, Start: sts, End: ets})
+	if err != nil {
+		log.Fatalf("could not select: %v", err)
+	}
+
+	// Handle our stream of series from the server.
+	for {
+		series, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			log.Fatalf("error with stream: %v", err)
+		}
+
+		// Print out the gRPC response.
+		log.Printf("Server Response: %s", series)
+	}
+}
+
+var selectSeriesCmd = &cobra.Command{
+	Use:   "select_series",
+	Short: "List data grouped by series",
+	Long:  `Connect to the gRPC server and return list of series, with their metric, labels and data points, based on a selection filter.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		doSelectSeries()
+	},
+}
@@ -19,6 +19,21 @@ var (
 	timestampPrecision       string
 	partitionDurationInHours int
 	writeTimeoutInSeconds    int
+	insertBatchSize          int
+	retention                time.Duration
+	policyFile               string
+	tokenFile                string
+	multiTenant              bool
+	maxOpenTenants           int
+	httpPort                 int
+	gatewayPort              int
+	influxUDPPort            int
+	listenerTenant           string
+	graphitePort             int
+	graphitePicklePort       int
+	graphiteTemplates        []string
+	statsdPort               int
+	statsdFlushInterval      time.Duration
 )
 
 func init() {
@@ -28,6 +43,24 @@ func init() {
 	serveCmd.Flags().StringVarP(&timestampPrecision, "timestampPrecision", "t", "s", "The precision of timestamps to be used by all operations. Options: ")
 	serveCmd.Flags().IntVarP(&partitionDurationInHours, "partitionDurationInHours", "b", 1, "The timestamp range inside partitions.")
 	serveCmd.Flags().IntVarP(&writeTimeoutInSeconds, "writeTimeoutInSeconds", "w", 30, "The timeout to wait when workers are busy (in seconds).")
+	serveCmd.Flags().DurationVarP(&retention, "retention", "r", 336*time.Hour, "How long to keep data before it gets removed from the disk.")
+	serveCmd.Flags().IntVar(&insertBatchSize, "insertBatchSize", 1000, "The number of streamed rows to buffer before writing them to storage.")
+	serveCmd.Flags().StringVar(&tlsCertFile, "tls-cert", "", "The certificate to serve gRPC over TLS with.")
+	serveCmd.Flags().StringVar(&tlsKeyFile, "tls-key", "", "The private key of the TLS certificate.")
+	serveCmd.Flags().StringVar(&tlsClientCAFile, "tls-client-ca", "", "The CA certificate client certificates must be signed by. Enables mutual TLS when set.")
+	serveCmd.Flags().StringVar(&tokenFile, "tokenFile", "", "The location of the JSON file with the bearer tokens clients must authenticate with.")
+	serveCmd.Flags().BoolVar(&multiTenant, "multiTenant", false, "Store the data of every tenant separately inside of the data path.")
+	serveCmd.Flags().IntVar(&maxOpenTenants, "maxOpenTenants", 16, "The number of idle tenants to keep open.")
+	serveCmd.Flags().IntVar(&httpPort, "httpPort", 0, "The port of the HTTP server exposing the Prometheus metrics and the HTTP APIs. Disabled when 0.")
+	serveCmd.Flags().IntVar(&gatewayPort, "gatewayPort", 0, "The port of the JSON gateway to the InsertRow, InsertRows and Select methods. Disabled when 0.")
+	serveCmd.Flags().IntVar(&influxUDPPort, "influxUDPPort", 0, "The port of the UDP listener receiving the InfluxDB line protocol. Disabled when 0.")
+	serveCmd.Flags().IntVar(&graphitePort, "graphitePort", 0, "The port of the TCP and UDP listeners receiving the Graphite plaintext protocol. Disabled when 0.")
+	serveCmd.Flags().IntVar(&graphitePicklePort, "graphitePicklePort", 0, "The port of the TCP listener receiving the Graphite pickle protocol. Disabled when 0.")
+	serveCmd.Flags().StringArrayVar(&graphiteTemplates, "graphiteTemplate", nil, "A template mapping Graphite paths to a metric and labels, such as \"site.*.host.measurement*\". Can be repeated.")
+	serveCmd.Flags().IntVar(&statsdPort, "statsdPort", 0, "The port of the UDP listener receiving the StatsD protocol. Disabled when 0.")
+	serveCmd.Flags().DurationVar(&statsdFlushInterval, "statsdFlushInterval", 10*time.Second, "How often the StatsD metrics are aggregated and written to storage.")
+	serveCmd.Flags().StringVar(&listenerTenant, "listenerTenant", "", "The tenant the data received by the UDP and TCP listeners is written to when multi-tenancy is enabled.")
+	serveCmd.Flags().StringVar(&policyFile, "policyFile", "", "The location of the JSON file with the retention and rollup policies of the metrics.")
 
 	// Make this sub-command part of our application.
 	rootCmd.AddCommand(serveCmd)
@@ -39,7 +72,30 @@ func doServe() {
 	writeTimeout := time.Duration(writeTimeoutInSeconds) * time.Second
 
 	// Setup our server.
-	server := server.New(port, dataPath, timestampPrecision, partitionDuration, writeTimeout)
+	opts := []server.Option{
+		server.WithInsertBatchSize(insertBatchSize),
+		server.WithRetention(retention),
+		server.WithPolicyFile(policyFile),
+		server.WithTLS(tlsCertFile, tlsKeyFile, tlsClientCAFile),
+		server.WithTokenFile(tokenFile),
+		server.WithHTTPPort(httpPort),
+		server.WithGatewayPort(gatewayPort),
+		server.WithInfluxUDPPort(influxUDPPort),
+		server.WithGraphite(graphitePort, graphitePicklePort, graphiteTemplates),
+		server.WithStatsd(statsdPort, statsdFlushInterval),
+		server.WithListenerTenant(listenerTenant),
+	}
+	if multiTenant {
+		opts = append(opts, server.WithMultiTenancy(maxOpenTenants))
+	}
+	server := server.New(
+		port,
+		dataPath,
+		timestampPrecision,
+		partitionDuration,
+		writeTimeout,
+		opts...,
+	)
 
 	// DEVELOPERS CODE:
 	// The following code will create an anonymous goroutine which will have a
@@ -71,6 +127,9 @@ var serveCmd = &cobra.Command{
 		if utils.Contains(okTimestampPrecision, timestampPrecision) == false {
 			log.Fatal("Timestamp precision must be either one of the following: ns, us, ms, or s.")
 		}
+		if retention <= 0 {
+			log.Fatal("Retention must be greater than zero.")
+		}
 
 		// Execute our command with our validated inputs.
 		doServe()
@@ -0,0 +1,111 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"time"
+
+	"github.com/spf13/cobra"
+	"google.golang.org/grpc"
+
+	tspb "github.com/golang/protobuf/ptypes/timestamp"
+
+	pb "github.com/bartmika/tstorage-server/proto"
+)
+
+func init() {
+	// The following are required.
+	streamInsertCmd.Flags().StringVarP(&metric, "metric", "m", "", "The metric to attach to the TSD.")
+	streamInsertCmd.MarkFlagRequired("metric")
+	streamInsertCmd.Flags().Float64VarP(&value, "value", "v", 0.00, "The value to attach to the TSD.")
+	streamInsertCmd.MarkFlagRequired("value")
+	streamInsertCmd.Flags().Int64VarP(&tsv, "timestamp", "t", 0, "The timestamp to attach to the TSD.")
+	streamInsertCmd.MarkFlagRequired("timestamp")
+
+	// The following are optional and will have defaults placed when missing.
+	streamInsertCmd.Flags().IntVarP(&port, "port", "p", 50051, "The port of our server.")
+	addClientSecurityFlags(streamInsertCmd)
+	rootCmd.AddCommand(streamInsertCmd)
+}
+
+func doStreamInsert() {
+	// Set up a direct connection to the gRPC server.
+	conn, err := grpc.Dial(
+		fmt.Sprintf(":%v", port),
+		transportSecurity(),
+		callMetadata(),
+		grpc.WithBlock(),
+	)
+	if err != nil {
+		log.Fatalf("did not connect: %v", err)
+	}
+
+	// Set up our protocol buffer interface.
+	client := pb.NewTStorageClient(conn)
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	ts := &tspb.Timestamp{
+		Seconds: tsv,
+		Nanos:   0,
+	}
+
+	// Generate our labels.
+	labels := []*pb.Label{}
+	labels = append(labels, &pb.Label{Name: "Source", Value: "Command"})
+
+	stream, err := client.StreamInsert(ctx)
+	if err != nil {
+		log.Fatalf("%v.StreamInsert(_) = _, %v", client, err)
+	}
+
+	// DEVELOPERS NOTE:
+	// To stream in both directions using gRPC, the following documentation
+	// will help explain how it works. Please visit it if the code below does
+	// not make any sense.
+	// https://grpc.io/docs/languages/go/basics/#bidirectional-streaming-rpc-1
+	//
+	// A long-lived client would keep sending batches with an increasing
+	// sequence number and resend any batch which the server did not ack.
+
+	batch := &pb.InsertBatch{
+		Sequence: 1,
+		Rows: []*pb.TimeSeriesDatum{
+			{Labels: labels, Metric: metric, Value: value, Timestamp: ts},
+		},
+	}
+	if err := stream.Send(batch); err != nil {
+		log.Fatalf("%v.Send(%v) = %v", stream, batch, err)
+	}
+	if err := stream.CloseSend(); err != nil {
+		log.Fatalf("%v.CloseSend() got error %v, want %v", stream, err, nil)
+	}
+
+	// Handle our stream of acknowledgements from the server.
+	for {
+		ack, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			log.Fatalf("error with stream: %v", err)
+		}
+		if !ack.Ok {
+			log.Fatalf("Batch #%v was rejected: %v", ack.Sequence, ack.Reason)
+		}
+		log.Printf("Batch #%v was successfully inserted", ack.Sequence)
+	}
+}
+
+var streamInsertCmd = &cobra.Command{
+	Use:   "stream_insert",
+	Short: "Insert single datum using bidirectional streaming",
+	Long:  `Connect to the gRPC server and send a batch of time-series data using the bidirectional streaming RPC and wait for the acknowledgement.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		doStreamInsert()
+	},
+}
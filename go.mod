@@ -4,6 +4,7 @@ go 1.16
 
 require (
 	github.com/golang/protobuf v1.5.2
+	github.com/golang/snappy v0.0.4
 	github.com/nakabonne/tstorage v0.2.1
 	github.com/spf13/cobra v1.2.1
 	google.golang.org/grpc v1.39.0
@@ -0,0 +1,85 @@
+package internal
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/nakabonne/tstorage"
+
+	pb "github.com/bartmika/tstorage-server/proto"
+)
+
+// Function will reduce the values into a single value using the aggregation
+// function. The values must not be empty.
+func aggregateValues(agg pb.Aggregation, values []float64) (float64, error) {
+	switch agg {
+	case pb.Aggregation_AGGREGATION_AVG:
+		return sum(values) / float64(len(values)), nil
+	case pb.Aggregation_AGGREGATION_MIN:
+		min := values[0]
+		for _, v := range values[1:] {
+			min = math.Min(min, v)
+		}
+		return min, nil
+	case pb.Aggregation_AGGREGATION_MAX:
+		max := values[0]
+		for _, v := range values[1:] {
+			max = math.Max(max, v)
+		}
+		return max, nil
+	case pb.Aggregation_AGGREGATION_SUM:
+		return sum(values), nil
+	case pb.Aggregation_AGGREGATION_COUNT:
+		return float64(len(values)), nil
+	case pb.Aggregation_AGGREGATION_STDDEV:
+		// DEVELOPERS NOTE:
+		// We are computing the population standard deviation which is the
+		// same as what Prometheus does with `stddev_over_time`.
+		mean := sum(values) / float64(len(values))
+		var variance float64
+		for _, v := range values {
+			variance += (v - mean) * (v - mean)
+		}
+		return math.Sqrt(variance / float64(len(values))), nil
+	case pb.Aggregation_AGGREGATION_P50:
+		return quantile(0.5, values), nil
+	case pb.Aggregation_AGGREGATION_P90:
+		return quantile(0.9, values), nil
+	case pb.Aggregation_AGGREGATION_P99:
+		return quantile(0.99, values), nil
+	}
+	return 0, fmt.Errorf("unsupported aggregation %v", agg)
+}
+
+// Function returns the sum of the values.
+func sum(values []float64) float64 {
+	var total float64
+	for _, v := range values {
+		total += v
+	}
+	return total
+}
+
+// Function returns the q-quantile (0 <= q <= 1) of the values using linear
+// interpolation between the closest ranks.
+func quantile(q float64, values []float64) float64 {
+	sorted := make([]float64, len(values))
+	copy(sorted, values)
+	sort.Float64s(sorted)
+
+	rank := q * float64(len(sorted)-1)
+	lower := math.Floor(rank)
+	upper := math.Ceil(rank)
+	weight := rank - lower
+	return sorted[int(lower)]*(1-weight) + sorted[int(upper)]*weight
+}
+
+// Function returns the values of the data points.
+func pointValues(points []*tstorage.DataPoint) []float64 {
+	values := make([]float64, 0, len(points))
+	for _, point := range points {
+		values = append(values, point.Value)
+	}
+	return values
+}
@@ -0,0 +1,99 @@
+package internal
+
+import (
+	"math"
+	"testing"
+
+	pb "github.com/bartmika/tstorage-server/proto"
+)
+
+func TestAggregateValues(t *testing.T) {
+	values := []float64{2, 4, 4, 4, 5, 5, 7, 9}
+	tests := []struct {
+		agg  pb.Aggregation
+		want float64
+	}{
+		{pb.Aggregation_AGGREGATION_AVG, 5},
+		{pb.Aggregation_AGGREGATION_MIN, 2},
+		{pb.Aggregation_AGGREGATION_MAX, 9},
+		{pb.Aggregation_AGGREGATION_SUM, 40},
+		{pb.Aggregation_AGGREGATION_COUNT, 8},
+		{pb.Aggregation_AGGREGATION_STDDEV, 2},
+		{pb.Aggregation_AGGREGATION_P50, 4.5},
+		{pb.Aggregation_AGGREGATION_P90, 7.6},
+		{pb.Aggregation_AGGREGATION_P99, 8.86},
+	}
+	for _, tt := range tests {
+		t.Run(tt.agg.String(), func(t *testing.T) {
+			got, err := aggregateValues(tt.agg, values)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAggregateValuesUnspecified(t *testing.T) {
+	if _, err := aggregateValues(pb.Aggregation_AGGREGATION_UNSPECIFIED, []float64{1}); err == nil {
+		t.Error("expected an error for an unspecified aggregation")
+	}
+}
+
+func TestQuantile(t *testing.T) {
+	tests := []struct {
+		name   string
+		q      float64
+		values []float64
+		want   float64
+	}{
+		{"single value", 0.5, []float64{3}, 3},
+		{"single value high quantile", 0.99, []float64{3}, 3},
+		{"minimum", 0, []float64{3, 1, 2}, 1},
+		{"maximum", 1, []float64{3, 1, 2}, 3},
+		{"exact rank", 0.5, []float64{3, 1, 2}, 2},
+		{"interpolated", 0.5, []float64{4, 1, 3, 2}, 2.5},
+		{"interpolated high", 0.9, []float64{4, 1, 3, 2}, 3.7},
+		{"negative values", 0.5, []float64{-1, -3}, -2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := quantile(tt.q, tt.values); math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestQuantileDoesNotSortInput(t *testing.T) {
+	values := []float64{3, 1, 2}
+	quantile(0.5, values)
+	if values[0] != 3 || values[1] != 1 || values[2] != 2 {
+		t.Errorf("input was modified: %v", values)
+	}
+}
+
+func TestStddev(t *testing.T) {
+	tests := []struct {
+		name   string
+		values []float64
+		want   float64
+	}{
+		{"single value", []float64{5}, 0},
+		{"constant", []float64{7, 7, 7}, 0},
+		{"population", []float64{1, 3}, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := aggregateValues(pb.Aggregation_AGGREGATION_STDDEV, tt.values)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
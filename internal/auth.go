@@ -0,0 +1,305 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/bartmika/tstorage-server/proto"
+)
+
+// The scopes a token can be granted. The admin scope grants every other scope.
+const (
+	scopeRead  = "read"
+	scopeWrite = "write"
+	scopeAdmin = "admin"
+)
+
+// methodScopes is the scope required to call every method of our service.
+var methodScopes = map[string]string{
+	"InsertRow":       scopeWrite,
+	"InsertRows":      scopeWrite,
+	"StreamInsert":    scopeWrite,
+	"Select":          scopeRead,
+	"SelectSeries":    scopeRead,
+	"ListMetrics":     scopeRead,
+	"ListLabelNames":  scopeRead,
+	"ListLabelValues": scopeRead,
+	"Aggregate":       scopeRead,
+	"RangeQuery":      scopeRead,
+	"Delete":          scopeAdmin,
+}
+
+const (
+	// healthMethodPrefix is the prefix of the methods of the health service,
+	// which can be called without a token so health checks keep working.
+	healthMethodPrefix = "/grpc.health.v1.Health/"
+
+	// reflectionMethodPrefix is the prefix of the methods of the reflection
+	// service.
+	reflectionMethodPrefix = "/grpc.reflection.v1alpha.ServerReflection/"
+)
+
+// tokenFile is the format of the token file given to the `serve` command.
+//
+// Example:
+//
+//	{
+//	    "tokens": [
+//	        {"name": "collector", "token": "0a1b2c", "scopes": ["write"], "metrics": ["solar_"], "tenant": "site_a"},
+//	        {"name": "grafana", "token": "3d4e5f", "scopes": ["read"]}
+//	    ]
+//	}
+type tokenFile struct {
+	Tokens []*accessToken `json:"tokens"`
+}
+
+// accessToken is a bearer token clients authenticate with along with what
+// the token is allowed to do.
+type accessToken struct {
+	// The name of the token, used to identify who made a request in the logs.
+	Name  string `json:"name"`
+	Token string `json:"token"`
+
+	// The scopes granted to the token.
+	Scopes []string `json:"scopes"`
+
+	// The prefixes of the metric names the token may access. Empty means
+	// every metric.
+	Metrics []string `json:"metrics"`
+
+	// The only tenant the token may access when multi-tenancy is enabled.
+	// Empty means the tenant is taken from the metadata of the request.
+	Tenant string `json:"tenant"`
+}
+
+// tokenContextKey is the key of the access token of the request inside of
+// the context of every request.
+type tokenContextKey struct{}
+
+// Function will load and validate the token file.
+func loadTokens(filePath string) (map[string]*accessToken, error) {
+	b, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read token file: %w", err)
+	}
+	f := &tokenFile{}
+	if err := json.Unmarshal(b, f); err != nil {
+		return nil, fmt.Errorf("failed to decode token file: %w", err)
+	}
+
+	tokens := map[string]*accessToken{}
+	for _, t := range f.Tokens {
+		if t.Token == "" {
+			return nil, fmt.Errorf("token %q has no token", t.Name)
+		}
+		if _, ok := tokens[t.Token]; ok {
+			return nil, fmt.Errorf("token %q is used more than once", t.Name)
+		}
+		if t.Tenant != "" {
+			if err := validateTenantName(t.Tenant); err != nil {
+				return nil, fmt.Errorf("token %q has an %w", t.Name, err)
+			}
+		}
+		for _, scope := range t.Scopes {
+			if scope != scopeRead && scope != scopeWrite && scope != scopeAdmin {
+				return nil, fmt.Errorf("token %q has an unsupported scope %q", t.Name, scope)
+			}
+		}
+		tokens[t.Token] = t
+	}
+	return tokens, nil
+}
+
+// Function returns true if the token was granted the scope.
+func (t *accessToken) hasScope(scope string) bool {
+	for _, s := range t.Scopes {
+		if s == scope || s == scopeAdmin {
+			return true
+		}
+	}
+	return false
+}
+
+// Function returns true if the token may access the metric. An empty metric
+// stands for any metric and is only allowed without a metric allowlist.
+func (t *accessToken) allowsMetric(metric string) bool {
+	if len(t.Metrics) == 0 {
+		return true
+	}
+	for _, prefix := range t.Metrics {
+		if metric != "" && strings.HasPrefix(metric, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// Function returns the access token of the request, if there is one.
+func tokenFromContext(ctx context.Context) (*accessToken, bool) {
+	t, ok := ctx.Value(tokenContextKey{}).(*accessToken)
+	return t, ok
+}
+
+// Function will authenticate the bearer token of the request and make sure
+// the token was granted the scope needed by the method. The context is
+// returned with the access token attached.
+func (s *TStorageServer) authenticate(ctx context.Context, fullMethod string) (context.Context, *accessToken, error) {
+	// DEVELOPERS NOTE:
+	// Any method we do not know about, for example one added to the service
+	// without updating `methodScopes`, requires the admin scope. Discovering
+	// our services with reflection only requires reading.
+	method := fullMethod[strings.LastIndex(fullMethod, "/")+1:]
+	scope, ok := methodScopes[method]
+	if strings.HasPrefix(fullMethod, reflectionMethodPrefix) {
+		scope = scopeRead
+	} else if !ok || !isServiceMethod(fullMethod) {
+		scope = scopeAdmin
+	}
+	return s.authenticateScope(ctx, scope)
+}
+
+// Function will authenticate the bearer token of the request and make sure
+// the token was granted the scope. The context is returned with the access
+// token attached.
+func (s *TStorageServer) authenticateScope(ctx context.Context, scope string) (context.Context, *accessToken, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	values := md.Get("authorization")
+	if len(values) == 0 || !strings.HasPrefix(values[0], "Bearer ") {
+		return nil, nil, status.Error(codes.Unauthenticated, "missing bearer token")
+	}
+	t, ok := s.tokens[strings.TrimPrefix(values[0], "Bearer ")]
+	if !ok {
+		return nil, nil, status.Error(codes.Unauthenticated, "invalid bearer token")
+	}
+	if !t.hasScope(scope) {
+		return nil, nil, status.Errorf(codes.PermissionDenied, "token %q is missing the %v scope", t.Name, scope)
+	}
+	return context.WithValue(ctx, tokenContextKey{}, t), t, nil
+}
+
+// Function returns true if the method belongs to our `TStorage` service.
+func isServiceMethod(fullMethod string) bool {
+	return strings.HasPrefix(fullMethod, "/"+pb.TStorage_ServiceDesc.ServiceName+"/")
+}
+
+// Function will make sure the token may access every metric the request
+// message refers to.
+func authorizeMessage(t *accessToken, msg interface{}) error {
+	return authorizeMetrics(t, requestMetrics(msg))
+}
+
+// Function will make sure the token may access every metric, where an empty
+// metric stands for any metric.
+func authorizeMetrics(t *accessToken, metrics []string) error {
+	if len(t.Metrics) == 0 {
+		return nil
+	}
+	for _, metric := range metrics {
+		if !t.allowsMetric(metric) {
+			if metric == "" {
+				return status.Errorf(codes.PermissionDenied, "token %q must select a metric", t.Name)
+			}
+			return status.Errorf(codes.PermissionDenied, "token %q may not access the %q metric", t.Name, metric)
+		}
+	}
+	return nil
+}
+
+// Function returns the metric names the request message refers to where an
+// empty name stands for any metric.
+//
+// DEVELOPERS NOTE:
+// Requests selecting series with label matchers but without a metric may
+// match any metric, so tokens restricted to some metrics must always set the
+// metric of the request.
+func requestMetrics(msg interface{}) []string {
+	switch m := msg.(type) {
+	case *pb.TimeSeriesDatum:
+		return []string{m.Metric}
+	case *pb.InsertBatch:
+		metrics := make([]string, 0, len(m.Rows))
+		for _, row := range m.Rows {
+			metrics = append(metrics, row.Metric)
+		}
+		return metrics
+	case *pb.Filter:
+		// The filter is nil when it is missing from the request.
+		return []string{m.GetMetric()}
+	case *pb.MetadataFilter:
+		return []string{m.GetMetric()}
+	case *pb.ListLabelValuesRequest:
+		return requestMetrics(m.GetFilter())
+	case *pb.AggregateRequest:
+		return requestMetrics(m.GetFilter())
+	case *pb.RangeQueryRequest:
+		return requestMetrics(m.GetFilter())
+	}
+	return []string{""}
+}
+
+// Function returns the interceptor authorizing every unary call.
+func (s *TStorageServer) unaryAuthInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if strings.HasPrefix(info.FullMethod, healthMethodPrefix) {
+			return handler(ctx, req)
+		}
+		ctx, t, err := s.authenticate(ctx, info.FullMethod)
+		if err != nil {
+			return nil, err
+		}
+		if isServiceMethod(info.FullMethod) {
+			if err := authorizeMessage(t, req); err != nil {
+				return nil, err
+			}
+		}
+		return handler(ctx, req)
+	}
+}
+
+// Function returns the interceptor authorizing every streaming call along
+// with every message received on the stream.
+func (s *TStorageServer) streamAuthInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if strings.HasPrefix(info.FullMethod, healthMethodPrefix) {
+			return handler(srv, ss)
+		}
+		ctx, t, err := s.authenticate(ss.Context(), info.FullMethod)
+		if err != nil {
+			return err
+		}
+		if !isServiceMethod(info.FullMethod) {
+			return handler(srv, &authorizedStream{ServerStream: ss, ctx: ctx})
+		}
+		return handler(srv, &authorizedStream{ServerStream: ss, ctx: ctx, token: t})
+	}
+}
+
+// authorizedStream checks every message received from the client against the
+// access token of the stream, unless the token is nil.
+type authorizedStream struct {
+	grpc.ServerStream
+	ctx   context.Context
+	token *accessToken
+}
+
+func (a *authorizedStream) Context() context.Context {
+	return a.ctx
+}
+
+func (a *authorizedStream) RecvMsg(m interface{}) error {
+	if err := a.ServerStream.RecvMsg(m); err != nil {
+		return err
+	}
+	if a.token == nil {
+		return nil
+	}
+	return authorizeMessage(a.token, m)
+}
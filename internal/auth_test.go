@@ -0,0 +1,139 @@
+package internal
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/bartmika/tstorage-server/proto"
+)
+
+// Function returns the full name of the method of our `TStorage` service.
+func serviceMethod(method string) string {
+	return "/" + pb.TStorage_ServiceDesc.ServiceName + "/" + method
+}
+
+// Function returns the server with the tokens used by the tests.
+func newAuthTestServer() *TStorageServer {
+	return &TStorageServer{tokens: map[string]*accessToken{
+		"w": {Name: "writer", Token: "w", Scopes: []string{scopeWrite}, Metrics: []string{"solar_"}},
+		"r": {Name: "reader", Token: "r", Scopes: []string{scopeRead}},
+		"a": {Name: "admin", Token: "a", Scopes: []string{scopeAdmin}},
+		"n": {Name: "none", Token: "n"},
+	}}
+}
+
+func TestAuthenticate(t *testing.T) {
+	s := newAuthTestServer()
+	tests := []struct {
+		name   string
+		header string
+		method string
+		want   codes.Code
+	}{
+		{"missing token", "", serviceMethod("Select"), codes.Unauthenticated},
+		{"not a bearer token", "Basic r", serviceMethod("Select"), codes.Unauthenticated},
+		{"unknown token", "Bearer x", serviceMethod("Select"), codes.Unauthenticated},
+		{"read scope reads", "Bearer r", serviceMethod("Select"), codes.OK},
+		{"read scope can not write", "Bearer r", serviceMethod("InsertRow"), codes.PermissionDenied},
+		{"write scope writes", "Bearer w", serviceMethod("StreamInsert"), codes.OK},
+		{"write scope can not read", "Bearer w", serviceMethod("RangeQuery"), codes.PermissionDenied},
+		{"read scope can not delete", "Bearer r", serviceMethod("Delete"), codes.PermissionDenied},
+		{"admin scope deletes", "Bearer a", serviceMethod("Delete"), codes.OK},
+		{"admin scope reads", "Bearer a", serviceMethod("Select"), codes.OK},
+		{"admin scope writes", "Bearer a", serviceMethod("InsertRows"), codes.OK},
+		{"no scopes", "Bearer n", serviceMethod("Select"), codes.PermissionDenied},
+		{"unknown method needs admin", "Bearer r", serviceMethod("Unknown"), codes.PermissionDenied},
+		{"other service needs admin", "Bearer r", "/other.Service/Select", codes.PermissionDenied},
+		{"reflection needs read", "Bearer r", reflectionMethodPrefix + "ServerReflectionInfo", codes.OK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tt.header != "" {
+				ctx = metadata.NewIncomingContext(ctx, metadata.Pairs("authorization", tt.header))
+			}
+			ctx, token, err := s.authenticate(ctx, tt.method)
+			if got := status.Code(err); got != tt.want {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+			if err != nil {
+				return
+			}
+			if fromCtx, ok := tokenFromContext(ctx); !ok || fromCtx != token {
+				t.Error("the token is not attached to the context")
+			}
+		})
+	}
+}
+
+func TestAuthorizeMessage(t *testing.T) {
+	restricted := &accessToken{Name: "writer", Metrics: []string{"solar_", "wind_"}}
+	unrestricted := &accessToken{Name: "reader"}
+	tests := []struct {
+		name  string
+		token *accessToken
+		msg   interface{}
+		want  codes.Code
+	}{
+		{"allowed metric", restricted, &pb.TimeSeriesDatum{Metric: "solar_power"}, codes.OK},
+		{"second prefix", restricted, &pb.TimeSeriesDatum{Metric: "wind_speed"}, codes.OK},
+		{"prefix only matches the start", restricted, &pb.TimeSeriesDatum{Metric: "my_solar_power"}, codes.PermissionDenied},
+		{"other metric", restricted, &pb.TimeSeriesDatum{Metric: "temperature"}, codes.PermissionDenied},
+		{"batch with an allowed metric", restricted, &pb.InsertBatch{Rows: []*pb.TimeSeriesDatum{{Metric: "solar_power"}, {Metric: "wind_speed"}}}, codes.OK},
+		{"batch with one other metric", restricted, &pb.InsertBatch{Rows: []*pb.TimeSeriesDatum{{Metric: "solar_power"}, {Metric: "temperature"}}}, codes.PermissionDenied},
+		{"filter", restricted, &pb.Filter{Metric: "solar_power"}, codes.OK},
+		{"filter without a metric", restricted, &pb.Filter{}, codes.PermissionDenied},
+		{"nested filter", restricted, &pb.RangeQueryRequest{Filter: &pb.Filter{Metric: "temperature"}}, codes.PermissionDenied},
+		{"missing nested filter", restricted, &pb.AggregateRequest{}, codes.PermissionDenied},
+		{"metadata filter without a metric", restricted, &pb.MetadataFilter{}, codes.PermissionDenied},
+		{"unknown message", restricted, &pb.DeleteResponse{}, codes.PermissionDenied},
+		{"unrestricted without a metric", unrestricted, &pb.Filter{}, codes.OK},
+		{"unrestricted", unrestricted, &pb.TimeSeriesDatum{Metric: "temperature"}, codes.OK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := status.Code(authorizeMessage(tt.token, tt.msg)); got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadTokens(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		wantErr bool
+	}{
+		{"valid", `{"tokens": [{"name": "a", "token": "x", "scopes": ["read", "write"], "metrics": ["solar_"], "tenant": "site_a"}]}`, false},
+		{"missing token", `{"tokens": [{"name": "a", "scopes": ["read"]}]}`, true},
+		{"duplicate token", `{"tokens": [{"name": "a", "token": "x"}, {"name": "b", "token": "x"}]}`, true},
+		{"unsupported scope", `{"tokens": [{"name": "a", "token": "x", "scopes": ["delete"]}]}`, true},
+		{"invalid tenant", `{"tokens": [{"name": "a", "token": "x", "tenant": "../etc"}]}`, true},
+		{"invalid json", `{"tokens": [`, true},
+	}
+	dir, err := ioutil.TempDir("", "tokens")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(dir, string(rune('a'+i))+".json")
+			if err := ioutil.WriteFile(path, []byte(tt.content), 0644); err != nil {
+				t.Fatal(err)
+			}
+			_, err := loadTokens(path)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("got error %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
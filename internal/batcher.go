@@ -0,0 +1,98 @@
+package internal
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"github.com/nakabonne/tstorage"
+)
+
+// batchFlushInterval is how often the rows buffered by a batcher are written
+// to storage when the batch is not full yet.
+const batchFlushInterval = time.Second
+
+// rowBatcher buffers the rows received by one of our listeners and writes
+// them to the storage of a tenant in batches.
+//
+// DEVELOPERS NOTE:
+// Our listeners receive data points a few at a time, for example one UDP
+// packet at a time, so we buffer them to reduce the number of calls made to
+// the storage. The batch is written once it is full or once a second.
+type rowBatcher struct {
+	mu sync.Mutex
+
+	server *TStorageServer
+	name   string
+	tenant string
+	size   int
+	rows   []tstorage.Row
+}
+
+// Function will create a batcher, with the name of the listener used by the
+// logs, writing into the tenant and start writing its batches in the
+// background until the server is stopped.
+func (s *TStorageServer) newRowBatcher(name string, tenant string) *rowBatcher {
+	b := &rowBatcher{
+		server: s,
+		name:   name,
+		tenant: tenant,
+		size:   s.insertBatchSize,
+		rows:   make([]tstorage.Row, 0, s.insertBatchSize),
+	}
+	go func() {
+		ticker := time.NewTicker(batchFlushInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-s.done:
+				return
+			case <-ticker.C:
+				b.flush()
+			}
+		}
+	}()
+	return b
+}
+
+// Function will buffer the rows and write the batch once it is full.
+func (b *rowBatcher) add(rows []tstorage.Row) {
+	b.mu.Lock()
+	b.rows = append(b.rows, rows...)
+	full := len(b.rows) >= b.size
+	b.mu.Unlock()
+
+	if full {
+		b.flush()
+	}
+}
+
+// Function will write the buffered rows to storage. Rows which could not be
+// written are logged and dropped since the sender is not waiting for an
+// answer.
+func (b *rowBatcher) flush() {
+	b.mu.Lock()
+	rows := b.rows
+	b.rows = make([]tstorage.Row, 0, b.size)
+	b.mu.Unlock()
+	if len(rows) == 0 {
+		return
+	}
+
+	impl, release, err := b.server.tenants.acquire(b.tenant)
+	if err != nil {
+		log.Printf("%v: dropped %v rows: %v", b.name, len(rows), err)
+		return
+	}
+	defer release()
+	if err := impl.insertRows(rows); err != nil {
+		log.Printf("%v: dropped %v rows: %v", b.name, len(rows), err)
+	}
+}
+
+// Function will write the remaining buffered rows to storage when the server
+// is stopped.
+func (b *rowBatcher) Close() error {
+	b.flush()
+	return nil
+}
@@ -0,0 +1,438 @@
+package internal
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nakabonne/tstorage"
+)
+
+const (
+	// catalogFileName is the name of the file, saved inside the data path,
+	// which holds the series catalog between restarts.
+	catalogFileName = "catalog.json"
+
+	// catalogLogFileName is the name of the append-only log, saved next to
+	// the catalog, which holds the series added since the catalog was last
+	// saved.
+	catalogLogFileName = "catalog.log"
+
+	// catalogSaveInterval is how often the catalog is saved to disk.
+	catalogSaveInterval = 30 * time.Second
+)
+
+// seriesCatalog keeps track of every series (metric and label set
+// combination) written to the storage along with the time range of the
+// series. The `tstorage` package can only select a series by its exact label
+// set and has no way of listing what it stores, so the catalog is used to
+// find the label sets of every series satisfying a group of label matchers
+// and to discover the metrics and labels being stored.
+type seriesCatalog struct {
+	mu sync.RWMutex
+
+	// The location of the file the catalog is persisted to. If empty then
+	// the catalog is only kept in memory.
+	path  string
+	dirty bool
+
+	// The append-only log every new series is written to before it is
+	// tracked, so no series is lost if the server crashes before the next
+	// save. Nil if the catalog is only kept in memory.
+	log *os.File
+
+	// The series are grouped by metric and then by the unique key produced
+	// by `seriesKey`.
+	metrics map[string]map[string]*catalogEntry
+}
+
+// catalogLogEntry is a single series written to the append-only log.
+type catalogLogEntry struct {
+	Metric string        `json:"metric"`
+	Entry  *catalogEntry `json:"entry"`
+}
+
+// catalogEntry is a single series tracked by the catalog.
+type catalogEntry struct {
+	Labels       []tstorage.Label `json:"labels"`
+	MinTimestamp int64            `json:"minTimestamp"`
+	MaxTimestamp int64            `json:"maxTimestamp"`
+}
+
+// Function will create our series catalog and load the previously saved
+// catalog from the file path, if there is one.
+func newSeriesCatalog(path string) (*seriesCatalog, error) {
+	c := &seriesCatalog{
+		path:    path,
+		metrics: map[string]map[string]*catalogEntry{},
+	}
+	if path == "" {
+		return c, nil
+	}
+
+	b, err := ioutil.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read series catalog: %w", err)
+	}
+	if err == nil {
+		saved := map[string][]*catalogEntry{}
+		if err := json.Unmarshal(b, &saved); err != nil {
+			return nil, fmt.Errorf("failed to decode series catalog: %w", err)
+		}
+		for metric, entries := range saved {
+			for _, entry := range entries {
+				c.track(metric, entry)
+			}
+		}
+	}
+
+	// Replay the series added after the catalog was last saved.
+	logPath := filepath.Join(filepath.Dir(path), catalogLogFileName)
+	b, err = ioutil.ReadFile(logPath)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read series catalog log: %w", err)
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(b))
+	scanner.Buffer(make([]byte, 0, 64*1024), len(b)+1)
+	for scanner.Scan() {
+		entry := &catalogLogEntry{}
+		if err := json.Unmarshal(scanner.Bytes(), entry); err != nil || entry.Entry == nil {
+			// DEVELOPERS NOTE:
+			// Only the last line can be invalid, when the server crashed
+			// while writing it, in which case the rows of the series were
+			// never written either.
+			break
+		}
+		c.track(entry.Metric, entry.Entry)
+		c.dirty = true
+	}
+
+	c.log, err = os.OpenFile(logPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open series catalog log: %w", err)
+	}
+	return c, nil
+}
+
+// Function will track the series, or extend the time range of the series if
+// it is already tracked. The lock must be held by the caller, if needed.
+func (c *seriesCatalog) track(metric string, entry *catalogEntry) {
+	entries, ok := c.metrics[metric]
+	if !ok {
+		entries = map[string]*catalogEntry{}
+		c.metrics[metric] = entries
+	}
+	key := seriesKey(entry.Labels)
+	existing, ok := entries[key]
+	if !ok {
+		entries[key] = entry
+		return
+	}
+	if entry.MinTimestamp < existing.MinTimestamp {
+		existing.MinTimestamp = entry.MinTimestamp
+	}
+	if entry.MaxTimestamp > existing.MaxTimestamp {
+		existing.MaxTimestamp = entry.MaxTimestamp
+	}
+}
+
+// Function will add the series of the rows to the catalog, if they were not
+// already tracked, and extend the time range of the series. The new series
+// are written to the log before returning.
+func (c *seriesCatalog) add(rows []tstorage.Row) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var added bytes.Buffer
+	for _, row := range rows {
+		entries, ok := c.metrics[row.Metric]
+		if !ok {
+			entries = map[string]*catalogEntry{}
+			c.metrics[row.Metric] = entries
+		}
+		labels := sortedLabels(row.Labels)
+		key := seriesKey(labels)
+		entry, ok := entries[key]
+		if !ok {
+			entry = &catalogEntry{Labels: labels, MinTimestamp: row.Timestamp, MaxTimestamp: row.Timestamp}
+			entries[key] = entry
+			if c.log != nil {
+				b, err := json.Marshal(&catalogLogEntry{Metric: row.Metric, Entry: entry})
+				if err != nil {
+					return fmt.Errorf("failed to encode series catalog log: %w", err)
+				}
+				added.Write(b)
+				added.WriteByte('\n')
+			}
+		}
+		if row.Timestamp < entry.MinTimestamp {
+			entry.MinTimestamp = row.Timestamp
+		}
+		if row.Timestamp > entry.MaxTimestamp {
+			entry.MaxTimestamp = row.Timestamp
+		}
+		c.dirty = true
+	}
+
+	if added.Len() == 0 {
+		return nil
+	}
+	if _, err := c.log.Write(added.Bytes()); err != nil {
+		return fmt.Errorf("failed to write series catalog log: %w", err)
+	}
+	if err := c.log.Sync(); err != nil {
+		return fmt.Errorf("failed to write series catalog log: %w", err)
+	}
+	return nil
+}
+
+// Function will stop tracking every series whose entire time range is
+// covered by the deleted `start` (inclusive) and `end` (exclusive) range.
+func (c *seriesCatalog) remove(deleted []*series, start int64, end int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, ser := range deleted {
+		entries, ok := c.metrics[ser.metric]
+		if !ok {
+			continue
+		}
+		key := seriesKey(sortedLabels(ser.labels))
+		entry, ok := entries[key]
+		if !ok || entry.MinTimestamp < start || entry.MaxTimestamp >= end {
+			continue
+		}
+		delete(entries, key)
+		if len(entries) == 0 {
+			delete(c.metrics, ser.metric)
+		}
+		c.dirty = true
+	}
+}
+
+// Function will stop tracking every series whose data points are all older
+// than the given timestamp and returns how many series were removed.
+func (c *seriesCatalog) expire(oldest int64) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	expired := 0
+	for metric, entries := range c.metrics {
+		for key, entry := range entries {
+			if entry.MaxTimestamp >= oldest {
+				if entry.MinTimestamp < oldest {
+					entry.MinTimestamp = oldest
+				}
+				continue
+			}
+			delete(entries, key)
+			expired++
+		}
+		if len(entries) == 0 {
+			delete(c.metrics, metric)
+		}
+	}
+	if expired > 0 {
+		c.dirty = true
+	}
+	return expired
+}
+
+// Function returns every series of the metric whose labels satisfy all the
+// matchers and which has data points within the `start` (inclusive) and
+// `end` (exclusive) range. If the metric is empty then the series of all
+// metrics are searched, which is useful when matching on the `__name__` label.
+func (c *seriesCatalog) find(metric string, matchers []*labelMatcher, start int64, end int64) []*series {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	results := []*series{}
+	for name, entries := range c.metrics {
+		if metric != "" && name != metric {
+			continue
+		}
+		for _, entry := range entries {
+			if entry.MinTimestamp >= end || entry.MaxTimestamp < start {
+				continue
+			}
+			if matchesAll(matchers, name, entry.Labels) {
+				// Give back a copy since `tstorage` sorts the labels in place.
+				results = append(results, &series{metric: name, labels: sortedLabels(entry.Labels)})
+			}
+		}
+	}
+
+	// Return the series in a predictable order.
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].metric != results[j].metric {
+			return results[i].metric < results[j].metric
+		}
+		return seriesKey(results[i].labels) < seriesKey(results[j].labels)
+	})
+	return results
+}
+
+// Function returns the series with the exact label set if it is tracked and
+// has data points within the `start` (inclusive) and `end` (exclusive) range.
+func (c *seriesCatalog) lookup(metric string, labels []tstorage.Label, start int64, end int64) []*series {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	entry, ok := c.metrics[metric][seriesKey(sortedLabels(labels))]
+	if !ok || entry.MinTimestamp >= end || entry.MaxTimestamp < start {
+		return []*series{}
+	}
+	return []*series{{metric: metric, labels: sortedLabels(entry.Labels)}}
+}
+
+// Function returns the newest timestamp of the tracked series.
+func (c *seriesCatalog) newest(metric string, labels []tstorage.Label) int64 {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if entry, ok := c.metrics[metric][seriesKey(sortedLabels(labels))]; ok {
+		return entry.MaxTimestamp
+	}
+	return math.MaxInt64
+}
+
+// Function returns the sorted metric names of every series found.
+func (c *seriesCatalog) metricNames(matchers []*labelMatcher, start int64, end int64) []string {
+	names := map[string]bool{}
+	for _, ser := range c.find("", matchers, start, end) {
+		names[ser.metric] = true
+	}
+	return sortedKeys(names)
+}
+
+// Function returns the sorted label names used by every series found.
+func (c *seriesCatalog) labelNames(metric string, matchers []*labelMatcher, start int64, end int64) []string {
+	names := map[string]bool{}
+	for _, ser := range c.find(metric, matchers, start, end) {
+		for _, label := range ser.labels {
+			names[label.Name] = true
+		}
+	}
+	return sortedKeys(names)
+}
+
+// Function returns the sorted values of the label used by every series found.
+func (c *seriesCatalog) labelValues(name string, metric string, matchers []*labelMatcher, start int64, end int64) []string {
+	values := map[string]bool{}
+	for _, ser := range c.find(metric, matchers, start, end) {
+		if value := labelValue(ser.metric, ser.labels, name); value != "" {
+			values[value] = true
+		}
+	}
+	return sortedKeys(values)
+}
+
+// Function returns the number of series tracked by the catalog.
+func (c *seriesCatalog) size() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	n := 0
+	for _, entries := range c.metrics {
+		n += len(entries)
+	}
+	return n
+}
+
+// Function will save the catalog to disk if it was changed since the last
+// time it was saved.
+func (c *seriesCatalog) save() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.path == "" || !c.dirty {
+		return nil
+	}
+
+	saved := map[string][]*catalogEntry{}
+	for metric, entries := range c.metrics {
+		for _, entry := range entries {
+			saved[metric] = append(saved[metric], entry)
+		}
+	}
+	b, err := json.Marshal(saved)
+	if err != nil {
+		return fmt.Errorf("failed to encode series catalog: %w", err)
+	}
+
+	// DEVELOPERS NOTE:
+	// We write to a temporary file first and then rename it so a crash while
+	// saving will never leave us with a partially written catalog.
+	tmp := c.path + ".tmp"
+	if err := ioutil.WriteFile(tmp, b, 0644); err != nil {
+		return fmt.Errorf("failed to write series catalog: %w", err)
+	}
+	if err := os.Rename(tmp, c.path); err != nil {
+		return fmt.Errorf("failed to write series catalog: %w", err)
+	}
+
+	// The saved catalog now has every series of the log.
+	if err := c.log.Truncate(0); err != nil {
+		return fmt.Errorf("failed to truncate series catalog log: %w", err)
+	}
+	c.dirty = false
+	return nil
+}
+
+// Function will save the catalog and close its log.
+func (c *seriesCatalog) close() error {
+	if err := c.save(); err != nil {
+		return err
+	}
+	if c.log == nil {
+		return nil
+	}
+	return c.log.Close()
+}
+
+// Function returns a copy of the labels sorted by name.
+func sortedLabels(labels []tstorage.Label) []tstorage.Label {
+	out := make([]tstorage.Label, len(labels))
+	copy(out, labels)
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].Name < out[j].Name
+	})
+	return out
+}
+
+// Function returns a string which uniquely identifies the sorted label set.
+func seriesKey(labels []tstorage.Label) string {
+	var sb strings.Builder
+	for _, label := range labels {
+		sb.WriteString(label.Name)
+		sb.WriteByte(0)
+		sb.WriteString(label.Value)
+		sb.WriteByte(0)
+	}
+	return sb.String()
+}
+
+// Function returns a string which uniquely identifies the series with the
+// sorted label set.
+func seriesID(metric string, sortedLabels []tstorage.Label) string {
+	return metric + "\x00" + seriesKey(sortedLabels)
+}
+
+// Function returns the keys of the set in sorted order.
+func sortedKeys(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for key := range set {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,140 @@
+package internal
+
+import (
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/nakabonne/tstorage"
+)
+
+func catalogRow(metric string, host string, ts int64) tstorage.Row {
+	return tstorage.Row{
+		Metric:    metric,
+		Labels:    []tstorage.Label{{Name: "host", Value: host}},
+		DataPoint: tstorage.DataPoint{Timestamp: ts, Value: 1},
+	}
+}
+
+func TestSeriesCatalogSurvivesCrash(t *testing.T) {
+	dir, err := ioutil.TempDir("", "catalog")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, catalogFileName)
+
+	c, err := newSeriesCatalog(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.add([]tstorage.Row{catalogRow("cpu", "a", 10)}); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.save(); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.add([]tstorage.Row{catalogRow("cpu", "b", 20), catalogRow("mem", "a", 30)}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Simulate a crash while writing the next series by leaving a partial
+	// line at the end of the log, without saving or closing the catalog.
+	f, err := os.OpenFile(filepath.Join(dir, catalogLogFileName), os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(`{"metric":"disk","ent`)
+	f.Close()
+
+	c, err = newSeriesCatalog(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := c.metricNames(nil, math.MinInt64, math.MaxInt64)
+	if len(got) != 2 || got[0] != "cpu" || got[1] != "mem" {
+		t.Fatalf("metricNames() = %v, want [cpu mem]", got)
+	}
+	if n := len(c.find("cpu", nil, math.MinInt64, math.MaxInt64)); n != 2 {
+		t.Fatalf("found %d cpu series, want 2", n)
+	}
+
+	// Saving includes the series of the log and empties it.
+	if err := c.close(); err != nil {
+		t.Fatal(err)
+	}
+	if b, _ := ioutil.ReadFile(filepath.Join(dir, catalogLogFileName)); len(b) != 0 {
+		t.Fatalf("log has %d bytes after saving, want 0", len(b))
+	}
+	c, err = newSeriesCatalog(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.close()
+	if n := c.size(); n != 3 {
+		t.Fatalf("size() = %d, want 3", n)
+	}
+}
+
+func TestSeriesCatalogFind(t *testing.T) {
+	c, err := newSeriesCatalog("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.add([]tstorage.Row{catalogRow("cpu", "a", 10), catalogRow("cpu", "a", 20), catalogRow("cpu", "b", 50)})
+
+	re, _ := newLabelMatcher(matchRegexp, "host", "a|b")
+	tests := []struct {
+		name  string
+		start int64
+		end   int64
+		want  int
+	}{
+		{"everything", math.MinInt64, math.MaxInt64, 2},
+		{"end is exclusive", 0, 10, 0},
+		{"start is inclusive", 20, 21, 1},
+		{"between data points", 15, 40, 1},
+		{"between series", 30, 40, 0},
+		{"after every series", 51, 100, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := len(c.find("cpu", []*labelMatcher{re}, tt.start, tt.end)); got != tt.want {
+				t.Fatalf("find() returned %d series, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSeriesCatalogLookup(t *testing.T) {
+	c, err := newSeriesCatalog("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.add([]tstorage.Row{catalogRow("cpu", "a", 10), catalogRow("cpu", "a", 20)})
+
+	tests := []struct {
+		name   string
+		metric string
+		labels []tstorage.Label
+		start  int64
+		end    int64
+		want   int
+	}{
+		{"exact", "cpu", []tstorage.Label{{Name: "host", Value: "a"}}, math.MinInt64, math.MaxInt64, 1},
+		{"other metric", "mem", []tstorage.Label{{Name: "host", Value: "a"}}, math.MinInt64, math.MaxInt64, 0},
+		{"other label value", "cpu", []tstorage.Label{{Name: "host", Value: "b"}}, math.MinInt64, math.MaxInt64, 0},
+		{"subset of labels", "cpu", nil, math.MinInt64, math.MaxInt64, 0},
+		{"superset of labels", "cpu", []tstorage.Label{{Name: "host", Value: "a"}, {Name: "dc", Value: "x"}}, math.MinInt64, math.MaxInt64, 0},
+		{"outside of range", "cpu", []tstorage.Label{{Name: "host", Value: "a"}}, 21, 30, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := len(c.lookup(tt.metric, tt.labels, tt.start, tt.end)); got != tt.want {
+				t.Fatalf("lookup() returned %d series, want %d", got, tt.want)
+			}
+		})
+	}
+}
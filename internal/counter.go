@@ -0,0 +1,66 @@
+package internal
+
+import (
+	"fmt"
+
+	"github.com/nakabonne/tstorage"
+
+	pb "github.com/bartmika/tstorage-server/proto"
+)
+
+// Function will apply the counter function to the window of data points,
+// which must be sorted by timestamp, where `seconds` is the duration of the
+// window used by `FUNCTION_RATE`. The boolean is false if the window does not
+// have enough data points to compute a value.
+//
+// DEVELOPERS NOTE:
+// Counters only ever go up, therefore if a value is lower than the value
+// before it we assume the counter was reset (ex: the device restarted) and
+// started counting from zero again. In this case the increase between those
+// two data points is the new value itself. Unlike Prometheus we do not
+// extrapolate to the edges of the window, so the sum of the increases of
+// consecutive windows is always exactly the increase of the counter.
+func counterValue(fn pb.Function, window []*tstorage.DataPoint, seconds float64, precision tstorage.TimestampPrecision) (float64, bool, error) {
+	if len(window) < 2 {
+		return 0, false, nil
+	}
+
+	switch fn {
+	case pb.Function_FUNCTION_INCREASE:
+		return counterIncrease(window), true, nil
+	case pb.Function_FUNCTION_RATE:
+		if seconds <= 0 {
+			return 0, false, nil
+		}
+		return counterIncrease(window) / seconds, true, nil
+	case pb.Function_FUNCTION_IRATE:
+		// Only the last two data points are used.
+		last, prev := window[len(window)-1], window[len(window)-2]
+		elapsed := float64(last.Timestamp-prev.Timestamp) * precisionUnit(precision).Seconds()
+		if elapsed <= 0 {
+			return 0, false, nil
+		}
+		return counterIncrease([]*tstorage.DataPoint{prev, last}) / elapsed, true, nil
+	case pb.Function_FUNCTION_DELTA:
+		// DEVELOPERS NOTE:
+		// Delta is meant for gauges, which can go up and down, so it is the
+		// plain difference between the last and first values without any
+		// reset detection.
+		return window[len(window)-1].Value - window[0].Value, true, nil
+	}
+	return 0, false, fmt.Errorf("unsupported function %v", fn)
+}
+
+// Function returns how much the counter increased over the data points while
+// taking counter resets into account.
+func counterIncrease(points []*tstorage.DataPoint) float64 {
+	var increase float64
+	for i := 1; i < len(points); i++ {
+		if points[i].Value >= points[i-1].Value {
+			increase += points[i].Value - points[i-1].Value
+		} else {
+			increase += points[i].Value
+		}
+	}
+	return increase
+}
@@ -0,0 +1,104 @@
+package internal
+
+import (
+	"math"
+	"testing"
+
+	"github.com/nakabonne/tstorage"
+
+	pb "github.com/bartmika/tstorage-server/proto"
+)
+
+// Function returns data points one second apart with the values.
+func counterPoints(values ...float64) []*tstorage.DataPoint {
+	points := make([]*tstorage.DataPoint, 0, len(values))
+	for i, v := range values {
+		points = append(points, &tstorage.DataPoint{Timestamp: int64(i), Value: v})
+	}
+	return points
+}
+
+func TestCounterIncrease(t *testing.T) {
+	tests := []struct {
+		name   string
+		values []float64
+		want   float64
+	}{
+		{"empty", nil, 0},
+		{"single", []float64{5}, 0},
+		{"monotonic", []float64{1, 3, 6}, 5},
+		{"flat", []float64{4, 4, 4}, 0},
+		{"reset", []float64{10, 12, 3, 5}, 2 + 3 + 2},
+		{"reset to zero", []float64{10, 0, 4}, 4},
+		{"multiple resets", []float64{5, 1, 8, 2}, 1 + 7 + 2},
+		{"reset at the end", []float64{1, 9, 2}, 8 + 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := counterIncrease(counterPoints(tt.values...)); got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCounterValue(t *testing.T) {
+	tests := []struct {
+		name    string
+		fn      pb.Function
+		values  []float64
+		seconds float64
+		want    float64
+		ok      bool
+	}{
+		{"increase", pb.Function_FUNCTION_INCREASE, []float64{1, 3, 6}, 2, 5, true},
+		{"increase with reset", pb.Function_FUNCTION_INCREASE, []float64{8, 2, 4}, 2, 4, true},
+		{"increase single point", pb.Function_FUNCTION_INCREASE, []float64{8}, 2, 0, false},
+		{"rate", pb.Function_FUNCTION_RATE, []float64{0, 10, 20}, 4, 5, true},
+		{"rate with reset", pb.Function_FUNCTION_RATE, []float64{10, 2, 6}, 2, 3, true},
+		{"rate without duration", pb.Function_FUNCTION_RATE, []float64{0, 10}, 0, 0, false},
+		{"irate uses last two points", pb.Function_FUNCTION_IRATE, []float64{0, 100, 103}, 10, 3, true},
+		{"irate with reset", pb.Function_FUNCTION_IRATE, []float64{0, 100, 4}, 10, 4, true},
+		{"delta", pb.Function_FUNCTION_DELTA, []float64{10, 12, 7}, 2, -3, true},
+		{"delta empty", pb.Function_FUNCTION_DELTA, nil, 2, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok, err := counterValue(tt.fn, counterPoints(tt.values...), tt.seconds, tstorage.Seconds)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ok != tt.ok || math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("got (%v, %v), want (%v, %v)", got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
+
+func TestCounterValueIrateSameTimestamp(t *testing.T) {
+	points := []*tstorage.DataPoint{{Timestamp: 1, Value: 1}, {Timestamp: 1, Value: 2}}
+	if _, ok, err := counterValue(pb.Function_FUNCTION_IRATE, points, 1, tstorage.Seconds); err != nil || ok {
+		t.Errorf("got (%v, %v), want no value", ok, err)
+	}
+}
+
+func TestCounterBucketsKeepIncreaseBetweenBuckets(t *testing.T) {
+	// The increase from 10 to 15 happens between the two buckets and must
+	// be counted in the second bucket; the reset in the second bucket is
+	// counted as the new value.
+	points := []*tstorage.DataPoint{
+		{Timestamp: 0, Value: 5},
+		{Timestamp: 9, Value: 10},
+		{Timestamp: 11, Value: 15},
+		{Timestamp: 15, Value: 2},
+	}
+	r := &rangeQuery{step: 10, function: pb.Function_FUNCTION_INCREASE, precision: tstorage.Seconds}
+	got, err := r.bucketize(points, 0, 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertDataPoints(t, got, []tstorage.DataPoint{
+		{Timestamp: 0, Value: 5},
+		{Timestamp: 10, Value: 5 + 2},
+	})
+}
@@ -0,0 +1,48 @@
+package internal
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+)
+
+// Function returns the options of our gRPC server for the transport security
+// selected with `WithTLS`, which is no security at all unless a certificate
+// was provided.
+func (s *TStorageServer) serverOptions() ([]grpc.ServerOption, error) {
+	if s.tlsCertFile == "" && s.tlsKeyFile == "" {
+		if s.tlsClientCAFile != "" {
+			return nil, errors.New("a certificate and private key are required to verify client certificates")
+		}
+		return nil, nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(s.tlsCertFile, s.tlsKeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load certificate: %w", err)
+	}
+	config := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+
+	// Require every client to present a certificate signed by our CA.
+	if s.tlsClientCAFile != "" {
+		pem, err := ioutil.ReadFile(s.tlsClientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read client CA: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %v", s.tlsClientCAFile)
+		}
+		config.ClientCAs = pool
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return []grpc.ServerOption{grpc.Creds(credentials.NewTLS(config))}, nil
+}
@@ -0,0 +1,102 @@
+package internal
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// Function will write a self-signed certificate for `localhost` and its
+// private key into the directory, and returns the paths of both files.
+func writeTestCertificate(t *testing.T, dir string) (string, string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "localhost"},
+		DNSNames:              []string{"localhost"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certFile := filepath.Join(dir, "server.crt")
+	keyFile := filepath.Join(dir, "server.key")
+	if err := ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return certFile, keyFile
+}
+
+func TestServerOptions(t *testing.T) {
+	dir, err := ioutil.TempDir("", "credentials")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	certFile, keyFile := writeTestCertificate(t, dir)
+	invalidFile := filepath.Join(dir, "invalid.pem")
+	if err := ioutil.WriteFile(invalidFile, []byte("not a certificate"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	missingFile := filepath.Join(dir, "missing.pem")
+
+	tests := []struct {
+		name         string
+		certFile     string
+		keyFile      string
+		clientCAFile string
+		want         int
+		wantErr      bool
+	}{
+		{"no TLS", "", "", "", 0, false},
+		{"TLS", certFile, keyFile, "", 1, false},
+		{"mutual TLS", certFile, keyFile, certFile, 1, false},
+		{"certificate without key", certFile, "", "", 0, true},
+		{"key without certificate", "", keyFile, "", 0, true},
+		{"client CA without certificate", "", "", certFile, 0, true},
+		{"certificate which can not be read", missingFile, keyFile, "", 0, true},
+		{"invalid key", certFile, invalidFile, "", 0, true},
+		{"client CA which can not be read", certFile, keyFile, missingFile, 0, true},
+		{"client CA without certificates", certFile, keyFile, invalidFile, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &TStorageServer{}
+			WithTLS(tt.certFile, tt.keyFile, tt.clientCAFile)(s)
+			opts, err := s.serverOptions()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+			}
+			if len(opts) != tt.want {
+				t.Errorf("got %v options, want %v", len(opts), tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,61 @@
+package internal
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// durationUnits are the units, from largest to smallest, supported in
+// addition to the ones supported by `time.ParseDuration`.
+var durationUnits = []struct {
+	suffix string
+	unit   time.Duration
+}{
+	{"y", 365 * 24 * time.Hour},
+	{"w", 7 * 24 * time.Hour},
+	{"d", 24 * time.Hour},
+}
+
+// Function will parse the duration string. In addition to the formats
+// supported by `time.ParseDuration` a whole number of days (ex: `90d`),
+// weeks (ex: `2w`) or years (ex: `1y`) is accepted.
+func parseDuration(s string) (time.Duration, error) {
+	for _, u := range durationUnits {
+		if !strings.HasSuffix(s, u.suffix) {
+			continue
+		}
+		n, err := strconv.ParseInt(strings.TrimSuffix(s, u.suffix), 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration %q", s)
+		}
+		return time.Duration(n) * u.unit, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid duration %q", s)
+	}
+	return d, nil
+}
+
+// Function returns the shortest string representation of the duration using
+// a single unit, for example `5m` or `90d`.
+func formatDuration(d time.Duration) string {
+	units := []struct {
+		suffix string
+		unit   time.Duration
+	}{
+		{"d", 24 * time.Hour},
+		{"h", time.Hour},
+		{"m", time.Minute},
+		{"s", time.Second},
+		{"ms", time.Millisecond},
+	}
+	for _, u := range units {
+		if d >= u.unit && d%u.unit == 0 {
+			return fmt.Sprintf("%d%s", d/u.unit, u.suffix)
+		}
+	}
+	return d.String()
+}
@@ -0,0 +1,373 @@
+package internal
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/golang/protobuf/ptypes/empty"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+
+	pb "github.com/bartmika/tstorage-server/proto"
+)
+
+// gatewayMarshaler encodes the messages sent back by our gateway.
+var gatewayMarshaler = protojson.MarshalOptions{EmitUnpopulated: true}
+
+// Function will start our JSON gateway in the background if a port was
+// provided.
+//
+// DEVELOPERS NOTE:
+// The gateway calls the implementation of our gRPC service directly, so the
+// handlers below only convert between JSON and our protocol buffer messages
+// while authentication, tenants and validation work exactly like with gRPC.
+func (s *TStorageServer) runGatewayServer() {
+	if s.gatewayPort == 0 {
+		return
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/insert_row", s.handleGatewayInsertRow)
+	mux.HandleFunc("/v1/insert_rows", s.handleGatewayInsertRows)
+	mux.HandleFunc("/v1/select", s.handleGatewaySelect)
+
+	s.gatewayServer = &http.Server{
+		Addr:    fmt.Sprintf(":%v", s.gatewayPort),
+		Handler: mux,
+	}
+	go func() {
+		if err := s.gatewayServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Fatalf("failed to serve gateway: %v", err)
+		}
+	}()
+
+	// For debugging purposes only.
+	log.Printf("JSON gateway is running on port %v", s.gatewayPort)
+}
+
+// Function returns the context of the request for calling the method of our
+// gRPC service, once the bearer token of the request was authenticated if
+// tokens are enabled, along with the access token.
+func (s *TStorageServer) gatewayContext(r *http.Request, method string) (context.Context, *accessToken, error) {
+	ctx := httpContext(r)
+	if s.tokens == nil {
+		return ctx, nil, nil
+	}
+	return s.authenticate(ctx, "/"+pb.TStorage_ServiceDesc.ServiceName+"/"+method)
+}
+
+// Function will record the call of the method made through our gateway in
+// our metrics, like the interceptors of our gRPC server do, and returns the
+// function to call with the error the call ended with.
+func (s *TStorageServer) observeGatewayCall(method string, stream bool) func(err error) {
+	fullMethod := "/" + pb.TStorage_ServiceDesc.ServiceName + "/" + method
+	started := time.Now()
+	if stream {
+		s.metrics.addActiveStreams(fullMethod, 1)
+	}
+	return func(err error) {
+		if stream {
+			s.metrics.addActiveStreams(fullMethod, -1)
+		}
+		s.metrics.observeRequest(fullMethod, err, time.Since(started))
+	}
+}
+
+// Function will insert the single time-series datum of the JSON body.
+func (s *TStorageServer) handleGatewayInsertRow(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	done := s.observeGatewayCall("InsertRow", false)
+	res, err := s.gatewayInsertRow(w, r)
+	done(err)
+	if err != nil {
+		writeGatewayError(w, err)
+		return
+	}
+	writeGatewayMessage(w, res)
+}
+
+// Function will call `InsertRow` with the datum of the JSON body.
+func (s *TStorageServer) gatewayInsertRow(w http.ResponseWriter, r *http.Request) (*empty.Empty, error) {
+	ctx, t, err := s.gatewayContext(r, "InsertRow")
+	if err != nil {
+		return nil, err
+	}
+	datum := &pb.TimeSeriesDatum{}
+	if err := readGatewayMessage(w, r, datum); err != nil {
+		return nil, err
+	}
+	if t != nil {
+		if err := authorizeMessage(t, datum); err != nil {
+			return nil, err
+		}
+	}
+	return s.impl.InsertRow(ctx, datum)
+}
+
+// Function will insert the time-series data of the NDJSON body, with one
+// datum per line, and answer with the result of the inserts. Like the rows
+// which can not be written, the lines which can not be decoded are reported
+// in the `errors` of the response by their index among the non-empty lines.
+func (s *TStorageServer) handleGatewayInsertRows(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	done := s.observeGatewayCall("InsertRows", true)
+	res, err := s.gatewayInsertRows(w, r)
+	done(err)
+	if err != nil {
+		writeGatewayError(w, err)
+		return
+	}
+	writeGatewayMessage(w, res)
+}
+
+// Function will call `InsertRows` with the data of the NDJSON body.
+func (s *TStorageServer) gatewayInsertRows(w http.ResponseWriter, r *http.Request) (*pb.InsertRowsResponse, error) {
+	ctx, t, err := s.gatewayContext(r, "InsertRows")
+	if err != nil {
+		return nil, err
+	}
+	scanner := bufio.NewScanner(http.MaxBytesReader(w, r.Body, maxHTTPBodySize))
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	stream := &gatewayInsertRowsStream{gatewayStream: gatewayStream{ctx: ctx}, scanner: scanner, token: t}
+	if err := s.impl.InsertRows(stream); err != nil {
+		return nil, err
+	}
+	return stream.res, nil
+}
+
+// Function will select the data points of the filter in the JSON body. The
+// data points are streamed back as NDJSON, with one data point per line, or
+// as a JSON array if the client accepts `application/json`.
+func (s *TStorageServer) handleGatewaySelect(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	done := s.observeGatewayCall("Select", true)
+	stream := &gatewaySelectStream{
+		w:     w,
+		array: strings.Contains(r.Header.Get("Accept"), "application/json"),
+	}
+	err := s.gatewaySelect(w, r, stream)
+	done(err)
+	if err != nil && !stream.started {
+		writeGatewayError(w, err)
+		return
+	}
+	stream.finish(err)
+}
+
+// Function will call `Select` with the filter of the JSON body, sending the
+// data points to the stream.
+func (s *TStorageServer) gatewaySelect(w http.ResponseWriter, r *http.Request, stream *gatewaySelectStream) error {
+	ctx, t, err := s.gatewayContext(r, "Select")
+	if err != nil {
+		return err
+	}
+	filter := &pb.Filter{}
+	if err := readGatewayMessage(w, r, filter); err != nil {
+		return err
+	}
+	if t != nil {
+		if err := authorizeMessage(t, filter); err != nil {
+			return err
+		}
+	}
+	stream.ctx = ctx
+	return s.impl.Select(filter, stream)
+}
+
+// gatewayStream is the server side of a gRPC stream made of an HTTP request.
+// The methods of the embedding streams used by our service are the only ones
+// which send or receive messages.
+type gatewayStream struct {
+	ctx context.Context
+}
+
+func (g *gatewayStream) SetHeader(metadata.MD) error  { return nil }
+func (g *gatewayStream) SendHeader(metadata.MD) error { return nil }
+func (g *gatewayStream) SetTrailer(metadata.MD)       {}
+func (g *gatewayStream) Context() context.Context     { return g.ctx }
+
+func (g *gatewayStream) SendMsg(m interface{}) error {
+	return status.Error(codes.Unimplemented, "not supported by the gateway")
+}
+
+func (g *gatewayStream) RecvMsg(m interface{}) error {
+	return status.Error(codes.Unimplemented, "not supported by the gateway")
+}
+
+// gatewayInsertRowsStream receives the time-series data of the `InsertRows`
+// stream from the lines of an NDJSON body.
+//
+// DEVELOPERS NOTE:
+// The lines which can not be decoded never reach `InsertRows`, so we keep
+// track of the index every received datum has among the non-empty lines and
+// add the lines we skipped to the errors of the response before it is sent.
+type gatewayInsertRowsStream struct {
+	gatewayStream
+	scanner *bufio.Scanner
+	token   *accessToken
+	line    int
+	rows    uint64
+	indexes []uint64
+	errors  []*pb.RowError
+	res     *pb.InsertRowsResponse
+}
+
+func (g *gatewayInsertRowsStream) Recv() (*pb.TimeSeriesDatum, error) {
+	for g.scanner.Scan() {
+		g.line++
+		line := strings.TrimSpace(g.scanner.Text())
+		if line == "" {
+			continue
+		}
+		index := g.rows
+		g.rows++
+		datum := &pb.TimeSeriesDatum{}
+		if err := protojson.Unmarshal([]byte(line), datum); err != nil {
+			g.errors = append(g.errors, &pb.RowError{Index: index, Reason: fmt.Sprintf("line %v: %v", g.line, err)})
+			continue
+		}
+		if g.token != nil {
+			if err := authorizeMessage(g.token, datum); err != nil {
+				return nil, err
+			}
+		}
+		g.indexes = append(g.indexes, index)
+		return datum, nil
+	}
+	if err := g.scanner.Err(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	return nil, io.EOF
+}
+
+func (g *gatewayInsertRowsStream) SendAndClose(res *pb.InsertRowsResponse) error {
+	for _, e := range res.Errors {
+		e.Index = g.indexes[e.Index]
+	}
+	res.Errors = append(res.Errors, g.errors...)
+	res.Rejected += uint64(len(g.errors))
+	sort.Slice(res.Errors, func(i, j int) bool {
+		return res.Errors[i].Index < res.Errors[j].Index
+	})
+	g.res = res
+	return nil
+}
+
+// gatewaySelectStream sends the data points of the `Select` stream as NDJSON
+// or as a JSON array, flushing every data point to the client right away.
+type gatewaySelectStream struct {
+	gatewayStream
+	w       http.ResponseWriter
+	array   bool
+	started bool
+}
+
+func (g *gatewaySelectStream) Send(point *pb.DataPoint) error {
+	b, err := gatewayMarshaler.Marshal(point)
+	if err != nil {
+		return err
+	}
+	g.start()
+	if g.array && g.started {
+		g.w.Write([]byte(","))
+	}
+	g.started = true
+	g.w.Write(b)
+	if !g.array {
+		g.w.Write([]byte("\n"))
+	}
+	if f, ok := g.w.(http.Flusher); ok {
+		f.Flush()
+	}
+	return nil
+}
+
+// Function will write the response headers and open the JSON array before
+// the first data point.
+func (g *gatewaySelectStream) start() {
+	if g.started {
+		return
+	}
+	if g.array {
+		g.w.Header().Set("Content-Type", "application/json")
+		g.w.Write([]byte("["))
+	} else {
+		g.w.Header().Set("Content-Type", "application/x-ndjson")
+	}
+}
+
+// Function will end the response. Since the status code was already sent, an
+// error happening after the first data point is sent as the last element of
+// the response.
+func (g *gatewaySelectStream) finish(err error) {
+	g.start()
+	if err != nil {
+		if g.array && g.started {
+			g.w.Write([]byte(","))
+		}
+		fmt.Fprintf(g.w, `{"error":%q}`, status.Convert(err).Message())
+		if !g.array {
+			g.w.Write([]byte("\n"))
+		}
+	}
+	if g.array {
+		g.w.Write([]byte("]"))
+	}
+}
+
+// Function will decode the JSON body of the request into the message.
+func readGatewayMessage(w http.ResponseWriter, r *http.Request, m proto.Message) error {
+	b, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxHTTPBodySize))
+	if err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	if err := protojson.Unmarshal(b, m); err != nil {
+		return status.Errorf(codes.InvalidArgument, "failed to decode request: %v", err)
+	}
+	return nil
+}
+
+// Function will write the message encoded as JSON.
+func writeGatewayMessage(w http.ResponseWriter, m proto.Message) {
+	b, err := gatewayMarshaler.Marshal(m)
+	if err != nil {
+		writeGatewayError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(b)
+	w.Write([]byte("\n"))
+}
+
+// Function will write the error as JSON with the status code matching the
+// gRPC status code of the error.
+func writeGatewayError(w http.ResponseWriter, err error) {
+	st := status.Convert(err)
+	writeJSON(w, httpStatusCode(err), map[string]interface{}{
+		"code":    st.Code().String(),
+		"message": st.Message(),
+	})
+}
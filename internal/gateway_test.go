@@ -0,0 +1,147 @@
+package internal
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"google.golang.org/protobuf/encoding/protojson"
+
+	pb "github.com/bartmika/tstorage-server/proto"
+)
+
+func TestGatewayInsertRows(t *testing.T) {
+	tests := []struct {
+		name   string
+		body   string
+		want   *pb.InsertRowsResponse
+		stored int
+	}{
+		{"valid", `{"metric": "cpu", "value": 1, "timestamp": "2020-09-13T12:26:40Z"}
+{"metric": "cpu", "value": 2, "timestamp": "2020-09-13T12:26:41Z"}`, &pb.InsertRowsResponse{Accepted: 2}, 2},
+		{"malformed lines are rejected", `{"metric": "cpu", "value": 1, "timestamp": "2020-09-13T12:26:40Z"}
+
+{"metric": "cpu", "value":
+{"metric": "", "value": 2}
+{"metric": "cpu", "value": "abc"}
+{"metric": "cpu", "value": 3, "timestamp": "2020-09-13T12:26:42Z"}
+`, &pb.InsertRowsResponse{Accepted: 2, Rejected: 3, Errors: []*pb.RowError{
+			{Index: 1, Reason: "line 3: "},
+			{Index: 2, Reason: "metric must be set"},
+			{Index: 3, Reason: "line 5: "},
+		}}, 2},
+		{"only malformed lines", "not json\n[]", &pb.InsertRowsResponse{Rejected: 2, Errors: []*pb.RowError{
+			{Index: 0, Reason: "line 1: "},
+			{Index: 1, Reason: "line 2: "},
+		}}, 0},
+		{"empty body", "", &pb.InsertRowsResponse{}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestServer(t)
+			w := httptest.NewRecorder()
+			s.handleGatewayInsertRows(w, httptest.NewRequest(http.MethodPost, "/v1/insert_rows", strings.NewReader(tt.body)))
+			if w.Code != http.StatusOK {
+				t.Fatalf("got status %v, want %v: %v", w.Code, http.StatusOK, w.Body.String())
+			}
+			got := &pb.InsertRowsResponse{}
+			if err := protojson.Unmarshal(w.Body.Bytes(), got); err != nil {
+				t.Fatal(err)
+			}
+			if got.Accepted != tt.want.Accepted || got.Rejected != tt.want.Rejected || len(got.Errors) != len(tt.want.Errors) {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+			for i, want := range tt.want.Errors {
+				if got.Errors[i].Index != want.Index || !strings.HasPrefix(got.Errors[i].Reason, want.Reason) {
+					t.Errorf("error %v: got %v, want %v", i, got.Errors[i], want)
+				}
+			}
+			if n := len(selectTestPoints(t, s, "", "cpu")); n != tt.stored {
+				t.Errorf("got %v stored data points, want %v", n, tt.stored)
+			}
+		})
+	}
+}
+
+func TestGatewaySelect(t *testing.T) {
+	s := newTestServer(t)
+	w := httptest.NewRecorder()
+	s.handleGatewayInsertRows(w, httptest.NewRequest(http.MethodPost, "/v1/insert_rows", strings.NewReader(`{"metric": "cpu", "value": 1, "timestamp": "2020-09-13T12:26:40Z"}
+{"metric": "cpu", "value": 2, "timestamp": "2020-09-13T12:26:41Z"}`)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("got status %v: %v", w.Code, w.Body.String())
+	}
+
+	filter := `{"metric": "cpu", "start": "2020-09-13T12:26:40Z", "end": "2020-09-13T12:26:42Z"}`
+	tests := []struct {
+		name   string
+		accept string
+		body   string
+		want   int
+		output string
+	}{
+		{"ndjson", "", filter, http.StatusOK, `{"value":1,"timestamp":"2020-09-13T12:26:40Z"}` + "\n" + `{"value":2,"timestamp":"2020-09-13T12:26:41Z"}` + "\n"},
+		{"array", "application/json", filter, http.StatusOK, `[{"value":1,"timestamp":"2020-09-13T12:26:40Z"},{"value":2,"timestamp":"2020-09-13T12:26:41Z"}]`},
+		{"empty array", "application/json", `{"metric": "mem", "start": "2020-09-13T12:26:40Z", "end": "2020-09-13T12:26:42Z"}`, http.StatusOK, `[]`},
+		{"invalid filter", "", `{"metric": 1}`, http.StatusBadRequest, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/v1/select", strings.NewReader(tt.body))
+			if tt.accept != "" {
+				r.Header.Set("Accept", tt.accept)
+			}
+			w := httptest.NewRecorder()
+			s.handleGatewaySelect(w, r)
+			if w.Code != tt.want {
+				t.Fatalf("got status %v, want %v: %v", w.Code, tt.want, w.Body.String())
+			}
+			if tt.output != "" && strings.ReplaceAll(w.Body.String(), " ", "") != tt.output {
+				t.Errorf("got %v, want %v", w.Body.String(), tt.output)
+			}
+		})
+	}
+}
+
+func TestGatewayMetrics(t *testing.T) {
+	s := newTestServer(t)
+	calls := []struct {
+		handler http.HandlerFunc
+		method  string
+		body    string
+	}{
+		{s.handleGatewayInsertRow, http.MethodPost, `{"metric": "cpu", "value": 1}`},
+		{s.handleGatewayInsertRow, http.MethodPost, `{"metric": ""}`},
+		{s.handleGatewayInsertRow, http.MethodGet, ""},
+		{s.handleGatewayInsertRows, http.MethodPost, "not json"},
+		{s.handleGatewaySelect, http.MethodPost, `{"metric": "cpu"}`},
+	}
+	for _, c := range calls {
+		c.handler(httptest.NewRecorder(), httptest.NewRequest(c.method, "/", strings.NewReader(c.body)))
+	}
+
+	tests := []struct {
+		method string
+		code   string
+		want   uint64
+	}{
+		{"InsertRow", "OK", 1},
+		{"InsertRow", "InvalidArgument", 1},
+		{"InsertRows", "OK", 1},
+		{"Select", "OK", 1},
+	}
+	for _, tt := range tests {
+		if got := s.metrics.requests[requestKey{method: tt.method, code: tt.code}]; got != tt.want {
+			t.Errorf("%v %v: got %v requests, want %v", tt.method, tt.code, got, tt.want)
+		}
+	}
+	if got := s.metrics.durations["InsertRow"].count; got != 2 {
+		t.Errorf("got %v observed InsertRow durations, want 2", got)
+	}
+	for _, method := range []string{"InsertRows", "Select"} {
+		if got, ok := s.metrics.activeStreams[method]; !ok || got != 0 {
+			t.Errorf("got %v active %v streams, want 0", got, method)
+		}
+	}
+}
@@ -0,0 +1,337 @@
+package internal
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nakabonne/tstorage"
+)
+
+// maxPickleSize is the largest pickled message, in bytes, accepted by the
+// Graphite pickle listener.
+const maxPickleSize = 16 << 20
+
+// graphiteTemplate maps the dotted path of a Graphite metric to a metric name
+// and labels.
+//
+// DEVELOPERS NOTE:
+// Templates follow the syntax of the Graphite templates of InfluxDB and are
+// written as `[filter] template [label=value,...]`. Every part of the template
+// names what the part of the path at the same position is used for:
+//
+//   - `measurement` is part of the metric name, and `measurement*` is the
+//     rest of the path.
+//   - `*`, or an empty part, is ignored.
+//   - Any other name is the label the part is stored as.
+//
+// For example the `site.*.host.measurement*` template maps the
+// `paris.servers.web1.cpu.load` path to the `cpu.load` metric with the
+// `site=paris` and `host=web1` labels. The optional filter, such as
+// `servers.*`, selects which paths the template is used for and the optional
+// labels are added to every metric mapped by the template. Paths too short to
+// contain the measurement of the template are not mapped by it.
+type graphiteTemplate struct {
+	filter []string
+	parts  []string
+	labels []tstorage.Label
+}
+
+// Function will parse the templates in the order they are used. Templates
+// with a filter are tried first, from the most to the least specific filter,
+// and the first template without a filter is used when no filter matched.
+func parseGraphiteTemplates(templates []string) ([]*graphiteTemplate, error) {
+	results := []*graphiteTemplate{}
+	for _, text := range templates {
+		fields := strings.Fields(text)
+
+		// The labels, if any, are always last and the filter, if any, is
+		// always first.
+		var labels string
+		if n := len(fields); n > 1 && strings.Contains(fields[n-1], "=") {
+			labels, fields = fields[n-1], fields[:n-1]
+		}
+		t := &graphiteTemplate{}
+		switch len(fields) {
+		case 1:
+			t.parts = strings.Split(fields[0], ".")
+		case 2:
+			t.filter = strings.Split(fields[0], ".")
+			t.parts = strings.Split(fields[1], ".")
+		default:
+			return nil, fmt.Errorf("invalid template %q", text)
+		}
+		if labels != "" {
+			for _, pair := range strings.Split(labels, ",") {
+				kv := strings.SplitN(pair, "=", 2)
+				if len(kv) != 2 || kv[0] == "" || kv[1] == "" {
+					return nil, fmt.Errorf("invalid label %q of template %q", pair, text)
+				}
+				t.labels = append(t.labels, tstorage.Label{Name: kv[0], Value: kv[1]})
+			}
+		}
+
+		hasMeasurement := false
+		for i, part := range t.parts {
+			if part == "measurement*" && i != len(t.parts)-1 {
+				return nil, fmt.Errorf("template %q may only end with measurement*", text)
+			}
+			if part == "measurement" || part == "measurement*" {
+				hasMeasurement = true
+			}
+		}
+		if !hasMeasurement {
+			return nil, fmt.Errorf("template %q has no measurement", text)
+		}
+		results = append(results, t)
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		return len(results[i].filter) > len(results[j].filter)
+	})
+	return results, nil
+}
+
+// Function returns true if the template is used for the path.
+func (t *graphiteTemplate) matches(path []string) bool {
+	if len(t.filter) > len(path) {
+		return false
+	}
+	for i, part := range t.filter {
+		if part != "*" && part != path[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Function will map the path to a metric name and labels. The empty parts of
+// the path, such as in `servers..cpu`, are skipped so they never become an
+// empty label value.
+func (t *graphiteTemplate) apply(path []string) (string, []tstorage.Label) {
+	measurement := []string{}
+	values := map[string][]string{}
+	names := []string{}
+	for i, part := range t.parts {
+		if i >= len(path) {
+			break
+		}
+		switch part {
+		case "measurement":
+			if path[i] != "" {
+				measurement = append(measurement, path[i])
+			}
+		case "measurement*":
+			for _, p := range path[i:] {
+				if p != "" {
+					measurement = append(measurement, p)
+				}
+			}
+		case "*", "":
+		default:
+			if path[i] == "" {
+				continue
+			}
+			if _, ok := values[part]; !ok {
+				names = append(names, part)
+			}
+			values[part] = append(values[part], path[i])
+		}
+	}
+
+	labels := make([]tstorage.Label, 0, len(names)+len(t.labels))
+	labels = append(labels, t.labels...)
+	for _, name := range names {
+		labels = append(labels, tstorage.Label{Name: name, Value: strings.Join(values[name], ".")})
+	}
+	return strings.Join(measurement, "."), labels
+}
+
+// Function will map the Graphite path, which may carry Graphite tags such as
+// `cpu.load;host=web1`, into a metric name and labels using the first
+// template used for the path. Without a template the path is the metric name.
+func mapGraphitePath(templates []*graphiteTemplate, path string) (string, []tstorage.Label) {
+	tags := strings.Split(path, ";")
+	path = tags[0]
+
+	metric, labels := path, []tstorage.Label{}
+	parts := strings.Split(path, ".")
+	for _, t := range templates {
+		if !t.matches(parts) {
+			continue
+		}
+		// Paths too short for the measurement of the template are left as is.
+		if m, l := t.apply(parts); m != "" {
+			metric, labels = m, l
+			break
+		}
+	}
+	for _, tag := range tags[1:] {
+		kv := strings.SplitN(tag, "=", 2)
+		if len(kv) == 2 && kv[0] != "" && kv[1] != "" {
+			labels = append(labels, tstorage.Label{Name: kv[0], Value: kv[1]})
+		}
+	}
+	return metric, labels
+}
+
+// Function will convert a Graphite data point into a row. Graphite timestamps
+// are in seconds, where a missing or negative timestamp means the current
+// time. False is returned for the values which can not be stored.
+func (s *TStorageServer) graphiteRow(path string, value float64, timestamp float64) (tstorage.Row, bool) {
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		return tstorage.Row{}, false
+	}
+	metric, labels := mapGraphitePath(s.graphiteTemplates, path)
+	if metric == "" {
+		return tstorage.Row{}, false
+	}
+	t := time.Now()
+	if timestamp >= 0 {
+		// Timestamps past the year 2262 can not be represented.
+		nanos := timestamp * float64(time.Second)
+		if nanos >= math.MaxInt64 {
+			return tstorage.Row{}, false
+		}
+		t = time.Unix(0, int64(nanos))
+	}
+	return tstorage.Row{
+		Metric:    metric,
+		Labels:    labels,
+		DataPoint: tstorage.DataPoint{Timestamp: timeToUnix(t, s.timestampPrecision), Value: value},
+	}, true
+}
+
+// Function will parse a line of the Graphite plaintext protocol, such as
+// `servers.web1.cpu.load 0.75 1465839830`.
+func (s *TStorageServer) parseGraphiteLine(line string) (tstorage.Row, bool, error) {
+	fields := strings.Fields(line)
+	if len(fields) != 2 && len(fields) != 3 {
+		return tstorage.Row{}, false, fmt.Errorf("invalid line %q", line)
+	}
+	value, err := strconv.ParseFloat(fields[1], 64)
+	if err != nil {
+		return tstorage.Row{}, false, fmt.Errorf("invalid value %q", fields[1])
+	}
+	timestamp := -1.0
+	if len(fields) == 3 && fields[2] != "N" {
+		timestamp, err = strconv.ParseFloat(fields[2], 64)
+		if err != nil {
+			return tstorage.Row{}, false, fmt.Errorf("invalid timestamp %q", fields[2])
+		}
+	}
+	row, ok := s.graphiteRow(fields[0], value, timestamp)
+	return row, ok, nil
+}
+
+// Function will read the lines of the Graphite plaintext protocol until the
+// end of the packet or until the connection is closed. Invalid lines are
+// logged and skipped.
+func (s *TStorageServer) readGraphiteLines(r io.Reader, name string, batcher *rowBatcher) {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		row, ok, err := s.parseGraphiteLine(line)
+		if err != nil {
+			log.Printf("%v: %v", name, err)
+			continue
+		}
+		if ok {
+			batcher.add([]tstorage.Row{row})
+		}
+	}
+}
+
+// Function will read the messages of the Graphite pickle protocol sent over
+// the connection until it is closed. Every message is the length of the
+// pickled data, as a 4 byte big-endian integer, followed by the pickled list
+// of `(path, (timestamp, value))` tuples.
+func (s *TStorageServer) readGraphitePickles(conn net.Conn, batcher *rowBatcher) {
+	r := bufio.NewReader(conn)
+	header := make([]byte, 4)
+	for {
+		if _, err := io.ReadFull(r, header); err != nil {
+			return
+		}
+		size := binary.BigEndian.Uint32(header)
+		if size > maxPickleSize {
+			log.Printf("graphite pickle: message of %v bytes is too large", size)
+			return
+		}
+		b := make([]byte, size)
+		if _, err := io.ReadFull(r, b); err != nil {
+			return
+		}
+
+		rows, err := s.parseGraphitePickle(b)
+		if err != nil {
+			log.Printf("graphite pickle: %v", err)
+			return
+		}
+		batcher.add(rows)
+	}
+}
+
+// Function will convert the pickled list of `(path, (timestamp, value))`
+// tuples into rows.
+func (s *TStorageServer) parseGraphitePickle(b []byte) ([]tstorage.Row, error) {
+	v, err := unpickle(b)
+	if err != nil {
+		return nil, err
+	}
+	list, ok := v.([]interface{})
+	if !ok {
+		return nil, errors.New("message is not a list")
+	}
+
+	rows := make([]tstorage.Row, 0, len(list))
+	for _, item := range list {
+		tuple, ok := item.([]interface{})
+		if !ok || len(tuple) != 2 {
+			return nil, errors.New("invalid data point")
+		}
+		path, ok := tuple[0].(string)
+		if !ok {
+			return nil, errors.New("invalid path")
+		}
+		point, ok := tuple[1].([]interface{})
+		if !ok || len(point) != 2 {
+			return nil, fmt.Errorf("invalid data point of %q", path)
+		}
+		timestamp, ok1 := pickleNumber(point[0])
+		value, ok2 := pickleNumber(point[1])
+		if !ok1 || !ok2 {
+			return nil, fmt.Errorf("invalid data point of %q", path)
+		}
+		if row, ok := s.graphiteRow(path, value, timestamp); ok {
+			rows = append(rows, row)
+		}
+	}
+	return rows, nil
+}
+
+// Function returns the unpickled integer, float or numeric string as a float.
+func pickleNumber(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case int64:
+		return float64(n), true
+	case float64:
+		return n, true
+	case string:
+		f, err := strconv.ParseFloat(n, 64)
+		return f, err == nil
+	}
+	return 0, false
+}
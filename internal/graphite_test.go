@@ -0,0 +1,181 @@
+package internal
+
+import (
+	"testing"
+	"time"
+
+	"github.com/nakabonne/tstorage"
+)
+
+func TestParseGraphiteTemplates(t *testing.T) {
+	tests := []struct {
+		name      string
+		templates []string
+		filters   []int
+		wantErr   bool
+	}{
+		{"without filter", []string{"host.measurement*"}, []int{0}, false},
+		{"most specific filter first", []string{"measurement", "a.* host.measurement", "a.b.* host.measurement"}, []int{3, 2, 0}, false},
+		{"labels", []string{"a.* host.measurement env=prod,dc=eu"}, []int{2}, false},
+		{"too many fields", []string{"a.* host.measurement env=prod extra"}, nil, true},
+		{"no measurement", []string{"host.field"}, nil, true},
+		{"measurement* not last", []string{"measurement*.host"}, nil, true},
+		{"invalid label", []string{"host.measurement env="}, nil, true},
+		{"label without name", []string{"host.measurement =prod"}, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseGraphiteTemplates(tt.templates)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+			}
+			if len(got) != len(tt.filters) {
+				t.Fatalf("got %v templates, want %v", len(got), len(tt.filters))
+			}
+			for i, n := range tt.filters {
+				if len(got[i].filter) != n {
+					t.Errorf("template %v: got filter %v, want %v parts", i, got[i].filter, n)
+				}
+			}
+		})
+	}
+}
+
+func TestMapGraphitePath(t *testing.T) {
+	templates, err := parseGraphiteTemplates([]string{
+		"site.*.host.measurement*",
+		"measurement",
+		"servers.* .host.measurement* env=prod",
+		"servers.db.* ..host.role.measurement",
+		"dc.* ..host.host.measurement",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name       string
+		templates  []*graphiteTemplate
+		path       string
+		wantMetric string
+		wantLabels []tstorage.Label
+	}{
+		{"filter", templates, "servers.web1.cpu.load", "cpu.load", []tstorage.Label{{Name: "env", Value: "prod"}, {Name: "host", Value: "web1"}}},
+		{"most specific filter", templates, "servers.db.pg1.primary.qps", "qps", []tstorage.Label{{Name: "host", Value: "pg1"}, {Name: "role", Value: "primary"}}},
+		{"parts after the measurement are ignored", templates, "servers.db.pg1.primary.qps.extra", "qps", []tstorage.Label{{Name: "host", Value: "pg1"}, {Name: "role", Value: "primary"}}},
+		{"repeated label", templates, "dc.eu.rack1.web1.cpu", "cpu", []tstorage.Label{{Name: "host", Value: "rack1.web1"}}},
+		{"first template without filter", templates, "paris.servers.web1.cpu.load", "cpu.load", []tstorage.Label{{Name: "site", Value: "paris"}, {Name: "host", Value: "web1"}}},
+		{"path too short for the template", templates, "paris.servers", "paris", []tstorage.Label{}},
+		{"path shorter than the filter", templates, "servers", "servers", []tstorage.Label{}},
+		{"filter matching a too short path", templates, "servers.web1", "servers", []tstorage.Label{}},
+		{"empty label value", templates, "servers..cpu.load", "cpu.load", []tstorage.Label{{Name: "env", Value: "prod"}}},
+		{"empty measurement parts", templates, "servers.web1.cpu..load.", "cpu.load", []tstorage.Label{{Name: "env", Value: "prod"}, {Name: "host", Value: "web1"}}},
+		{"empty part of a repeated label", templates, "dc.eu..web1.cpu", "cpu", []tstorage.Label{{Name: "host", Value: "web1"}}},
+		{"empty measurement uses the next template", templates, "servers.db.pg1.primary.", "pg1.primary", []tstorage.Label{{Name: "env", Value: "prod"}, {Name: "host", Value: "db"}}},
+		{"tags", templates, "servers.web1.cpu;dc=eu;invalid;=x;y=", "cpu", []tstorage.Label{{Name: "env", Value: "prod"}, {Name: "host", Value: "web1"}, {Name: "dc", Value: "eu"}}},
+		{"without templates", nil, "servers.web1.cpu;dc=eu", "servers.web1.cpu", []tstorage.Label{{Name: "dc", Value: "eu"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			metric, labels := mapGraphitePath(tt.templates, tt.path)
+			if metric != tt.wantMetric {
+				t.Errorf("got metric %q, want %q", metric, tt.wantMetric)
+			}
+			if seriesKey(labels) != seriesKey(tt.wantLabels) {
+				t.Errorf("got labels %v, want %v", labels, tt.wantLabels)
+			}
+		})
+	}
+}
+
+func TestParseGraphiteLine(t *testing.T) {
+	s := &TStorageServer{timestampPrecision: tstorage.Seconds}
+	tests := []struct {
+		name    string
+		line    string
+		want    []tstorage.Row
+		wantErr bool
+	}{
+		{"with timestamp", "servers.web1.cpu 0.75 1465839830", []tstorage.Row{
+			{Metric: "servers.web1.cpu", Labels: []tstorage.Label{}, DataPoint: tstorage.DataPoint{Timestamp: 1465839830, Value: 0.75}},
+		}, false},
+		{"fractional timestamp", "cpu 1 1465839830.9", []tstorage.Row{
+			{Metric: "cpu", Labels: []tstorage.Label{}, DataPoint: tstorage.DataPoint{Timestamp: 1465839830, Value: 1}},
+		}, false},
+		{"tags", "cpu;host=web1 -2e3 1465839830", []tstorage.Row{
+			{Metric: "cpu", Labels: []tstorage.Label{{Name: "host", Value: "web1"}}, DataPoint: tstorage.DataPoint{Timestamp: 1465839830, Value: -2000}},
+		}, false},
+		{"NaN is skipped", "cpu NaN 1465839830", nil, false},
+		{"infinity is skipped", "cpu +Inf 1465839830", nil, false},
+		{"empty metric is skipped", ";host=web1 1 1465839830", nil, false},
+		{"timestamp overflows", "cpu 1 1e20", nil, false},
+		{"missing value", "cpu", nil, true},
+		{"too many fields", "cpu 1 1465839830 extra", nil, true},
+		{"invalid value", "cpu abc 1465839830", nil, true},
+		{"invalid timestamp", "cpu 1 abc", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			row, ok, err := s.parseGraphiteLine(tt.line)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+			}
+			var got []tstorage.Row
+			if ok {
+				got = append(got, row)
+			}
+			assertRows(t, got, tt.want)
+		})
+	}
+}
+
+func TestParseGraphiteLineWithoutTimestamp(t *testing.T) {
+	s := &TStorageServer{timestampPrecision: tstorage.Seconds}
+	for _, line := range []string{"cpu 1", "cpu 1 N", "cpu 1 -1"} {
+		t.Run(line, func(t *testing.T) {
+			before := time.Now().Unix()
+			row, ok, err := s.parseGraphiteLine(line)
+			if err != nil || !ok {
+				t.Fatalf("got %v, %v, want a row", ok, err)
+			}
+			if row.Timestamp < before || row.Timestamp > time.Now().Unix() {
+				t.Errorf("got timestamp %v, want the current time", row.Timestamp)
+			}
+		})
+	}
+}
+
+func TestParseGraphitePickle(t *testing.T) {
+	s := &TStorageServer{timestampPrecision: tstorage.Seconds}
+	tests := []struct {
+		name    string
+		pickle  []byte
+		want    []tstorage.Row
+		wantErr bool
+	}{
+		{"data points", pickleFromHex(pickleProtocol2), []tstorage.Row{
+			{Metric: "servers.web1.cpu", Labels: []tstorage.Label{}, DataPoint: tstorage.DataPoint{Timestamp: 1600000000, Value: 1.5}},
+			{Metric: "servers.web2.cpu", Labels: []tstorage.Label{{Name: "dc", Value: "eu"}}, DataPoint: tstorage.DataPoint{Timestamp: 1600000001, Value: 2}},
+		}, false},
+		{"numeric strings", pickleFromHex(pickleStrings), []tstorage.Row{
+			{Metric: "a", Labels: []tstorage.Label{}, DataPoint: tstorage.DataPoint{Timestamp: 1600000000, Value: 7}},
+		}, false},
+		{"empty list", []byte("]."), []tstorage.Row{}, false},
+		{"NaN is skipped", []byte("](X\x01\x00\x00\x00a(K\x01Fnan\ntta."), []tstorage.Row{}, false},
+		{"truncated", pickleFromHex(pickleProtocol2)[:20], nil, true},
+		{"not a list", []byte("N."), nil, true},
+		{"not a tuple", []byte("]K\x01a."), nil, true},
+		{"invalid path", []byte("](K\x01(K\x01K\x02tta."), nil, true},
+		{"invalid data point", []byte("](X\x01\x00\x00\x00a(K\x01tta."), nil, true},
+		{"invalid value", []byte("](X\x01\x00\x00\x00a(K\x01Ntta."), nil, true},
+		{"invalid numeric string", []byte("](X\x01\x00\x00\x00a(K\x01X\x01\x00\x00\x00xtta."), nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := s.parseGraphitePickle(tt.pickle)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+			}
+			assertRows(t, got, tt.want)
+		})
+	}
+}
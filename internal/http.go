@@ -0,0 +1,118 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// maxHTTPBodySize is the largest request body, in bytes, accepted by our HTTP
+// endpoints.
+const maxHTTPBodySize = 32 << 20
+
+// Function will start our HTTP server in the background if a port was
+// provided.
+func (s *TStorageServer) runHTTPServer() {
+	if s.httpPort == 0 {
+		return
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", s.handleMetrics)
+	mux.HandleFunc("/api/v1/write", s.handleRemoteWrite)
+	mux.HandleFunc("/api/v1/read", s.handleRemoteRead)
+	mux.HandleFunc("/api/v1/query", s.handlePromQuery)
+	mux.HandleFunc("/api/v1/query_range", s.handlePromQueryRange)
+	mux.HandleFunc("/api/v1/series", s.handlePromSeries)
+	mux.HandleFunc("/api/v1/labels", s.handlePromLabels)
+	mux.HandleFunc("/api/v1/label/", s.handlePromLabelValues)
+	mux.HandleFunc("/api/v2/write", s.handleInfluxWrite)
+	mux.HandleFunc("/api/put", s.handleOpenTSDBPut)
+	mux.HandleFunc("/api/query", s.handleOpenTSDBQuery)
+
+	s.httpServer = &http.Server{
+		Addr:    fmt.Sprintf(":%v", s.httpPort),
+		Handler: mux,
+	}
+	go func() {
+		if err := s.httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Fatalf("failed to serve HTTP: %v", err)
+		}
+	}()
+
+	// For debugging purposes only.
+	log.Printf("HTTP server is running on port %v", s.httpPort)
+}
+
+// Function returns the implementation of our gRPC service for the tenant of
+// the HTTP request, once the bearer token of the request was authenticated
+// for the scope, along with the access token, if tokens are enabled, and the
+// function to call once the request is done.
+//
+// DEVELOPERS NOTE:
+// The `Authorization` and `X-Tenant-Id` headers are passed as the metadata of
+// a gRPC request would be, so HTTP requests are authenticated and resolved to
+// a tenant exactly like gRPC requests. The `Token <token>` authorization used
+// by InfluxDB clients is accepted as a bearer token.
+func (s *TStorageServer) httpTenant(r *http.Request, scope string) (*TStorageServerImpl, *accessToken, func(), error) {
+	ctx := httpContext(r)
+	var t *accessToken
+	if s.tokens != nil {
+		var err error
+		ctx, t, err = s.authenticateScope(ctx, scope)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+	}
+
+	impl, release, err := s.impl.forTenant(ctx)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return impl, t, release, nil
+}
+
+// Function returns the context of the HTTP request with the `Authorization`
+// and `X-Tenant-Id` headers attached as incoming gRPC metadata.
+func httpContext(r *http.Request) context.Context {
+	md := metadata.MD{}
+	if v := r.Header.Get("Authorization"); strings.HasPrefix(v, "Token ") {
+		md.Set("authorization", "Bearer "+strings.TrimPrefix(v, "Token "))
+	} else if v != "" {
+		md.Set("authorization", v)
+	}
+	if v := r.Header.Get(tenantMetadataKey); v != "" {
+		md.Set(tenantMetadataKey, v)
+	}
+	return metadata.NewIncomingContext(r.Context(), md)
+}
+
+// Function will write the error to the HTTP response with the status code
+// matching the gRPC status code of the error.
+func writeHTTPError(w http.ResponseWriter, err error) {
+	http.Error(w, status.Convert(err).Message(), httpStatusCode(err))
+}
+
+// Function returns the HTTP status code matching the gRPC status code of the
+// error.
+func httpStatusCode(err error) int {
+	switch status.Code(err) {
+	case codes.InvalidArgument:
+		return http.StatusBadRequest
+	case codes.Unauthenticated:
+		return http.StatusUnauthorized
+	case codes.PermissionDenied:
+		return http.StatusForbidden
+	case codes.NotFound:
+		return http.StatusNotFound
+	case codes.Unavailable:
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusInternalServerError
+}
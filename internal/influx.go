@@ -0,0 +1,303 @@
+package internal
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nakabonne/tstorage"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// influxDefaultPrecision is the precision of the timestamps of the InfluxDB
+// line protocol when none is given.
+const influxDefaultPrecision = time.Nanosecond
+
+// Function will write the lines of an InfluxDB line protocol write request
+// into the storage of the tenant of the request, which is compatible with the
+// `/api/v2/write` endpoint of InfluxDB used by Telegraf.
+//
+// DEVELOPERS NOTE:
+// The `org` and `bucket` parameters are ignored, the tenant is selected with
+// the `X-Tenant-Id` header like with every other HTTP request. InfluxDB
+// clients send their token as `Authorization: Token <token>` which we accept
+// as well.
+func (s *TStorageServer) handleInfluxWrite(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	impl, t, release, err := s.httpTenant(r, scopeWrite)
+	if err != nil {
+		writeHTTPError(w, err)
+		return
+	}
+	defer release()
+
+	precision, err := influxPrecision(r.URL.Query().Get("precision"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var body io.Reader = http.MaxBytesReader(w, r.Body, maxHTTPBodySize)
+	if r.Header.Get("Content-Encoding") == "gzip" {
+		gz, err := gzip.NewReader(body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		defer gz.Close()
+		body = gz
+	}
+	b, err := ioutil.ReadAll(body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// DEVELOPERS NOTE:
+	// Like InfluxDB we write nothing if any of the lines is invalid so the
+	// client can fix the request and send it again.
+	rows, err := parseInfluxLines(b, precision, impl.timestampPrecision)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if t != nil {
+		metrics := make([]string, 0, len(rows))
+		for _, row := range rows {
+			metrics = append(metrics, row.Metric)
+		}
+		if err := authorizeMetrics(t, metrics); err != nil {
+			writeHTTPError(w, err)
+			return
+		}
+	}
+
+	for start := 0; start < len(rows); start += impl.insertBatchSize {
+		end := start + impl.insertBatchSize
+		if end > len(rows) {
+			end = len(rows)
+		}
+		if err := impl.insertRows(rows[start:end]); err != nil {
+			writeHTTPError(w, status.Error(codes.Internal, err.Error()))
+			return
+		}
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Function returns the duration of a single tick of the timestamps for the
+// precision of the InfluxDB line protocol. The short names of the InfluxDB 1.x
+// API are accepted as well.
+func influxPrecision(precision string) (time.Duration, error) {
+	switch precision {
+	case "", "ns", "n":
+		return time.Nanosecond, nil
+	case "us", "u":
+		return time.Microsecond, nil
+	case "ms":
+		return time.Millisecond, nil
+	case "s":
+		return time.Second, nil
+	case "m":
+		return time.Minute, nil
+	case "h":
+		return time.Hour, nil
+	}
+	return 0, fmt.Errorf("unsupported precision %q", precision)
+}
+
+// Function will parse every line of the InfluxDB line protocol where the
+// timestamps have the given precision. Lines without a timestamp use the
+// current time. The rows of the valid lines are returned along with the error
+// of the first invalid line, if any.
+func parseInfluxLines(b []byte, precision time.Duration, tsp tstorage.TimestampPrecision) ([]tstorage.Row, error) {
+	now := time.Now()
+	rows := []tstorage.Row{}
+	var firstErr error
+	scanner := bufio.NewScanner(bytes.NewReader(b))
+	scanner.Buffer(make([]byte, 0, 64*1024), maxHTTPBodySize)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		lineRows, err := parseInfluxLine(line, precision, now, tsp)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = fmt.Errorf("line %v: %w", n, err)
+			}
+			continue
+		}
+		rows = append(rows, lineRows...)
+	}
+	if err := scanner.Err(); err != nil && firstErr == nil {
+		firstErr = err
+	}
+	return rows, firstErr
+}
+
+// Function will parse a single line of the InfluxDB line protocol, such as
+// `weather,location=us-midwest temperature=82,humidity=71i 1465839830100400200`,
+// into a row for every numeric field. The metric of every row is the
+// measurement and the field joined by an underscore, such as
+// `weather_temperature`, and the tags become its labels. String fields are
+// ignored while booleans are stored as 1 and 0.
+func parseInfluxLine(line string, precision time.Duration, now time.Time, tsp tstorage.TimestampPrecision) ([]tstorage.Row, error) {
+	// Split the line into its measurement and tags, its fields and its
+	// optional timestamp.
+	end := indexUnescaped(line, ' ', false)
+	if end < 0 {
+		return nil, errors.New("missing fields")
+	}
+	key, rest := line[:end], strings.TrimLeft(line[end+1:], " ")
+	end = indexUnescaped(rest, ' ', true)
+	fieldSet, timestamp := rest, ""
+	if end >= 0 {
+		fieldSet, timestamp = rest[:end], strings.TrimSpace(rest[end+1:])
+	}
+
+	// Parse our measurement and tags.
+	parts := splitUnescaped(key, ',', false)
+	measurement := unescapeInflux(parts[0])
+	if measurement == "" {
+		return nil, errors.New("missing measurement")
+	}
+	labels := make([]tstorage.Label, 0, len(parts)-1)
+	for _, tag := range parts[1:] {
+		i := indexUnescaped(tag, '=', false)
+		if i <= 0 {
+			return nil, fmt.Errorf("invalid tag %q", tag)
+		}
+		name, value := unescapeInflux(tag[:i]), unescapeInflux(tag[i+1:])
+		if value == "" {
+			continue
+		}
+		labels = append(labels, tstorage.Label{Name: name, Value: value})
+	}
+
+	// Parse our timestamp, if there is one.
+	ts := timeToUnix(now, tsp)
+	if timestamp != "" {
+		v, err := strconv.ParseInt(timestamp, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid timestamp %q", timestamp)
+		}
+		nanos, ok := multiplyTimestamp(v, int64(precision))
+		if !ok {
+			return nil, fmt.Errorf("timestamp %q is out of range", timestamp)
+		}
+		ts = timeToUnix(time.Unix(0, nanos), tsp)
+	}
+
+	// Parse our fields.
+	rows := []tstorage.Row{}
+	for _, field := range splitUnescaped(fieldSet, ',', true) {
+		i := indexUnescaped(field, '=', false)
+		if i <= 0 {
+			return nil, fmt.Errorf("invalid field %q", field)
+		}
+		name, raw := unescapeInflux(field[:i]), field[i+1:]
+		value, ok, err := parseInfluxFieldValue(raw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value of field %q: %w", name, err)
+		}
+		if !ok {
+			continue
+		}
+		rows = append(rows, tstorage.Row{
+			Metric:    measurement + "_" + name,
+			Labels:    labels,
+			DataPoint: tstorage.DataPoint{Timestamp: ts, Value: value},
+		})
+	}
+	return rows, nil
+}
+
+// Function will parse the value of a field. False is returned for the string
+// values which can not be stored.
+func parseInfluxFieldValue(raw string) (float64, bool, error) {
+	switch raw {
+	case "t", "T", "true", "True", "TRUE":
+		return 1, true, nil
+	case "f", "F", "false", "False", "FALSE":
+		return 0, true, nil
+	case "":
+		return 0, false, errors.New("missing value")
+	}
+	switch {
+	case strings.HasPrefix(raw, `"`):
+		if len(raw) < 2 || !strings.HasSuffix(raw, `"`) {
+			return 0, false, errors.New("unterminated string")
+		}
+		return 0, false, nil
+	case strings.HasSuffix(raw, "i"):
+		v, err := strconv.ParseInt(raw[:len(raw)-1], 10, 64)
+		return float64(v), err == nil, err
+	case strings.HasSuffix(raw, "u"):
+		v, err := strconv.ParseUint(raw[:len(raw)-1], 10, 64)
+		return float64(v), err == nil, err
+	}
+	v, err := strconv.ParseFloat(raw, 64)
+	return v, err == nil, err
+}
+
+// Function returns the index of the first occurrence of the separator which
+// is not escaped with a backslash, nor inside of double quotes if `quotes` is
+// true, or -1 if there is none.
+func indexUnescaped(s string, sep byte, quotes bool) int {
+	quoted := false
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '\\':
+			i++
+		case quotes && s[i] == '"':
+			quoted = !quoted
+		case s[i] == sep && !quoted:
+			return i
+		}
+	}
+	return -1
+}
+
+// Function will split the string on every separator which is not escaped.
+func splitUnescaped(s string, sep byte, quotes bool) []string {
+	parts := []string{}
+	for {
+		i := indexUnescaped(s, sep, quotes)
+		if i < 0 {
+			return append(parts, s)
+		}
+		parts = append(parts, s[:i])
+		s = s[i+1:]
+	}
+}
+
+// Function will remove the backslashes escaping the special characters of
+// measurements, tag keys, tag values and field keys.
+func unescapeInflux(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) && strings.IndexByte(`, ="\`, s[i+1]) >= 0 {
+			i++
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
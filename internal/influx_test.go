@@ -0,0 +1,143 @@
+package internal
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/nakabonne/tstorage"
+)
+
+// Function will fail the test if the rows are not equal, ignoring the order
+// of the labels.
+func assertRows(t *testing.T, got []tstorage.Row, want []tstorage.Row) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("got %v rows, want %v: %+v", len(got), len(want), got)
+	}
+	for i := range want {
+		if got[i].Metric != want[i].Metric || got[i].DataPoint != want[i].DataPoint || seriesKey(sortedLabels(got[i].Labels)) != seriesKey(sortedLabels(want[i].Labels)) {
+			t.Errorf("row %v: got %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestParseInfluxLine(t *testing.T) {
+	now := time.Unix(1600000000, 0)
+	host := []tstorage.Label{{Name: "host", Value: "a"}}
+	tests := []struct {
+		name      string
+		line      string
+		precision time.Duration
+		want      []tstorage.Row
+		wantErr   bool
+	}{
+		{"fields and tags", "cpu,host=a usage=0.5,idle=90i 1465839830100400200", time.Nanosecond, []tstorage.Row{
+			{Metric: "cpu_usage", Labels: host, DataPoint: tstorage.DataPoint{Timestamp: 1465839830, Value: 0.5}},
+			{Metric: "cpu_idle", Labels: host, DataPoint: tstorage.DataPoint{Timestamp: 1465839830, Value: 90}},
+		}, false},
+		{"without timestamp", "cpu usage=1", time.Nanosecond, []tstorage.Row{
+			{Metric: "cpu_usage", Labels: []tstorage.Label{}, DataPoint: tstorage.DataPoint{Timestamp: 1600000000, Value: 1}},
+		}, false},
+		{"seconds precision", "cpu usage=1 1600000100", time.Second, []tstorage.Row{
+			{Metric: "cpu_usage", Labels: []tstorage.Label{}, DataPoint: tstorage.DataPoint{Timestamp: 1600000100, Value: 1}},
+		}, false},
+		{"booleans and unsigned", "door open=true,closed=F,count=3u", time.Nanosecond, []tstorage.Row{
+			{Metric: "door_open", Labels: []tstorage.Label{}, DataPoint: tstorage.DataPoint{Timestamp: 1600000000, Value: 1}},
+			{Metric: "door_closed", Labels: []tstorage.Label{}, DataPoint: tstorage.DataPoint{Timestamp: 1600000000, Value: 0}},
+			{Metric: "door_count", Labels: []tstorage.Label{}, DataPoint: tstorage.DataPoint{Timestamp: 1600000000, Value: 3}},
+		}, false},
+		{"string fields are ignored", `log,host=a message="a b, c=d",level=3i`, time.Nanosecond, []tstorage.Row{
+			{Metric: "log_level", Labels: host, DataPoint: tstorage.DataPoint{Timestamp: 1600000000, Value: 3}},
+		}, false},
+		{"escaped characters", `my\ cpu,my\,tag=a\=b us\ age=1`, time.Nanosecond, []tstorage.Row{
+			{Metric: "my cpu_us age", Labels: []tstorage.Label{{Name: "my,tag", Value: "a=b"}}, DataPoint: tstorage.DataPoint{Timestamp: 1600000000, Value: 1}},
+		}, false},
+		{"empty tag value is dropped", "cpu,host= usage=1", time.Nanosecond, []tstorage.Row{
+			{Metric: "cpu_usage", Labels: []tstorage.Label{}, DataPoint: tstorage.DataPoint{Timestamp: 1600000000, Value: 1}},
+		}, false},
+		{"missing fields", "cpu,host=a", time.Nanosecond, nil, true},
+		{"missing measurement", ",host=a usage=1", time.Nanosecond, nil, true},
+		{"invalid tag", "cpu,host usage=1", time.Nanosecond, nil, true},
+		{"invalid field", "cpu usage", time.Nanosecond, nil, true},
+		{"missing value", "cpu usage=", time.Nanosecond, nil, true},
+		{"invalid value", "cpu usage=abc", time.Nanosecond, nil, true},
+		{"invalid integer", "cpu usage=1.5i", time.Nanosecond, nil, true},
+		{"unterminated string", `cpu message="abc`, time.Nanosecond, nil, true},
+		{"invalid timestamp", "cpu usage=1 abc", time.Nanosecond, nil, true},
+		{"timestamp overflows", "cpu usage=1 9223372036854775", time.Second, nil, true},
+		{"negative timestamp overflows", "cpu usage=1 -9223372036854775", time.Second, nil, true},
+		{"largest timestamp", "cpu usage=1 9223372036", time.Second, []tstorage.Row{
+			{Metric: "cpu_usage", Labels: []tstorage.Label{}, DataPoint: tstorage.DataPoint{Timestamp: 9223372036, Value: 1}},
+		}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseInfluxLine(tt.line, tt.precision, now, tstorage.Seconds)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+			}
+			assertRows(t, got, tt.want)
+		})
+	}
+}
+
+func TestParseInfluxLines(t *testing.T) {
+	b := []byte("# comment\ncpu usage=1 1600000000\n\ncpu usage 1600000001\ncpu usage=3 1600000002\nmem free=abc\n")
+	rows, err := parseInfluxLines(b, time.Second, tstorage.Seconds)
+	if err == nil || err.Error() != `line 4: invalid field "usage"` {
+		t.Errorf("got error %v, want the error of line 4", err)
+	}
+
+	// The valid lines are still returned.
+	assertRows(t, rows, []tstorage.Row{
+		{Metric: "cpu_usage", Labels: []tstorage.Label{}, DataPoint: tstorage.DataPoint{Timestamp: 1600000000, Value: 1}},
+		{Metric: "cpu_usage", Labels: []tstorage.Label{}, DataPoint: tstorage.DataPoint{Timestamp: 1600000002, Value: 3}},
+	})
+}
+
+func TestInfluxPrecision(t *testing.T) {
+	tests := []struct {
+		precision string
+		want      time.Duration
+		wantErr   bool
+	}{
+		{"", time.Nanosecond, false},
+		{"n", time.Nanosecond, false},
+		{"us", time.Microsecond, false},
+		{"u", time.Microsecond, false},
+		{"ms", time.Millisecond, false},
+		{"s", time.Second, false},
+		{"m", time.Minute, false},
+		{"h", time.Hour, false},
+		{"d", 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.precision, func(t *testing.T) {
+			got, err := influxPrecision(tt.precision)
+			if (err != nil) != tt.wantErr || got != tt.want {
+				t.Errorf("got (%v, %v), want %v", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestMultiplyTimestamp(t *testing.T) {
+	tests := []struct {
+		v      int64
+		factor int64
+		want   int64
+		ok     bool
+	}{
+		{5, 1000, 5000, true},
+		{-5, 1000, -5000, true},
+		{math.MaxInt64, 1, math.MaxInt64, true},
+		{math.MaxInt64/1000 + 1, 1000, 0, false},
+		{math.MinInt64/1000 - 1, 1000, 0, false},
+	}
+	for _, tt := range tests {
+		if got, ok := multiplyTimestamp(tt.v, tt.factor); got != tt.want || ok != tt.ok {
+			t.Errorf("multiplyTimestamp(%v, %v) = (%v, %v), want (%v, %v)", tt.v, tt.factor, got, ok, tt.want, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package internal
+
+import (
+	"log"
+	"path/filepath"
+	"time"
+)
+
+// janitorInterval is how often the janitor looks for partitions which were
+// removed from the disk.
+const janitorInterval = 5 * time.Minute
+
+// Function will watch the partitions inside of our data path, and measure the
+// disk usage exposed by our metrics, every `janitorInterval` until the server
+// is stopped.
+//
+// DEVELOPERS NOTE:
+// The `tstorage` package removes partitions older than the retention on its
+// own but does not tell us about it; therefore the janitor compares the
+// partitions found on disk with the ones it saw the last time to log which
+// partitions were dropped and how much space was freed. Afterwards it cleans
+// up the tombstones and catalog entries which only referred to the data of
+// the dropped partitions.
+func (s *TStorageServer) runJanitor() {
+	if s.dataPath == "" {
+		return
+	}
+
+	// The partitions seen by the last run for the data path of every tenant.
+	known := map[string]map[string]*partitionInfo{}
+	sweep := func() {
+		partitions := map[string]int{}
+		s.tenants.each(func(impl *TStorageServerImpl) {
+			known[impl.dataPath] = impl.cleanup(known[impl.dataPath])
+			partitions[impl.tenant] = len(known[impl.dataPath])
+		})
+		s.metrics.setDiskUsage(partitions, dirSize(s.dataPath))
+	}
+	sweep()
+
+	ticker := time.NewTicker(janitorInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-ticker.C:
+			sweep()
+		}
+	}
+}
+
+// Function will log the partitions which were dropped since the last run and
+// clean up after them. The partitions found on disk are returned, and nothing
+// is logged if there was no previous run.
+func (s *TStorageServerImpl) cleanup(known map[string]*partitionInfo) map[string]*partitionInfo {
+	partitions, err := listPartitions(s.dataPath)
+	if err != nil {
+		log.Printf("failed to list partitions: %v", err)
+		return known
+	}
+
+	current := map[string]*partitionInfo{}
+	for _, p := range partitions {
+		current[p.name] = p
+	}
+
+	var dropped int
+	var freed int64
+	for name, p := range known {
+		if _, ok := current[name]; ok {
+			continue
+		}
+		log.Printf("Janitor: dropped partition %v freeing %v bytes", filepath.Join(s.dataPath, name), p.size)
+		dropped++
+		freed += p.size
+	}
+	if dropped == 0 {
+		return current
+	}
+	log.Printf("Janitor: dropped %v partitions freeing %v bytes in total", dropped, freed)
+
+	// Anything which ends before the oldest partition left on disk referred
+	// to data which no longer exists.
+	oldest, ok, err := oldestPartitionTimestamp(s.dataPath)
+	if err != nil || !ok {
+		return current
+	}
+	purged, err := s.tombstones.purge(oldest)
+	if err != nil {
+		log.Printf("failed to purge tombstones: %v", err)
+	} else if purged > 0 {
+		log.Printf("Janitor: purged %v tombstones of expired data", purged)
+	}
+	if expired := s.catalog.expire(oldest); expired > 0 {
+		log.Printf("Janitor: removed %v expired series from the catalog", expired)
+	}
+	return current
+}
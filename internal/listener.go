@@ -0,0 +1,191 @@
+package internal
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"net"
+	"sync"
+)
+
+// maxPacketSize is the largest UDP packet our listeners can receive.
+const maxPacketSize = 64 * 1024
+
+// Function returns the tenant the data received by our listeners is written
+// to. Our listeners do not authenticate their senders, so without
+// multi-tenancy the data goes to the single tenant and otherwise to the
+// tenant chosen when the server was started.
+func (s *TStorageServer) listenerTenant() string {
+	if !s.multiTenant {
+		return ""
+	}
+	if s.listenerTenantName == "" {
+		log.Fatalf("the tenant of the listeners must be set when multi-tenancy is enabled")
+	}
+	if err := validateTenantName(s.listenerTenantName); err != nil {
+		log.Fatalf("failed to use the tenant of the listeners: %v", err)
+	}
+	return s.listenerTenantName
+}
+
+// Function will start the listeners which were enabled in the background.
+func (s *TStorageServer) runListeners() {
+	if s.influxUDPPort != 0 {
+		batcher := s.newRowBatcher("influx udp", s.listenerTenant())
+		s.serveUDP("influx", s.influxUDPPort, func(packet []byte) {
+			rows, err := parseInfluxLines(packet, influxDefaultPrecision, s.timestampPrecision)
+			if err != nil {
+				log.Printf("influx udp: %v", err)
+			}
+			batcher.add(rows)
+		})
+		s.listeners = append(s.listeners, batcher)
+	}
+
+	if s.graphitePort != 0 || s.graphitePicklePort != 0 {
+		templates, err := parseGraphiteTemplates(s.graphiteTemplateSpecs)
+		if err != nil {
+			log.Fatalf("failed to parse graphite templates: %v", err)
+		}
+		s.graphiteTemplates = templates
+
+		batcher := s.newRowBatcher("graphite", s.listenerTenant())
+		if s.graphitePort != 0 {
+			s.serveTCP("graphite", s.graphitePort, func(conn net.Conn) {
+				s.readGraphiteLines(conn, "graphite tcp", batcher)
+			})
+			s.serveUDP("graphite", s.graphitePort, func(packet []byte) {
+				s.readGraphiteLines(bytes.NewReader(packet), "graphite udp", batcher)
+			})
+		}
+		if s.graphitePicklePort != 0 {
+			s.serveTCP("graphite pickle", s.graphitePicklePort, func(conn net.Conn) {
+				s.readGraphitePickles(conn, batcher)
+			})
+		}
+		s.listeners = append(s.listeners, batcher)
+	}
+
+	if s.statsdPort != 0 {
+		batcher := s.newRowBatcher("statsd", s.listenerTenant())
+		aggregator := s.newStatsdAggregator(batcher)
+		s.serveUDP("statsd", s.statsdPort, aggregator.handlePacket)
+		s.listeners = append(s.listeners, aggregator, batcher)
+	}
+}
+
+// Function will receive UDP packets on the port in the background and pass
+// every one of them to the handler.
+func (s *TStorageServer) serveUDP(name string, port int, handle func(packet []byte)) {
+	conn, err := net.ListenPacket("udp", fmt.Sprintf(":%v", port))
+	if err != nil {
+		log.Fatalf("failed to listen: %v", err)
+	}
+	s.listeners = append(s.listeners, conn)
+
+	go func() {
+		buf := make([]byte, maxPacketSize)
+		for {
+			n, _, err := conn.ReadFrom(buf)
+			if err != nil {
+				select {
+				case <-s.done:
+				default:
+					log.Printf("%v udp: failed to receive: %v", name, err)
+				}
+				return
+			}
+			handle(buf[:n])
+		}
+	}()
+
+	// For debugging purposes only.
+	log.Printf("%v UDP listener is running on port %v", name, port)
+}
+
+// Function will accept TCP connections on the port in the background and pass
+// every one of them to the handler, which reads from the connection until it
+// is closed.
+func (s *TStorageServer) serveTCP(name string, port int, handle func(conn net.Conn)) {
+	lis, err := net.Listen("tcp", fmt.Sprintf(":%v", port))
+	if err != nil {
+		log.Fatalf("failed to listen: %v", err)
+	}
+	l := &tcpListener{Listener: lis, conns: map[net.Conn]bool{}}
+	s.listeners = append(s.listeners, l)
+
+	go func() {
+		for {
+			conn, err := lis.Accept()
+			if err != nil {
+				select {
+				case <-s.done:
+				default:
+					log.Printf("%v tcp: failed to accept: %v", name, err)
+				}
+				return
+			}
+			if !l.track(conn) {
+				conn.Close()
+				return
+			}
+			go func() {
+				defer l.untrack(conn)
+				handle(conn)
+			}()
+		}
+	}()
+
+	// For debugging purposes only.
+	log.Printf("%v TCP listener is running on port %v", name, port)
+}
+
+// tcpListener is a TCP listener which also closes the connections it
+// accepted when closed, so no data is received once our listeners are
+// closed.
+type tcpListener struct {
+	net.Listener
+
+	mu     sync.Mutex
+	closed bool
+	conns  map[net.Conn]bool
+}
+
+// Function will keep track of the connection, returning false if the listener
+// was closed already.
+func (l *tcpListener) track(conn net.Conn) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.closed {
+		return false
+	}
+	l.conns[conn] = true
+	return true
+}
+
+// Function will close the connection and stop keeping track of it.
+func (l *tcpListener) untrack(conn net.Conn) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	conn.Close()
+	delete(l.conns, conn)
+}
+
+func (l *tcpListener) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.closed = true
+	for conn := range l.conns {
+		conn.Close()
+	}
+	return l.Listener.Close()
+}
+
+// Function will stop our listeners and write the data they buffered.
+func (s *TStorageServer) closeListeners() {
+	for _, l := range s.listeners {
+		if err := l.Close(); err != nil {
+			log.Printf("failed to close listener: %v", err)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package internal
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/nakabonne/tstorage"
+
+	pb "github.com/bartmika/tstorage-server/proto"
+)
+
+// metricNameLabel is the special label name which can be used by matchers to
+// match against the metric name of a series.
+const metricNameLabel = "__name__"
+
+// matchType is the operator used by a label matcher.
+type matchType int
+
+const (
+	matchEqual matchType = iota
+	matchNotEqual
+	matchRegexp
+	matchNotRegexp
+)
+
+// labelMatcher matches a single label of a series. A label missing from a
+// series is treated as having an empty value.
+type labelMatcher struct {
+	name  string
+	value string
+	typ   matchType
+	re    *regexp.Regexp
+}
+
+// Function will create a new label matcher and compile the regular
+// expression, if there is one. Regular expressions are fully anchored.
+func newLabelMatcher(typ matchType, name string, value string) (*labelMatcher, error) {
+	m := &labelMatcher{name: name, value: value, typ: typ}
+	if typ == matchRegexp || typ == matchNotRegexp {
+		re, err := regexp.Compile("^(?:" + value + ")$")
+		if err != nil {
+			return nil, fmt.Errorf("invalid regular expression for label %q: %w", name, err)
+		}
+		m.re = re
+	}
+	return m, nil
+}
+
+// Function returns true if the label value satisfies the matcher.
+func (m *labelMatcher) matches(value string) bool {
+	switch m.typ {
+	case matchEqual:
+		return value == m.value
+	case matchNotEqual:
+		return value != m.value
+	case matchRegexp:
+		return m.re.MatchString(value)
+	case matchNotRegexp:
+		return !m.re.MatchString(value)
+	}
+	return false
+}
+
+// Function returns true if the metric and labels of a series satisfy every
+// one of the matchers.
+func matchesAll(matchers []*labelMatcher, metric string, labels []tstorage.Label) bool {
+	for _, m := range matchers {
+		if !m.matches(labelValue(metric, labels, m.name)) {
+			return false
+		}
+	}
+	return true
+}
+
+// Function returns the value of the label with the given name or an empty
+// string if the series does not have the label.
+func labelValue(metric string, labels []tstorage.Label, name string) string {
+	if name == metricNameLabel {
+		return metric
+	}
+	for _, label := range labels {
+		if label.Name == name {
+			return label.Value
+		}
+	}
+	return ""
+}
+
+// Function will convert the protocol buffer matchers into label matchers.
+func toLabelMatchers(in []*pb.LabelMatcher) ([]*labelMatcher, error) {
+	matchers := make([]*labelMatcher, 0, len(in))
+	for _, m := range in {
+		var typ matchType
+		switch m.Type {
+		case pb.LabelMatcher_EQ:
+			typ = matchEqual
+		case pb.LabelMatcher_NEQ:
+			typ = matchNotEqual
+		case pb.LabelMatcher_RE:
+			typ = matchRegexp
+		case pb.LabelMatcher_NRE:
+			typ = matchNotRegexp
+		default:
+			return nil, fmt.Errorf("unsupported matcher type %v", m.Type)
+		}
+		matcher, err := newLabelMatcher(typ, m.Name, m.Value)
+		if err != nil {
+			return nil, err
+		}
+		matchers = append(matchers, matcher)
+	}
+	return matchers, nil
+}
@@ -0,0 +1,122 @@
+package internal
+
+import (
+	"testing"
+
+	"github.com/nakabonne/tstorage"
+
+	pb "github.com/bartmika/tstorage-server/proto"
+)
+
+func TestLabelMatcher(t *testing.T) {
+	tests := []struct {
+		name  string
+		typ   matchType
+		value string
+		input string
+		want  bool
+	}{
+		{"equal", matchEqual, "web", "web", true},
+		{"equal other", matchEqual, "web", "db", false},
+		{"equal missing", matchEqual, "", "", true},
+		{"not equal", matchNotEqual, "web", "db", true},
+		{"not equal same", matchNotEqual, "web", "web", false},
+		{"regexp", matchRegexp, "web|db", "db", true},
+		{"regexp is anchored at the start", matchRegexp, "eb", "web", false},
+		{"regexp is anchored at the end", matchRegexp, "we", "web", false},
+		{"regexp alternatives are anchored", matchRegexp, "a|b", "ab", false},
+		{"regexp wildcard", matchRegexp, "w.*", "web", true},
+		{"regexp matches missing label", matchRegexp, ".*", "", true},
+		{"not regexp", matchNotRegexp, "web|db", "cache", true},
+		{"not regexp match", matchNotRegexp, "web|db", "web", false},
+		{"not regexp is anchored", matchNotRegexp, "we", "web", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := newLabelMatcher(tt.typ, "host", tt.value)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := m.matches(tt.input); got != tt.want {
+				t.Fatalf("matches(%q) = %v, want %v", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLabelMatcherInvalidRegexp(t *testing.T) {
+	for _, typ := range []matchType{matchRegexp, matchNotRegexp} {
+		if _, err := newLabelMatcher(typ, "host", "(web"); err == nil {
+			t.Errorf("newLabelMatcher(%v) expected an error", typ)
+		}
+	}
+}
+
+func TestMatchesAll(t *testing.T) {
+	labels := []tstorage.Label{{Name: "host", Value: "web1"}, {Name: "region", Value: "eu"}}
+	mustMatcher := func(typ matchType, name string, value string) *labelMatcher {
+		m, err := newLabelMatcher(typ, name, value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return m
+	}
+	tests := []struct {
+		name     string
+		matchers []*labelMatcher
+		want     bool
+	}{
+		{"no matchers", nil, true},
+		{"metric name", []*labelMatcher{mustMatcher(matchEqual, metricNameLabel, "cpu")}, true},
+		{"metric name regexp", []*labelMatcher{mustMatcher(matchRegexp, metricNameLabel, "c.u")}, true},
+		{"all match", []*labelMatcher{mustMatcher(matchEqual, "host", "web1"), mustMatcher(matchRegexp, "region", "eu|us")}, true},
+		{"one does not match", []*labelMatcher{mustMatcher(matchEqual, "host", "web1"), mustMatcher(matchEqual, "region", "us")}, false},
+		{"missing label is empty", []*labelMatcher{mustMatcher(matchEqual, "rack", "")}, true},
+		{"missing label not equal", []*labelMatcher{mustMatcher(matchNotEqual, "rack", "")}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := matchesAll(tt.matchers, "cpu", labels); got != tt.want {
+				t.Fatalf("matchesAll() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestToLabelMatchers(t *testing.T) {
+	tests := []struct {
+		name    string
+		in      []*pb.LabelMatcher
+		want    []matchType
+		wantErr bool
+	}{
+		{"empty", nil, []matchType{}, false},
+		{"every type", []*pb.LabelMatcher{
+			{Type: pb.LabelMatcher_EQ, Name: "a", Value: "1"},
+			{Type: pb.LabelMatcher_NEQ, Name: "a", Value: "1"},
+			{Type: pb.LabelMatcher_RE, Name: "a", Value: "1"},
+			{Type: pb.LabelMatcher_NRE, Name: "a", Value: "1"},
+		}, []matchType{matchEqual, matchNotEqual, matchRegexp, matchNotRegexp}, false},
+		{"unknown type", []*pb.LabelMatcher{{Type: pb.LabelMatcher_Type(42), Name: "a"}}, nil, true},
+		{"invalid regexp", []*pb.LabelMatcher{{Type: pb.LabelMatcher_RE, Name: "a", Value: "["}}, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := toLabelMatchers(tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("toLabelMatchers() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("toLabelMatchers() returned %d matchers, want %d", len(got), len(tt.want))
+			}
+			for i, m := range got {
+				if m.typ != tt.want[i] {
+					t.Errorf("matcher %d has type %v, want %v", i, m.typ, tt.want[i])
+				}
+			}
+		})
+	}
+}
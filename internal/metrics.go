@@ -0,0 +1,235 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+// durationBuckets are the upper bounds, in seconds, of the buckets of the
+// request duration histograms.
+var durationBuckets = []float64{0.001, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// serverMetrics keeps track of the measurements about the server itself which
+// are exposed in the Prometheus text format.
+//
+// DEVELOPERS NOTE:
+// We only need a handful of counters, gauges and histograms so we write the
+// text format ourselves instead of depending on the Prometheus client library.
+type serverMetrics struct {
+	mu sync.Mutex
+
+	// The number of requests by method and status code.
+	requests map[requestKey]uint64
+
+	// The duration of the requests by method.
+	durations map[string]*histogram
+
+	// The number of streams currently open by method.
+	activeStreams map[string]int64
+
+	// The number of rows written to storage. Updated atomically.
+	rowsInserted uint64
+
+	// The number of partitions by tenant and the size of the data path, which
+	// are measured by the janitor instead of on every scrape since walking
+	// the data path gets slow with many partitions.
+	partitions   map[string]int
+	dataPathSize int64
+}
+
+// requestKey identifies the requests made to a method which ended with the
+// same status code.
+type requestKey struct {
+	method string
+	code   string
+}
+
+// histogram counts the observed values falling into every bucket of
+// `durationBuckets`.
+type histogram struct {
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+// Function will create our empty metrics.
+func newServerMetrics() *serverMetrics {
+	return &serverMetrics{
+		requests:      map[requestKey]uint64{},
+		durations:     map[string]*histogram{},
+		activeStreams: map[string]int64{},
+		partitions:    map[string]int{},
+	}
+}
+
+// Function will replace the disk usage measured by the janitor.
+func (m *serverMetrics) setDiskUsage(partitions map[string]int, dataPathSize int64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.partitions = partitions
+	m.dataPathSize = dataPathSize
+}
+
+// Function will record a finished request.
+func (m *serverMetrics) observeRequest(fullMethod string, err error, elapsed time.Duration) {
+	method := fullMethod[strings.LastIndex(fullMethod, "/")+1:]
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.requests[requestKey{method: method, code: status.Code(err).String()}]++
+	h, ok := m.durations[method]
+	if !ok {
+		h = &histogram{counts: make([]uint64, len(durationBuckets))}
+		m.durations[method] = h
+	}
+	seconds := elapsed.Seconds()
+	for i, bound := range durationBuckets {
+		if seconds <= bound {
+			h.counts[i]++
+		}
+	}
+	h.sum += seconds
+	h.count++
+}
+
+// Function will add the delta to the number of open streams of the method.
+func (m *serverMetrics) addActiveStreams(fullMethod string, delta int64) {
+	method := fullMethod[strings.LastIndex(fullMethod, "/")+1:]
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.activeStreams[method] += delta
+}
+
+// Function will record the rows written to storage.
+func (m *serverMetrics) addRowsInserted(rows int) {
+	atomic.AddUint64(&m.rowsInserted, uint64(rows))
+}
+
+// Function returns the interceptor measuring every unary call.
+func (m *serverMetrics) unaryInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		started := time.Now()
+		res, err := handler(ctx, req)
+		m.observeRequest(info.FullMethod, err, time.Since(started))
+		return res, err
+	}
+}
+
+// Function returns the interceptor measuring every streaming call.
+func (m *serverMetrics) streamInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		started := time.Now()
+		m.addActiveStreams(info.FullMethod, 1)
+		err := handler(srv, ss)
+		m.addActiveStreams(info.FullMethod, -1)
+		m.observeRequest(info.FullMethod, err, time.Since(started))
+		return err
+	}
+}
+
+// Function will write our metrics, along with the measurements of the open
+// tenants, in the Prometheus text format.
+func (s *TStorageServer) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	m := s.metrics
+
+	m.mu.Lock()
+	writeHeader(w, "tstorage_grpc_requests_total", "counter", "The number of gRPC requests handled by method and status code.")
+	keys := make([]requestKey, 0, len(m.requests))
+	for key := range m.requests {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].method != keys[j].method {
+			return keys[i].method < keys[j].method
+		}
+		return keys[i].code < keys[j].code
+	})
+	for _, key := range keys {
+		fmt.Fprintf(w, "tstorage_grpc_requests_total{method=%q,code=%q} %v\n", key.method, key.code, m.requests[key])
+	}
+
+	writeHeader(w, "tstorage_grpc_request_duration_seconds", "histogram", "The duration of the gRPC requests by method.")
+	methods := make([]string, 0, len(m.durations))
+	for method := range m.durations {
+		methods = append(methods, method)
+	}
+	sort.Strings(methods)
+	for _, method := range methods {
+		h := m.durations[method]
+		for i, bound := range durationBuckets {
+			fmt.Fprintf(w, "tstorage_grpc_request_duration_seconds_bucket{method=%q,le=\"%v\"} %v\n", method, bound, h.counts[i])
+		}
+		fmt.Fprintf(w, "tstorage_grpc_request_duration_seconds_bucket{method=%q,le=\"+Inf\"} %v\n", method, h.count)
+		fmt.Fprintf(w, "tstorage_grpc_request_duration_seconds_sum{method=%q} %v\n", method, h.sum)
+		fmt.Fprintf(w, "tstorage_grpc_request_duration_seconds_count{method=%q} %v\n", method, h.count)
+	}
+
+	writeHeader(w, "tstorage_grpc_active_streams", "gauge", "The number of gRPC streams currently open by method.")
+	methods = methods[:0]
+	for method := range m.activeStreams {
+		methods = append(methods, method)
+	}
+	sort.Strings(methods)
+	for _, method := range methods {
+		fmt.Fprintf(w, "tstorage_grpc_active_streams{method=%q} %v\n", method, m.activeStreams[method])
+	}
+	partitions := m.partitions
+	dataPathSize := m.dataPathSize
+	m.mu.Unlock()
+
+	writeHeader(w, "tstorage_rows_inserted_total", "counter", "The number of rows written to storage, use rate() to get the rows inserted per second.")
+	fmt.Fprintf(w, "tstorage_rows_inserted_total %v\n", atomic.LoadUint64(&m.rowsInserted))
+
+	// DEVELOPERS NOTE:
+	// Only the tenants which are open are included since the others are not
+	// loaded in memory. The disk usage is the one measured by the last run of
+	// the janitor.
+	type tenantStats struct {
+		name   string
+		series int
+	}
+	stats := []tenantStats{}
+	s.tenants.each(func(impl *TStorageServerImpl) {
+		stats = append(stats, tenantStats{name: impl.tenant, series: impl.catalog.size()})
+	})
+	sort.Slice(stats, func(i, j int) bool {
+		return stats[i].name < stats[j].name
+	})
+	tenants := make([]string, 0, len(partitions))
+	for name := range partitions {
+		tenants = append(tenants, name)
+	}
+	sort.Strings(tenants)
+
+	writeHeader(w, "tstorage_series", "gauge", "The number of series stored by tenant.")
+	for _, st := range stats {
+		fmt.Fprintf(w, "tstorage_series{tenant=%q} %v\n", st.name, st.series)
+	}
+	writeHeader(w, "tstorage_partitions", "gauge", "The number of partitions written to disk by tenant.")
+	for _, name := range tenants {
+		fmt.Fprintf(w, "tstorage_partitions{tenant=%q} %v\n", name, partitions[name])
+	}
+	if s.dataPath != "" {
+		writeHeader(w, "tstorage_data_path_size_bytes", "gauge", "The number of bytes used on disk by the data path.")
+		fmt.Fprintf(w, "tstorage_data_path_size_bytes %v\n", dataPathSize)
+	}
+}
+
+// Function will write the help and type lines of the metric.
+func writeHeader(w io.Writer, name string, typ string, help string) {
+	fmt.Fprintf(w, "# HELP %v %v\n# TYPE %v %v\n", name, help, name, typ)
+}
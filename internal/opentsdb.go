@@ -0,0 +1,709 @@
+package internal
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang/protobuf/ptypes"
+	"github.com/nakabonne/tstorage"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/bartmika/tstorage-server/proto"
+)
+
+// openTSDBDatum is a data point sent to the OpenTSDB `/api/put` endpoint.
+// The timestamp is in seconds, or in milliseconds if it has more than ten
+// digits.
+type openTSDBDatum struct {
+	Metric    string            `json:"metric"`
+	Timestamp json.Number       `json:"timestamp"`
+	Value     json.Number       `json:"value"`
+	Tags      map[string]string `json:"tags"`
+}
+
+// openTSDBPutError is the error of a data point which could not be written.
+type openTSDBPutError struct {
+	Datum *openTSDBDatum `json:"datapoint"`
+	Error string         `json:"error"`
+}
+
+// openTSDBPutResponse is the summary of an `/api/put` request.
+type openTSDBPutResponse struct {
+	Success int                 `json:"success"`
+	Failed  int                 `json:"failed"`
+	Errors  []*openTSDBPutError `json:"errors,omitempty"`
+}
+
+// openTSDBQueryRequest is the body of a POST request to the OpenTSDB
+// `/api/query` endpoint. The start and end are either a timestamp or a
+// relative time such as `1h-ago`.
+type openTSDBQueryRequest struct {
+	Start        interface{}         `json:"start"`
+	End          interface{}         `json:"end"`
+	Queries      []*openTSDBSubQuery `json:"queries"`
+	MsResolution bool                `json:"msResolution"`
+}
+
+// openTSDBSubQuery selects the series of a metric and how they are
+// downsampled and aggregated together.
+type openTSDBSubQuery struct {
+	Aggregator string            `json:"aggregator"`
+	Metric     string            `json:"metric"`
+	Downsample string            `json:"downsample"`
+	Tags       map[string]string `json:"tags"`
+	Filters    []*openTSDBFilter `json:"filters"`
+}
+
+// openTSDBFilter filters the series of a sub query by the value of a tag.
+type openTSDBFilter struct {
+	Type    string `json:"type"`
+	Tagk    string `json:"tagk"`
+	Filter  string `json:"filter"`
+	GroupBy bool   `json:"groupBy"`
+}
+
+// openTSDBResult is a series returned by the `/api/query` endpoint, where
+// `tags` are the tags shared by every aggregated series and `aggregateTags`
+// the tags whose values differ between the aggregated series.
+type openTSDBResult struct {
+	Metric        string            `json:"metric"`
+	Tags          map[string]string `json:"tags"`
+	AggregateTags []string          `json:"aggregateTags"`
+	DPS           openTSDBPoints    `json:"dps"`
+}
+
+// openTSDBPoints are the data points of a result, which are encoded as an
+// object from the timestamp to the value ordered by timestamp.
+type openTSDBPoints []*tstorage.DataPoint
+
+func (p openTSDBPoints) MarshalJSON() ([]byte, error) {
+	var b bytes.Buffer
+	b.WriteByte('{')
+	for i, point := range p {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		fmt.Fprintf(&b, "%q:", strconv.FormatInt(point.Timestamp, 10))
+		if math.IsNaN(point.Value) || math.IsInf(point.Value, 0) {
+			b.WriteString("null")
+		} else {
+			b.WriteString(strconv.FormatFloat(point.Value, 'g', -1, 64))
+		}
+	}
+	b.WriteByte('}')
+	return b.Bytes(), nil
+}
+
+// Function will write the data points of an OpenTSDB `/api/put` request into
+// the storage of the tenant of the request. The body is a single data point
+// or a list of data points.
+//
+// DEVELOPERS NOTE:
+// Like OpenTSDB we write the valid data points even if some are invalid, and
+// tell the client how many were written with the `summary` parameter and
+// which failed with the `details` parameter.
+func (s *TStorageServer) handleOpenTSDBPut(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		writeOpenTSDBError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	impl, t, release, err := s.httpTenant(r, scopeWrite)
+	if err != nil {
+		writeOpenTSDBError(w, httpStatusCode(err), status.Convert(err).Message())
+		return
+	}
+	defer release()
+
+	b, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxHTTPBodySize))
+	if err != nil {
+		writeOpenTSDBError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	data := []*openTSDBDatum{}
+	if b = bytes.TrimSpace(b); bytes.HasPrefix(b, []byte("[")) {
+		err = json.Unmarshal(b, &data)
+	} else {
+		datum := &openTSDBDatum{}
+		err = json.Unmarshal(b, datum)
+		data = append(data, datum)
+	}
+	if err != nil {
+		writeOpenTSDBError(w, http.StatusBadRequest, "failed to decode request: "+err.Error())
+		return
+	}
+
+	// Convert our data points, keeping track of the ones which are invalid.
+	res := &openTSDBPutResponse{}
+	rows := make([]tstorage.Row, 0, len(data))
+	metrics := make([]string, 0, len(data))
+	for _, datum := range data {
+		row, err := impl.fromOpenTSDBDatum(datum)
+		if err != nil {
+			res.Errors = append(res.Errors, &openTSDBPutError{Datum: datum, Error: err.Error()})
+			continue
+		}
+		rows = append(rows, row)
+		metrics = append(metrics, row.Metric)
+	}
+	if t != nil {
+		if err := authorizeMetrics(t, metrics); err != nil {
+			writeOpenTSDBError(w, httpStatusCode(err), status.Convert(err).Message())
+			return
+		}
+	}
+
+	for start := 0; start < len(rows); start += impl.insertBatchSize {
+		end := start + impl.insertBatchSize
+		if end > len(rows) {
+			end = len(rows)
+		}
+		if err := impl.insertRows(rows[start:end]); err != nil {
+			writeOpenTSDBError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+	}
+	res.Success, res.Failed = len(rows), len(res.Errors)
+
+	query := r.URL.Query()
+	_, details := query["details"]
+	_, summary := query["summary"]
+	code := http.StatusNoContent
+	if res.Failed > 0 {
+		code = http.StatusBadRequest
+	} else if details || summary {
+		code = http.StatusOK
+	}
+	if !details {
+		res.Errors = nil
+	}
+	if code == http.StatusNoContent {
+		w.WriteHeader(code)
+		return
+	}
+	if !details && !summary {
+		writeOpenTSDBError(w, code, fmt.Sprintf("%v data points had errors, append details to the request to see them", res.Failed))
+		return
+	}
+	writeJSON(w, code, res)
+}
+
+// Function will validate the OpenTSDB data point and convert it into the row
+// format used by `tstorage` like the data points sent with `InsertRow`.
+func (s *TStorageServerImpl) fromOpenTSDBDatum(datum *openTSDBDatum) (tstorage.Row, error) {
+	value, err := datum.Value.Float64()
+	if err != nil {
+		return tstorage.Row{}, fmt.Errorf("invalid value %q", datum.Value)
+	}
+	v, err := datum.Timestamp.Int64()
+	if err != nil || v <= 0 {
+		return tstorage.Row{}, fmt.Errorf("invalid timestamp %q", datum.Timestamp)
+	}
+	t, ok := openTSDBTimestamp(v)
+	if !ok {
+		return tstorage.Row{}, fmt.Errorf("timestamp %q is out of range", datum.Timestamp)
+	}
+	ts, err := ptypes.TimestampProto(t)
+	if err != nil {
+		return tstorage.Row{}, err
+	}
+
+	labels := make([]*pb.Label, 0, len(datum.Tags))
+	for name, value := range datum.Tags {
+		labels = append(labels, &pb.Label{Name: name, Value: value})
+	}
+	return s.toRow(&pb.TimeSeriesDatum{
+		Metric:    datum.Metric,
+		Labels:    labels,
+		Value:     value,
+		Timestamp: ts,
+	})
+}
+
+// Function returns the time of the OpenTSDB timestamp, which is in seconds or
+// in milliseconds if it has more than ten digits, and false if the timestamp
+// is too large to be represented.
+func openTSDBTimestamp(v int64) (time.Time, bool) {
+	if v > 9999999999 {
+		nanos, ok := multiplyTimestamp(v, int64(time.Millisecond))
+		return time.Unix(0, nanos), ok
+	}
+	return time.Unix(v, 0), true
+}
+
+// Function will answer an OpenTSDB `/api/query` request, either a GET request
+// with the `start`, `end` and `m` parameters or a POST request with a JSON
+// body.
+func (s *TStorageServer) handleOpenTSDBQuery(w http.ResponseWriter, r *http.Request) {
+	impl, t, release, err := s.httpTenant(r, scopeRead)
+	if err != nil {
+		writeOpenTSDBError(w, httpStatusCode(err), status.Convert(err).Message())
+		return
+	}
+	defer release()
+
+	req := &openTSDBQueryRequest{}
+	switch r.Method {
+	case http.MethodGet:
+		query := r.URL.Query()
+		req.Start, req.End = query.Get("start"), query.Get("end")
+		_, ms := query["ms"]
+		req.MsResolution = ms || query.Get("msResolution") == "true"
+		for _, m := range query["m"] {
+			sub, err := parseOpenTSDBSubQuery(m)
+			if err != nil {
+				writeOpenTSDBError(w, http.StatusBadRequest, err.Error())
+				return
+			}
+			req.Queries = append(req.Queries, sub)
+		}
+	case http.MethodPost:
+		b, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxHTTPBodySize))
+		if err == nil {
+			err = json.Unmarshal(b, req)
+		}
+		if err != nil {
+			writeOpenTSDBError(w, http.StatusBadRequest, "failed to decode request: "+err.Error())
+			return
+		}
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		writeOpenTSDBError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	results, err := impl.openTSDBQuery(req, t)
+	if err != nil {
+		writeOpenTSDBError(w, httpStatusCode(err), status.Convert(err).Message())
+		return
+	}
+	writeJSON(w, http.StatusOK, results)
+}
+
+// Function will run every sub query of the request.
+func (s *TStorageServerImpl) openTSDBQuery(req *openTSDBQueryRequest, t *accessToken) ([]*openTSDBResult, error) {
+	now := time.Now()
+	if req.Start == nil || req.Start == "" {
+		return nil, status.Error(codes.InvalidArgument, "start must be set")
+	}
+	start, err := parseOpenTSDBTime(req.Start, now)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	end := now
+	if req.End != nil && req.End != "" {
+		if end, err = parseOpenTSDBTime(req.End, now); err != nil {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+	}
+	if !start.Before(end) {
+		return nil, status.Error(codes.InvalidArgument, "start must be before end")
+	}
+	if len(req.Queries) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "at least one query must be set")
+	}
+
+	results := []*openTSDBResult{}
+	for _, sub := range req.Queries {
+		if t != nil {
+			if err := authorizeMetrics(t, []string{sub.Metric}); err != nil {
+				return nil, err
+			}
+		}
+		subResults, err := s.openTSDBSubQuery(sub, timeToUnix(start, s.timestampPrecision), timeToUnix(end, s.timestampPrecision)+1, req.MsResolution)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, subResults...)
+	}
+	return results, nil
+}
+
+// Function will select the series of the sub query within the `start`
+// (inclusive) and `end` (exclusive) range, downsample every series and then
+// aggregate the series of every group into a single result.
+//
+// DEVELOPERS NOTE:
+// OpenTSDB interpolates the values of the series which do not have a data
+// point at the same time before aggregating them, while we only aggregate the
+// values found at the same time. Downsampling aligns the data points of every
+// series so they are aggregated as expected.
+func (s *TStorageServerImpl) openTSDBSubQuery(sub *openTSDBSubQuery, start int64, end int64, msResolution bool) ([]*openTSDBResult, error) {
+	if sub.Metric == "" {
+		return nil, status.Error(codes.InvalidArgument, "metric must be set")
+	}
+	agg, none, err := openTSDBAggregation(sub.Aggregator)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	// Convert the tags and filters into label matchers, keeping track of the
+	// tags the series are grouped by.
+	m, _ := newLabelMatcher(matchEqual, metricNameLabel, sub.Metric)
+	q := &seriesQuery{metric: sub.Metric, matchers: []*labelMatcher{m}, start: start, end: end}
+	groupBy := []string{}
+	filters := sub.Filters
+	for name, value := range sub.Tags {
+		filters = append(filters, &openTSDBFilter{Type: "literal_or", Tagk: name, Filter: value, GroupBy: true})
+		if value == "*" {
+			filters[len(filters)-1].Type = "wildcard"
+		}
+	}
+	for _, f := range filters {
+		matcher, err := f.toLabelMatcher()
+		if err != nil {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+		q.matchers = append(q.matchers, matcher)
+		if f.GroupBy {
+			groupBy = append(groupBy, f.Tagk)
+		}
+	}
+
+	// Select and downsample every series.
+	var r *rangeQuery
+	if sub.Downsample != "" {
+		if r, err = s.toOpenTSDBDownsample(sub.Downsample, q); err != nil {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+	}
+	results, err := s.selectSeries(q)
+	if err != nil {
+		return nil, err
+	}
+	if r != nil {
+		for _, ser := range results {
+			if ser.points, err = r.bucketize(ser.points, alignDown(q.start, r.step), q.end); err != nil {
+				return nil, status.Error(codes.InvalidArgument, err.Error())
+			}
+		}
+	}
+
+	// Group the series by the values of the tags they are grouped by, or
+	// keep every series on its own with the `none` aggregator.
+	groups := map[string][]*series{}
+	keys := []string{}
+	for i, ser := range results {
+		key := strconv.Itoa(i)
+		if !none {
+			values := make([]string, 0, len(groupBy))
+			for _, name := range groupBy {
+				values = append(values, labelValue(ser.metric, ser.labels, name))
+			}
+			key = strings.Join(values, "\x00")
+		}
+		if _, ok := groups[key]; !ok {
+			keys = append(keys, key)
+		}
+		groups[key] = append(groups[key], ser)
+	}
+	sort.Strings(keys)
+
+	out := make([]*openTSDBResult, 0, len(keys))
+	for _, key := range keys {
+		result, err := s.aggregateOpenTSDBGroup(sub.Metric, groups[key], agg, msResolution)
+		if err != nil {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+		out = append(out, result)
+	}
+	return out, nil
+}
+
+// Function will aggregate the data points of the series found at the same
+// time into a single result.
+func (s *TStorageServerImpl) aggregateOpenTSDBGroup(metric string, group []*series, agg pb.Aggregation, msResolution bool) (*openTSDBResult, error) {
+	result := &openTSDBResult{Metric: metric, Tags: map[string]string{}, AggregateTags: []string{}}
+
+	// The tags with the same value in every series are shared, the others
+	// were aggregated.
+	counts := map[string]int{}
+	for _, ser := range group {
+		for _, label := range ser.labels {
+			counts[label.Name+"\x00"+label.Value]++
+		}
+	}
+	aggregated := map[string]bool{}
+	for _, ser := range group {
+		for _, label := range ser.labels {
+			if counts[label.Name+"\x00"+label.Value] == len(group) {
+				result.Tags[label.Name] = label.Value
+			} else {
+				aggregated[label.Name] = true
+			}
+		}
+	}
+	result.AggregateTags = append(result.AggregateTags, sortedKeys(aggregated)...)
+
+	// Aggregate the values found at the same time, ignoring the missing
+	// values of the buckets filled with null.
+	values := map[int64][]float64{}
+	timestamps := []int64{}
+	for _, ser := range group {
+		for _, point := range ser.points {
+			if _, ok := values[point.Timestamp]; !ok {
+				timestamps = append(timestamps, point.Timestamp)
+				values[point.Timestamp] = []float64{}
+			}
+			if !math.IsNaN(point.Value) {
+				values[point.Timestamp] = append(values[point.Timestamp], point.Value)
+			}
+		}
+	}
+	sort.Slice(timestamps, func(i, j int) bool {
+		return timestamps[i] < timestamps[j]
+	})
+	for _, ts := range timestamps {
+		value := math.NaN()
+		if len(values[ts]) > 0 {
+			v, err := aggregateValues(agg, values[ts])
+			if err != nil {
+				return nil, err
+			}
+			value = v
+		}
+		ms := unixToMillis(ts, s.timestampPrecision)
+		if !msResolution {
+			ms /= 1000
+		}
+		result.DPS = append(result.DPS, &tstorage.DataPoint{Timestamp: ms, Value: value})
+	}
+	return result, nil
+}
+
+// Function will convert the OpenTSDB downsample specification, such as
+// `1m-avg` or `1h-max-null`, into a range query.
+func (s *TStorageServerImpl) toOpenTSDBDownsample(spec string, q *seriesQuery) (*rangeQuery, error) {
+	parts := strings.Split(spec, "-")
+	if len(parts) != 2 && len(parts) != 3 {
+		return nil, fmt.Errorf("invalid downsample %q", spec)
+	}
+	interval := parts[0]
+	if strings.HasSuffix(interval, "n") {
+		// OpenTSDB months are approximated as 30 days.
+		n, err := strconv.Atoi(strings.TrimSuffix(interval, "n"))
+		if err != nil {
+			return nil, fmt.Errorf("invalid downsample interval %q", interval)
+		}
+		interval = fmt.Sprintf("%vd", n*30)
+	}
+	step, err := parseDuration(interval)
+	if err != nil {
+		return nil, err
+	}
+	if step < precisionUnit(s.timestampPrecision) {
+		return nil, fmt.Errorf("downsample interval must be at least %v", precisionUnit(s.timestampPrecision))
+	}
+
+	agg, none, err := openTSDBAggregation(parts[1])
+	if err != nil {
+		return nil, err
+	}
+	if none {
+		return nil, errors.New("downsample aggregator must not be none")
+	}
+	fill := pb.Fill_FILL_NONE
+	if len(parts) == 3 {
+		switch parts[2] {
+		case "none":
+		case "nan", "null":
+			fill = pb.Fill_FILL_NULL
+		default:
+			return nil, fmt.Errorf("unsupported fill policy %q", parts[2])
+		}
+	}
+
+	r := &rangeQuery{
+		step:        int64(step / precisionUnit(s.timestampPrecision)),
+		aggregation: agg,
+		fill:        fill,
+		precision:   s.timestampPrecision,
+	}
+	if buckets := (q.end - alignDown(q.start, r.step) + r.step - 1) / r.step; buckets > maxBucketsPerSeries {
+		return nil, fmt.Errorf("query would return %v buckets per series which exceeds the limit of %v, please use a larger downsample interval", buckets, maxBucketsPerSeries)
+	}
+	return r, nil
+}
+
+// Function returns the aggregation matching the OpenTSDB aggregator along
+// with whether it is the `none` aggregator, which does not aggregate the
+// series at all.
+func openTSDBAggregation(aggregator string) (pb.Aggregation, bool, error) {
+	switch aggregator {
+	case "avg":
+		return pb.Aggregation_AGGREGATION_AVG, false, nil
+	case "min", "mimmin":
+		return pb.Aggregation_AGGREGATION_MIN, false, nil
+	case "max", "mimmax":
+		return pb.Aggregation_AGGREGATION_MAX, false, nil
+	case "sum", "zimsum":
+		return pb.Aggregation_AGGREGATION_SUM, false, nil
+	case "count":
+		return pb.Aggregation_AGGREGATION_COUNT, false, nil
+	case "dev":
+		return pb.Aggregation_AGGREGATION_STDDEV, false, nil
+	case "p50":
+		return pb.Aggregation_AGGREGATION_P50, false, nil
+	case "p90":
+		return pb.Aggregation_AGGREGATION_P90, false, nil
+	case "p99":
+		return pb.Aggregation_AGGREGATION_P99, false, nil
+	case "none":
+		return pb.Aggregation_AGGREGATION_AVG, true, nil
+	}
+	return 0, false, fmt.Errorf("unsupported aggregator %q", aggregator)
+}
+
+// Function will convert the OpenTSDB filter into a label matcher.
+func (f *openTSDBFilter) toLabelMatcher() (*labelMatcher, error) {
+	if f.Tagk == "" {
+		return nil, errors.New("filter tagk must be set")
+	}
+	alternatives := func(caseInsensitive bool) string {
+		values := strings.Split(f.Filter, "|")
+		for i, v := range values {
+			values[i] = regexp.QuoteMeta(v)
+		}
+		if caseInsensitive {
+			return "(?i)" + strings.Join(values, "|")
+		}
+		return strings.Join(values, "|")
+	}
+
+	switch f.Type {
+	case "literal_or":
+		if !strings.Contains(f.Filter, "|") {
+			return newLabelMatcher(matchEqual, f.Tagk, f.Filter)
+		}
+		return newLabelMatcher(matchRegexp, f.Tagk, alternatives(false))
+	case "iliteral_or":
+		return newLabelMatcher(matchRegexp, f.Tagk, alternatives(true))
+	case "not_literal_or":
+		return newLabelMatcher(matchNotRegexp, f.Tagk, alternatives(false))
+	case "not_iliteral_or":
+		return newLabelMatcher(matchNotRegexp, f.Tagk, alternatives(true))
+	case "wildcard", "iwildcard":
+		// Series without the tag never match, like in OpenTSDB.
+		parts := strings.Split(f.Filter, "*")
+		for i, part := range parts {
+			parts[i] = regexp.QuoteMeta(part)
+		}
+		pattern := strings.Join(parts, ".*")
+		if f.Filter == "*" {
+			pattern = ".+"
+		}
+		if f.Type == "iwildcard" {
+			pattern = "(?i)" + pattern
+		}
+		return newLabelMatcher(matchRegexp, f.Tagk, pattern)
+	case "regexp":
+		return newLabelMatcher(matchRegexp, f.Tagk, f.Filter)
+	}
+	return nil, fmt.Errorf("unsupported filter type %q", f.Type)
+}
+
+// Function will parse the `m` parameter of a GET query, such as
+// `sum:1m-avg:sys.cpu.user{host=web01|web02,dc=*}`.
+func parseOpenTSDBSubQuery(m string) (*openTSDBSubQuery, error) {
+	metric, tags := m, ""
+	if i := strings.IndexByte(m, '{'); i >= 0 {
+		if !strings.HasSuffix(m, "}") {
+			return nil, fmt.Errorf("invalid query %q", m)
+		}
+		metric, tags = m[:i], m[i+1:len(m)-1]
+	}
+
+	parts := strings.Split(metric, ":")
+	if len(parts) < 2 {
+		return nil, fmt.Errorf("invalid query %q", m)
+	}
+	sub := &openTSDBSubQuery{
+		Aggregator: parts[0],
+		Metric:     parts[len(parts)-1],
+		Tags:       map[string]string{},
+	}
+	for _, part := range parts[1 : len(parts)-1] {
+		if !strings.Contains(part, "-") {
+			return nil, fmt.Errorf("unsupported query option %q", part)
+		}
+		sub.Downsample = part
+	}
+	if tags != "" {
+		for _, pair := range strings.Split(tags, ",") {
+			kv := strings.SplitN(pair, "=", 2)
+			if len(kv) != 2 || kv[0] == "" || kv[1] == "" {
+				return nil, fmt.Errorf("invalid tag %q", pair)
+			}
+			sub.Tags[kv[0]] = kv[1]
+		}
+	}
+	return sub, nil
+}
+
+// Function will parse an OpenTSDB time, which is a timestamp in seconds or
+// milliseconds, a relative time such as `1h-ago`, or a date such as
+// `2021/07/01-12:00:00`.
+func parseOpenTSDBTime(v interface{}, now time.Time) (time.Time, error) {
+	var text string
+	switch t := v.(type) {
+	case float64:
+		// Keep the numbers which do not fit into an int64 out of the
+		// conversion since its result is undefined.
+		if math.Abs(t) < math.MaxInt64 {
+			if ts, ok := openTSDBTimestamp(int64(t)); ok {
+				return ts, nil
+			}
+		}
+		return time.Time{}, fmt.Errorf("invalid time %v", v)
+	case string:
+		text = t
+	default:
+		return time.Time{}, fmt.Errorf("invalid time %v", v)
+	}
+
+	if n, err := strconv.ParseInt(text, 10, 64); err == nil {
+		if t, ok := openTSDBTimestamp(n); ok {
+			return t, nil
+		}
+		return time.Time{}, fmt.Errorf("invalid time %q", text)
+	}
+	if strings.HasSuffix(text, "-ago") {
+		d, err := parseDuration(strings.TrimSuffix(text, "-ago"))
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid time %q", text)
+		}
+		return now.Add(-d), nil
+	}
+	for _, layout := range []string{"2006/01/02-15:04:05", "2006/01/02-15:04", "2006/01/02 15:04:05", "2006/01/02 15:04", "2006/01/02"} {
+		if t, err := time.ParseInLocation(layout, text, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q", text)
+}
+
+// Function will write the error in the format of OpenTSDB.
+func writeOpenTSDBError(w http.ResponseWriter, code int, message string) {
+	writeJSON(w, code, map[string]interface{}{
+		"error": map[string]interface{}{"code": code, "message": message},
+	})
+}
+
+// Function will write the value encoded as JSON with the status code.
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
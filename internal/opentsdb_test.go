@@ -0,0 +1,295 @@
+package internal
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nakabonne/tstorage"
+
+	pb "github.com/bartmika/tstorage-server/proto"
+)
+
+func TestParseOpenTSDBTime(t *testing.T) {
+	now := time.Unix(1600000000, 0)
+	tests := []struct {
+		name    string
+		v       interface{}
+		want    time.Time
+		wantErr bool
+	}{
+		{"seconds", "1600000000", time.Unix(1600000000, 0), false},
+		{"milliseconds", "1600000000123", time.Unix(1600000000, 123*int64(time.Millisecond)), false},
+		{"number", float64(1600000000), time.Unix(1600000000, 0), false},
+		{"relative", "1h-ago", now.Add(-time.Hour), false},
+		{"date", "2021/07/01-12:30:15", time.Date(2021, 7, 1, 12, 30, 15, 0, time.Local), false},
+		{"date without seconds", "2021/07/01 12:30", time.Date(2021, 7, 1, 12, 30, 0, 0, time.Local), false},
+		{"day", "2021/07/01", time.Date(2021, 7, 1, 0, 0, 0, 0, time.Local), false},
+		{"milliseconds overflow", "9223372036854775", time.Time{}, true},
+		{"number overflows", 1e30, time.Time{}, true},
+		{"invalid relative", "1x-ago", time.Time{}, true},
+		{"invalid date", "2021-07-01", time.Time{}, true},
+		{"invalid type", true, time.Time{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseOpenTSDBTime(tt.v, now)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+			}
+			if !got.Equal(tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseOpenTSDBSubQuery(t *testing.T) {
+	tests := []struct {
+		name    string
+		m       string
+		want    *openTSDBSubQuery
+		wantErr bool
+	}{
+		{"metric", "sum:sys.cpu", &openTSDBSubQuery{Aggregator: "sum", Metric: "sys.cpu", Tags: map[string]string{}}, false},
+		{"downsample and tags", "avg:1m-max-null:sys.cpu{host=web01|web02,dc=*}", &openTSDBSubQuery{
+			Aggregator: "avg", Metric: "sys.cpu", Downsample: "1m-max-null",
+			Tags: map[string]string{"host": "web01|web02", "dc": "*"},
+		}, false},
+		{"missing aggregator", "sys.cpu", nil, true},
+		{"unsupported option", "sum:rate:sys.cpu", nil, true},
+		{"unterminated tags", "sum:sys.cpu{host=web01", nil, true},
+		{"invalid tag", "sum:sys.cpu{host}", nil, true},
+		{"empty tag value", "sum:sys.cpu{host=}", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseOpenTSDBSubQuery(tt.m)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOpenTSDBFilterToLabelMatcher(t *testing.T) {
+	tests := []struct {
+		name     string
+		filter   *openTSDBFilter
+		matches  []string
+		excludes []string
+		wantErr  bool
+	}{
+		{"literal", &openTSDBFilter{Type: "literal_or", Tagk: "host", Filter: "web01"}, []string{"web01"}, []string{"web011", "WEB01"}, false},
+		{"literal alternatives are anchored", &openTSDBFilter{Type: "literal_or", Tagk: "host", Filter: "web01|web02"}, []string{"web01", "web02"}, []string{"web011", "aweb02", "web01|web02"}, false},
+		{"literal with regexp characters", &openTSDBFilter{Type: "literal_or", Tagk: "host", Filter: "a.b|c"}, []string{"a.b", "c"}, []string{"axb"}, false},
+		{"case insensitive literal", &openTSDBFilter{Type: "iliteral_or", Tagk: "host", Filter: "web01"}, []string{"web01", "WEB01"}, []string{"web011"}, false},
+		{"not literal", &openTSDBFilter{Type: "not_literal_or", Tagk: "host", Filter: "web01|web02"}, []string{"web011", "db01"}, []string{"web01", "web02"}, false},
+		{"not case insensitive literal", &openTSDBFilter{Type: "not_iliteral_or", Tagk: "host", Filter: "web01"}, []string{"db01"}, []string{"WEB01"}, false},
+		{"wildcard", &openTSDBFilter{Type: "wildcard", Tagk: "host", Filter: "web*.eu"}, []string{"web.eu", "web01.eu"}, []string{"web01.eu2", "db01.eu", "WEB01.eu"}, false},
+		{"any value", &openTSDBFilter{Type: "wildcard", Tagk: "host", Filter: "*"}, []string{"web01"}, []string{""}, false},
+		{"case insensitive wildcard", &openTSDBFilter{Type: "iwildcard", Tagk: "host", Filter: "web*"}, []string{"WEB01"}, []string{"db01"}, false},
+		{"regexp is anchored", &openTSDBFilter{Type: "regexp", Tagk: "host", Filter: "web[0-9]+"}, []string{"web01"}, []string{"web01a", "aweb01"}, false},
+		{"invalid regexp", &openTSDBFilter{Type: "regexp", Tagk: "host", Filter: "web("}, nil, nil, true},
+		{"missing tagk", &openTSDBFilter{Type: "literal_or", Filter: "web01"}, nil, nil, true},
+		{"unsupported type", &openTSDBFilter{Type: "range", Tagk: "host", Filter: "1"}, nil, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := tt.filter.toLabelMatcher()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+			}
+			for _, v := range tt.matches {
+				if !m.matches(v) {
+					t.Errorf("%q does not match", v)
+				}
+			}
+			for _, v := range tt.excludes {
+				if m.matches(v) {
+					t.Errorf("%q matches", v)
+				}
+			}
+		})
+	}
+}
+
+func TestToOpenTSDBDownsample(t *testing.T) {
+	impl := &TStorageServerImpl{timestampPrecision: tstorage.Seconds}
+	q := &seriesQuery{start: 1600000000, end: 1600000000 + 7*86400}
+	tests := []struct {
+		name    string
+		spec    string
+		want    *rangeQuery
+		wantErr bool
+	}{
+		{"aggregator", "1m-avg", &rangeQuery{step: 60, aggregation: pb.Aggregation_AGGREGATION_AVG, fill: pb.Fill_FILL_NONE, precision: tstorage.Seconds}, false},
+		{"fill", "1h-zimsum-null", &rangeQuery{step: 3600, aggregation: pb.Aggregation_AGGREGATION_SUM, fill: pb.Fill_FILL_NULL, precision: tstorage.Seconds}, false},
+		{"explicit no fill", "1m-max-none", &rangeQuery{step: 60, aggregation: pb.Aggregation_AGGREGATION_MAX, fill: pb.Fill_FILL_NONE, precision: tstorage.Seconds}, false},
+		{"months", "1n-count", &rangeQuery{step: 30 * 86400, aggregation: pb.Aggregation_AGGREGATION_COUNT, fill: pb.Fill_FILL_NONE, precision: tstorage.Seconds}, false},
+		{"missing aggregator", "1m", nil, true},
+		{"too many parts", "1m-avg-null-x", nil, true},
+		{"none aggregator", "1m-none", nil, true},
+		{"unsupported aggregator", "1m-median", nil, true},
+		{"unsupported fill", "1m-avg-zero", nil, true},
+		{"invalid interval", "abc-avg", nil, true},
+		{"invalid months", "xn-avg", nil, true},
+		{"interval below the precision", "1ms-avg", nil, true},
+		{"too many buckets", "1s-avg", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := impl.toOpenTSDBDownsample(tt.spec, q)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOpenTSDBPointsMarshalJSON(t *testing.T) {
+	points := openTSDBPoints{
+		{Timestamp: 1600000000, Value: 1.5},
+		{Timestamp: 1600000060, Value: math.NaN()},
+		{Timestamp: 1600000120, Value: 2},
+	}
+	b, err := json.Marshal(points)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"1600000000":1.5,"1600000060":null,"1600000120":2}`; string(b) != want {
+		t.Errorf("got %s, want %s", b, want)
+	}
+	if b, _ := json.Marshal(openTSDBPoints{}); string(b) != "{}" {
+		t.Errorf("got %s, want {}", b)
+	}
+}
+
+func TestOpenTSDBPut(t *testing.T) {
+	tests := []struct {
+		name     string
+		method   string
+		query    string
+		body     string
+		want     int
+		wantBody string
+		stored   int
+	}{
+		{"single data point", http.MethodPost, "", `{"metric":"sys.cpu","timestamp":1600000000,"value":1,"tags":{"host":"web01"}}`, http.StatusNoContent, "", 1},
+		{"summary", http.MethodPost, "?summary", `[{"metric":"sys.cpu","timestamp":1600000000000,"value":1},{"metric":"sys.cpu","timestamp":1600000001,"value":"2"}]`, http.StatusOK, `{"success":2,"failed":0}`, 2},
+		{"details of the invalid data points", http.MethodPost, "?details", `[
+			{"metric":"sys.cpu","timestamp":1600000000,"value":1},
+			{"metric":"","timestamp":1600000001,"value":1},
+			{"metric":"sys.cpu","timestamp":0,"value":1},
+			{"metric":"sys.cpu","timestamp":1.5,"value":1},
+			{"metric":"sys.cpu","timestamp":9223372036854775,"value":1},
+			{"metric":"sys.cpu","timestamp":1600000002,"value":1e999}
+		]`, http.StatusBadRequest, `"success":1,"failed":5`, 1},
+		{"invalid data points without details", http.MethodPost, "", `[{"metric":"","timestamp":1600000000,"value":1}]`, http.StatusBadRequest, "append details", 0},
+		{"invalid JSON", http.MethodPost, "", `{"metric":`, http.StatusBadRequest, "failed to decode request", 0},
+		{"method not allowed", http.MethodGet, "", "", http.StatusMethodNotAllowed, "method not allowed", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestServer(t)
+			w := httptest.NewRecorder()
+			s.handleOpenTSDBPut(w, httptest.NewRequest(tt.method, "/api/put"+tt.query, strings.NewReader(tt.body)))
+			if w.Code != tt.want {
+				t.Fatalf("got status %v, want %v: %v", w.Code, tt.want, w.Body.String())
+			}
+			if !strings.Contains(w.Body.String(), tt.wantBody) {
+				t.Errorf("got body %v, want %v", w.Body.String(), tt.wantBody)
+			}
+			stored := 0
+			for _, labels := range [][]tstorage.Label{nil, {{Name: "host", Value: "web01"}}} {
+				stored += len(selectTestPoints(t, s, "", "sys.cpu", labels...))
+			}
+			if stored != tt.stored {
+				t.Errorf("got %v stored data points, want %v", stored, tt.stored)
+			}
+		})
+	}
+}
+
+func TestOpenTSDBQuery(t *testing.T) {
+	// The data points are aligned to the minute so the downsampled buckets
+	// start at the start of the query.
+	const base = 1600000020
+	s := newTestServer(t)
+	impl, release, err := s.tenants.acquire("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer release()
+	rows := []tstorage.Row{
+		{Metric: "sys.cpu", Labels: []tstorage.Label{{Name: "host", Value: "web01"}, {Name: "dc", Value: "eu"}}, DataPoint: tstorage.DataPoint{Timestamp: base, Value: 1}},
+		{Metric: "sys.cpu", Labels: []tstorage.Label{{Name: "host", Value: "web01"}, {Name: "dc", Value: "eu"}}, DataPoint: tstorage.DataPoint{Timestamp: base + 60, Value: 3}},
+		{Metric: "sys.cpu", Labels: []tstorage.Label{{Name: "host", Value: "web02"}, {Name: "dc", Value: "eu"}}, DataPoint: tstorage.DataPoint{Timestamp: base, Value: 5}},
+		{Metric: "sys.cpu", Labels: []tstorage.Label{{Name: "host", Value: "db01"}, {Name: "dc", Value: "us"}}, DataPoint: tstorage.DataPoint{Timestamp: base, Value: 10}},
+	}
+	if err := impl.insertRows(rows); err != nil {
+		t.Fatal(err)
+	}
+
+	query := func(subs ...*openTSDBSubQuery) *openTSDBQueryRequest {
+		return &openTSDBQueryRequest{Start: "1600000020", End: "1600000199", Queries: subs}
+	}
+	tests := []struct {
+		name    string
+		req     *openTSDBQueryRequest
+		want    string
+		wantErr bool
+	}{
+		{"aggregate every series", query(&openTSDBSubQuery{Aggregator: "sum", Metric: "sys.cpu"}),
+			`[{"metric":"sys.cpu","tags":{},"aggregateTags":["dc","host"],"dps":{"1600000020":16,"1600000080":3}}]`, false},
+		{"group by tag", query(&openTSDBSubQuery{Aggregator: "max", Metric: "sys.cpu", Tags: map[string]string{"dc": "*"}}),
+			`[{"metric":"sys.cpu","tags":{"dc":"eu"},"aggregateTags":["host"],"dps":{"1600000020":5,"1600000080":3}},` +
+				`{"metric":"sys.cpu","tags":{"dc":"us","host":"db01"},"aggregateTags":[],"dps":{"1600000020":10}}]`, false},
+		{"filter without grouping", query(&openTSDBSubQuery{Aggregator: "sum", Metric: "sys.cpu", Filters: []*openTSDBFilter{{Type: "iliteral_or", Tagk: "host", Filter: "WEB01|db01"}}}),
+			`[{"metric":"sys.cpu","tags":{},"aggregateTags":["dc","host"],"dps":{"1600000020":11,"1600000080":3}}]`, false},
+		{"none aggregator", query(&openTSDBSubQuery{Aggregator: "none", Metric: "sys.cpu", Tags: map[string]string{"dc": "eu"}}),
+			`[{"metric":"sys.cpu","tags":{"dc":"eu","host":"web01"},"aggregateTags":[],"dps":{"1600000020":1,"1600000080":3}},` +
+				`{"metric":"sys.cpu","tags":{"dc":"eu","host":"web02"},"aggregateTags":[],"dps":{"1600000020":5}}]`, false},
+		{"empty buckets", query(&openTSDBSubQuery{Aggregator: "sum", Metric: "sys.cpu", Downsample: "1m-avg-null", Tags: map[string]string{"host": "web02"}}),
+			`[{"metric":"sys.cpu","tags":{"dc":"eu","host":"web02"},"aggregateTags":[],"dps":{"1600000020":5,"1600000080":null,"1600000140":null}}]`, false},
+		{"milliseconds", &openTSDBQueryRequest{Start: float64(base), End: "1600000021", MsResolution: true, Queries: []*openTSDBSubQuery{{Aggregator: "sum", Metric: "sys.cpu"}}},
+			`[{"metric":"sys.cpu","tags":{},"aggregateTags":["dc","host"],"dps":{"1600000020000":16}}]`, false},
+		{"no series", query(&openTSDBSubQuery{Aggregator: "sum", Metric: "sys.mem"}), `[]`, false},
+		{"missing start", &openTSDBQueryRequest{Queries: []*openTSDBSubQuery{{Aggregator: "sum", Metric: "sys.cpu"}}}, "", true},
+		{"start after end", &openTSDBQueryRequest{Start: "1600000200", End: "1600000100", Queries: []*openTSDBSubQuery{{Aggregator: "sum", Metric: "sys.cpu"}}}, "", true},
+		{"missing queries", query(), "", true},
+		{"missing metric", query(&openTSDBSubQuery{Aggregator: "sum"}), "", true},
+		{"unsupported aggregator", query(&openTSDBSubQuery{Aggregator: "median", Metric: "sys.cpu"}), "", true},
+		{"invalid filter", query(&openTSDBSubQuery{Aggregator: "sum", Metric: "sys.cpu", Filters: []*openTSDBFilter{{Type: "regexp", Tagk: "host", Filter: "("}}}), "", true},
+		{"invalid downsample", query(&openTSDBSubQuery{Aggregator: "sum", Metric: "sys.cpu", Downsample: "1m"}), "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results, err := impl.openTSDBQuery(tt.req, nil)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			b, err := json.Marshal(results)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(b) != tt.want {
+				t.Errorf("got %s, want %s", b, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,147 @@
+package internal
+
+import "time"
+
+const (
+	defaultInsertBatchSize = 1000
+	defaultRetention       = 336 * time.Hour
+)
+
+// Option is an optional setting for New.
+type Option func(*TStorageServer)
+
+// WithInsertBatchSize specifies how many rows received by the `InsertRows`
+// stream are buffered before being written to storage in a single call.
+//
+// Defaults to 1000.
+func WithInsertBatchSize(size int) Option {
+	return func(s *TStorageServer) {
+		s.insertBatchSize = size
+	}
+}
+
+// WithRetention specifies how long data is kept before the partition it was
+// written to gets removed from the disk. A zero or negative value uses the
+// default.
+//
+// Defaults to 14d.
+func WithRetention(retention time.Duration) Option {
+	return func(s *TStorageServer) {
+		s.retention = retention
+	}
+}
+
+// WithPolicyFile specifies the location of the file with the retention and
+// rollup policies of the metrics, see `policyFile` for the format.
+//
+// Defaults to no policies.
+func WithPolicyFile(filePath string) Option {
+	return func(s *TStorageServer) {
+		s.policyFile = filePath
+	}
+}
+
+// WithTLS specifies the certificate and private key files used to serve gRPC
+// over TLS. If the client CA file is provided as well then every client must
+// present a certificate signed by it (mutual TLS).
+//
+// Defaults to no TLS.
+func WithTLS(certFile string, keyFile string, clientCAFile string) Option {
+	return func(s *TStorageServer) {
+		s.tlsCertFile = certFile
+		s.tlsKeyFile = keyFile
+		s.tlsClientCAFile = clientCAFile
+	}
+}
+
+// WithTokenFile specifies the location of the file with the bearer tokens
+// clients must authenticate with, see `tokenFile` for the format.
+//
+// Defaults to no authentication.
+func WithTokenFile(filePath string) Option {
+	return func(s *TStorageServer) {
+		s.tokenFile = filePath
+	}
+}
+
+// WithMultiTenancy specifies that every request is made for a tenant, given
+// by the access token or the `x-tenant-id` metadata, whose data is stored
+// separately inside of its own directory of the data path. At most
+// `maxOpenTenants` idle tenants are kept open.
+//
+// Defaults to a single tenant using the data path itself.
+func WithMultiTenancy(maxOpenTenants int) Option {
+	return func(s *TStorageServer) {
+		s.multiTenant = true
+		s.maxOpenTenants = maxOpenTenants
+	}
+}
+
+// WithHTTPPort specifies the port of the HTTP server exposing our metrics at
+// `/metrics` in the Prometheus text format along with our HTTP APIs, such as
+// the Prometheus remote write and remote read endpoints.
+//
+// Defaults to no HTTP server.
+func WithHTTPPort(port int) Option {
+	return func(s *TStorageServer) {
+		s.httpPort = port
+	}
+}
+
+// WithGatewayPort specifies the port of the HTTP server exposing the
+// `InsertRow`, `InsertRows` and `Select` methods of our service as JSON.
+//
+// Defaults to no gateway.
+func WithGatewayPort(port int) Option {
+	return func(s *TStorageServer) {
+		s.gatewayPort = port
+	}
+}
+
+// WithInfluxUDPPort specifies the port of the UDP listener receiving the
+// InfluxDB line protocol with nanosecond timestamps.
+//
+// Defaults to no UDP listener.
+func WithInfluxUDPPort(port int) Option {
+	return func(s *TStorageServer) {
+		s.influxUDPPort = port
+	}
+}
+
+// WithGraphite specifies the port of the TCP and UDP listeners receiving the
+// Graphite plaintext protocol, the port of the TCP listener receiving the
+// Graphite pickle protocol and the templates mapping the Graphite paths to
+// metric names and labels, see `graphiteTemplate` for the syntax. A port of 0
+// disables its listeners.
+//
+// Defaults to no Graphite listeners.
+func WithGraphite(port int, picklePort int, templates []string) Option {
+	return func(s *TStorageServer) {
+		s.graphitePort = port
+		s.graphitePicklePort = picklePort
+		s.graphiteTemplateSpecs = templates
+	}
+}
+
+// WithStatsd specifies the port of the UDP listener receiving the StatsD
+// protocol and how often the received metrics are aggregated and written to
+// storage, see `statsdAggregator` for the written metrics.
+//
+// Defaults to no StatsD listener and a flush interval of 10 seconds.
+func WithStatsd(port int, flushInterval time.Duration) Option {
+	return func(s *TStorageServer) {
+		s.statsdPort = port
+		s.statsdFlushInterval = flushInterval
+	}
+}
+
+// WithListenerTenant specifies the tenant the data received by our listeners
+// is written to when multi-tenancy is enabled, since our listeners do not
+// authenticate their senders.
+//
+// Required by the listeners when multi-tenancy is enabled.
+func WithListenerTenant(tenant string) Option {
+	return func(s *TStorageServer) {
+		s.listenerTenantName = tenant
+	}
+}
@@ -0,0 +1,80 @@
+package internal
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+)
+
+// partitionDirRegex matches the name of the directories `tstorage` creates
+// for every partition written to disk; the name contains the minimum and
+// maximum timestamp of the data points inside of the partition.
+var partitionDirRegex = regexp.MustCompile(`^p-(-?\d+)-(-?\d+)$`)
+
+// partitionInfo describes a partition which `tstorage` has written to disk.
+type partitionInfo struct {
+	name         string
+	minTimestamp int64
+	maxTimestamp int64
+
+	// The number of bytes used on disk by the partition.
+	size int64
+}
+
+// Function returns every partition found inside of the data path.
+func listPartitions(dataPath string) ([]*partitionInfo, error) {
+	entries, err := ioutil.ReadDir(dataPath)
+	if err != nil {
+		return nil, err
+	}
+
+	partitions := []*partitionInfo{}
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		matches := partitionDirRegex.FindStringSubmatch(entry.Name())
+		if matches == nil {
+			continue
+		}
+		min, _ := strconv.ParseInt(matches[1], 10, 64)
+		max, _ := strconv.ParseInt(matches[2], 10, 64)
+		partitions = append(partitions, &partitionInfo{
+			name:         entry.Name(),
+			minTimestamp: min,
+			maxTimestamp: max,
+			size:         dirSize(filepath.Join(dataPath, entry.Name())),
+		})
+	}
+	return partitions, nil
+}
+
+// Function returns the minimum timestamp of the oldest partition on disk. The
+// boolean is false if there are no partitions on disk yet.
+func oldestPartitionTimestamp(dataPath string) (int64, bool, error) {
+	partitions, err := listPartitions(dataPath)
+	if err != nil || len(partitions) == 0 {
+		return 0, false, err
+	}
+	oldest := partitions[0].minTimestamp
+	for _, p := range partitions[1:] {
+		if p.minTimestamp < oldest {
+			oldest = p.minTimestamp
+		}
+	}
+	return oldest, true, nil
+}
+
+// Function returns the total size of the files inside of the directory.
+func dirSize(path string) int64 {
+	var size int64
+	filepath.Walk(path, func(_ string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() {
+			size += info.Size()
+		}
+		return nil
+	})
+	return size
+}
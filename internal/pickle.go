@@ -0,0 +1,363 @@
+package internal
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// Function will decode the pickled data sent by Graphite clients, which is
+// made of lists, tuples, strings and numbers. Lists and tuples are returned
+// as `[]interface{}`, strings and bytes as `string`, integers as `int64` and
+// floats as `float64`.
+//
+// DEVELOPERS NOTE:
+// We only implement the opcodes needed to decode plain data, of any pickle
+// protocol version, and never the ones which can create objects or call
+// functions, so unpickling the data sent by anyone is safe.
+func unpickle(b []byte) (interface{}, error) {
+	u := &unpickler{r: bytes.NewReader(b), memo: map[int]interface{}{}}
+	for {
+		op, err := u.r.ReadByte()
+		if err != nil {
+			return nil, errors.New("unexpected end of pickle")
+		}
+		if op == '.' { // STOP
+			if len(u.stack) != 1 {
+				return nil, errors.New("invalid pickle")
+			}
+			return u.stack[0], nil
+		}
+		if err := u.exec(op); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// unpickler is the state of the pickle machine.
+type unpickler struct {
+	r     *bytes.Reader
+	stack []interface{}
+	marks []int
+	memo  map[int]interface{}
+}
+
+// Function will run a single opcode of the pickle machine.
+func (u *unpickler) exec(op byte) error {
+	switch op {
+	case 0x80: // PROTO
+		_, err := u.r.ReadByte()
+		return err
+	case 0x95: // FRAME
+		_, err := u.readBytes(8)
+		return err
+	case '(': // MARK
+		u.marks = append(u.marks, len(u.stack))
+	case ']', ')': // EMPTY_LIST, EMPTY_TUPLE
+		u.push([]interface{}{})
+	case 'l', 't': // LIST, TUPLE
+		items, err := u.popMark()
+		if err != nil {
+			return err
+		}
+		u.push(items)
+	case 0x85, 0x86, 0x87: // TUPLE1, TUPLE2, TUPLE3
+		n := int(op-0x85) + 1
+		if len(u.stack) < n {
+			return errors.New("invalid pickle")
+		}
+		items := append([]interface{}{}, u.stack[len(u.stack)-n:]...)
+		u.stack = u.stack[:len(u.stack)-n]
+		u.push(items)
+	case 'a': // APPEND
+		v, err := u.pop()
+		if err != nil {
+			return err
+		}
+		return u.appendTop([]interface{}{v})
+	case 'e': // APPENDS
+		items, err := u.popMark()
+		if err != nil {
+			return err
+		}
+		return u.appendTop(items)
+	case 'N': // NONE
+		u.push(nil)
+	case 0x88: // NEWTRUE
+		u.push(int64(1))
+	case 0x89: // NEWFALSE
+		u.push(int64(0))
+	case 'I', 'L': // INT, LONG
+		line, err := u.readLine()
+		if err != nil {
+			return err
+		}
+		line = strings.TrimSuffix(line, "L")
+		if op == 'I' && (line == "00" || line == "01") {
+			u.push(int64(line[1] - '0'))
+			return nil
+		}
+		v, err := strconv.ParseInt(line, 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid integer %q", line)
+		}
+		u.push(v)
+	case 'J': // BININT
+		b, err := u.readBytes(4)
+		if err != nil {
+			return err
+		}
+		u.push(int64(int32(binary.LittleEndian.Uint32(b))))
+	case 'K': // BININT1
+		b, err := u.readBytes(1)
+		if err != nil {
+			return err
+		}
+		u.push(int64(b[0]))
+	case 'M': // BININT2
+		b, err := u.readBytes(2)
+		if err != nil {
+			return err
+		}
+		u.push(int64(binary.LittleEndian.Uint16(b)))
+	case 0x8a: // LONG1
+		n, err := u.r.ReadByte()
+		if err != nil {
+			return err
+		}
+		b, err := u.readBytes(int(n))
+		if err != nil {
+			return err
+		}
+		u.push(decodeLong(b))
+	case 'F': // FLOAT
+		line, err := u.readLine()
+		if err != nil {
+			return err
+		}
+		v, err := strconv.ParseFloat(line, 64)
+		if err != nil {
+			return fmt.Errorf("invalid float %q", line)
+		}
+		u.push(v)
+	case 'G': // BINFLOAT
+		b, err := u.readBytes(8)
+		if err != nil {
+			return err
+		}
+		u.push(math.Float64frombits(binary.BigEndian.Uint64(b)))
+	case 'S': // STRING
+		line, err := u.readLine()
+		if err != nil {
+			return err
+		}
+		v, err := unquotePickleString(line)
+		if err != nil {
+			return err
+		}
+		u.push(v)
+	case 'V': // UNICODE
+		line, err := u.readLine()
+		if err != nil {
+			return err
+		}
+		u.push(line)
+	case 'U', 'C', 0x8c: // SHORT_BINSTRING, SHORT_BINBYTES, SHORT_BINUNICODE
+		n, err := u.r.ReadByte()
+		if err != nil {
+			return err
+		}
+		return u.pushString(int(n))
+	case 'T', 'B', 'X': // BINSTRING, BINBYTES, BINUNICODE
+		b, err := u.readBytes(4)
+		if err != nil {
+			return err
+		}
+		return u.pushString(int(binary.LittleEndian.Uint32(b)))
+	case 0x8d, 0x8e: // BINUNICODE8, BINBYTES8
+		b, err := u.readBytes(8)
+		if err != nil {
+			return err
+		}
+		n := binary.LittleEndian.Uint64(b)
+		if n > uint64(u.r.Len()) {
+			return errors.New("unexpected end of pickle")
+		}
+		return u.pushString(int(n))
+	case 'p', 'g': // PUT, GET
+		line, err := u.readLine()
+		if err != nil {
+			return err
+		}
+		index, err := strconv.Atoi(line)
+		if err != nil {
+			return fmt.Errorf("invalid memo index %q", line)
+		}
+		return u.memoize(op == 'p', index)
+	case 'q', 'h': // BINPUT, BINGET
+		b, err := u.readBytes(1)
+		if err != nil {
+			return err
+		}
+		return u.memoize(op == 'q', int(b[0]))
+	case 'r', 'j': // LONG_BINPUT, LONG_BINGET
+		b, err := u.readBytes(4)
+		if err != nil {
+			return err
+		}
+		return u.memoize(op == 'r', int(binary.LittleEndian.Uint32(b)))
+	case 0x94: // MEMOIZE
+		return u.memoize(true, len(u.memo))
+	default:
+		return fmt.Errorf("unsupported pickle opcode %#x", op)
+	}
+	return nil
+}
+
+// Function will push the value on the stack.
+func (u *unpickler) push(v interface{}) {
+	u.stack = append(u.stack, v)
+}
+
+// Function will pop the value on top of the stack.
+func (u *unpickler) pop() (interface{}, error) {
+	if len(u.stack) == 0 {
+		return nil, errors.New("invalid pickle")
+	}
+	v := u.stack[len(u.stack)-1]
+	u.stack = u.stack[:len(u.stack)-1]
+	return v, nil
+}
+
+// Function will pop every value pushed since the last mark.
+func (u *unpickler) popMark() ([]interface{}, error) {
+	if len(u.marks) == 0 {
+		return nil, errors.New("invalid pickle")
+	}
+	mark := u.marks[len(u.marks)-1]
+	u.marks = u.marks[:len(u.marks)-1]
+
+	// The values below the mark may have been popped since it was set.
+	if mark > len(u.stack) {
+		return nil, errors.New("invalid pickle")
+	}
+	items := append([]interface{}{}, u.stack[mark:]...)
+	u.stack = u.stack[:mark]
+	return items, nil
+}
+
+// Function will append the values to the list on top of the stack.
+func (u *unpickler) appendTop(items []interface{}) error {
+	if len(u.stack) == 0 {
+		return errors.New("invalid pickle")
+	}
+	list, ok := u.stack[len(u.stack)-1].([]interface{})
+	if !ok {
+		return errors.New("invalid pickle")
+	}
+	u.stack[len(u.stack)-1] = append(list, items...)
+	return nil
+}
+
+// Function will store the value on top of the stack in the memo when `put`
+// is true and otherwise push the value stored in the memo.
+//
+// DEVELOPERS NOTE:
+// Lists are stored by value, so lists appended to after being stored in the
+// memo are not updated in the memo. Graphite clients only memoize the
+// strings and tuples they reuse so this does not matter here.
+func (u *unpickler) memoize(put bool, index int) error {
+	if put {
+		if len(u.stack) == 0 {
+			return errors.New("invalid pickle")
+		}
+		u.memo[index] = u.stack[len(u.stack)-1]
+		return nil
+	}
+	v, ok := u.memo[index]
+	if !ok {
+		return fmt.Errorf("missing memo index %v", index)
+	}
+	u.push(v)
+	return nil
+}
+
+// Function will read a line without its newline.
+func (u *unpickler) readLine() (string, error) {
+	var b strings.Builder
+	for {
+		c, err := u.r.ReadByte()
+		if err != nil {
+			return "", errors.New("unexpected end of pickle")
+		}
+		if c == '\n' {
+			return b.String(), nil
+		}
+		b.WriteByte(c)
+	}
+}
+
+// Function will read the given number of bytes.
+func (u *unpickler) readBytes(n int) ([]byte, error) {
+	if n < 0 || n > u.r.Len() {
+		return nil, errors.New("unexpected end of pickle")
+	}
+	b := make([]byte, n)
+	u.r.Read(b)
+	return b, nil
+}
+
+// Function will read and push a string of the given length.
+func (u *unpickler) pushString(n int) error {
+	b, err := u.readBytes(n)
+	if err != nil {
+		return err
+	}
+	u.push(string(b))
+	return nil
+}
+
+// Function will decode the Python string literal of the STRING opcode, which
+// is usually single quoted such as `'it\'s'`.
+func unquotePickleString(line string) (string, error) {
+	if len(line) < 2 || line[0] != line[len(line)-1] || (line[0] != '\'' && line[0] != '"') {
+		return "", fmt.Errorf("invalid string %q", line)
+	}
+	quoted := line
+	if line[0] == '\'' {
+		// Python and Go escape the same way, except for the quotes.
+		body := strings.NewReplacer(`\\`, `\\`, `\'`, `'`, `"`, `\"`).Replace(line[1 : len(line)-1])
+		quoted = `"` + body + `"`
+	}
+	v, err := strconv.Unquote(quoted)
+	if err != nil {
+		return "", fmt.Errorf("invalid string %q", line)
+	}
+	return v, nil
+}
+
+// Function will decode the little-endian two's complement integer, returning
+// the integers too large for an `int64` as a `float64`.
+func decodeLong(b []byte) interface{} {
+	if len(b) == 0 {
+		return int64(0)
+	}
+	be := make([]byte, len(b))
+	for i := range b {
+		be[len(b)-1-i] = b[i]
+	}
+	v := new(big.Int).SetBytes(be)
+	if b[len(b)-1]&0x80 != 0 {
+		v.Sub(v, new(big.Int).Lsh(big.NewInt(1), uint(len(b)*8)))
+	}
+	if v.IsInt64() {
+		return v.Int64()
+	}
+	f, _ := new(big.Float).SetInt(v).Float64()
+	return f
+}
@@ -0,0 +1,143 @@
+package internal
+
+import (
+	"encoding/hex"
+	"math"
+	"reflect"
+	"testing"
+)
+
+// The pickles below were made by Python with `pickle.dumps(v, protocol=N)`.
+const (
+	// [("servers.web1.cpu", (1600000000, 1.5)), ("servers.web2.cpu;dc=eu", (1600000001.5, 2))]
+	pickleProtocol0 = "286c70300a2856736572766572732e776562312e6370750a70310a2849313630303030303030300a46312e350a7470320a7470330a612856736572766572732e776562322e6370753b64633d65750a70340a2846313630303030303030312e350a49320a7470350a7470360a612e"
+	pickleProtocol1 = "5d710028285810000000736572766572732e776562312e6370757101284a00105e5f473ff8000000000000747102747103285816000000736572766572732e776562322e6370753b64633d65757104284741d7d784006000004b02747105747106652e"
+	pickleProtocol2 = "80025d7100285810000000736572766572732e776562312e63707571014a00105e5f473ff80000000000008671028671035816000000736572766572732e776562322e6370753b64633d657571044741d7d784006000004b02867105867106652e"
+	pickleProtocol4 = "80049552000000000000005d94288c10736572766572732e776562312e637075944a00105e5f473ff8000000000000869486948c16736572766572732e776562322e6370753b64633d6575944741d7d784006000004b0286948694652e"
+
+	// [("a", (1600000000, 2**70))]
+	pickleLong = "80025d710058010000006171014a00105e5f8a09000000000000000040867102867103612e"
+	// [("a", (1600000000, -300))]
+	pickleNegative = "80025d710058010000006171014a00105e5f4ad4feffff867102867103612e"
+	// [("a", ("1600000000", "7"))]
+	pickleStrings = "80025d71005801000000617101580a0000003136303030303030303071025801000000377103867104867105612e"
+)
+
+// Function will decode the hex encoded pickle.
+func pickleFromHex(s string) []byte {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+func TestUnpickle(t *testing.T) {
+	points := []interface{}{
+		[]interface{}{"servers.web1.cpu", []interface{}{int64(1600000000), 1.5}},
+		[]interface{}{"servers.web2.cpu;dc=eu", []interface{}{1600000001.5, int64(2)}},
+	}
+	tests := []struct {
+		name   string
+		pickle []byte
+		want   interface{}
+	}{
+		{"protocol 0", pickleFromHex(pickleProtocol0), points},
+		{"protocol 1", pickleFromHex(pickleProtocol1), points},
+		{"protocol 2", pickleFromHex(pickleProtocol2), points},
+		{"protocol 4", pickleFromHex(pickleProtocol4), points},
+		{"long", pickleFromHex(pickleLong), []interface{}{[]interface{}{"a", []interface{}{int64(1600000000), math.Pow(2, 70)}}}},
+		{"negative", pickleFromHex(pickleNegative), []interface{}{[]interface{}{"a", []interface{}{int64(1600000000), int64(-300)}}}},
+		{"strings", pickleFromHex(pickleStrings), []interface{}{[]interface{}{"a", []interface{}{"1600000000", "7"}}}},
+		{"quoted string", []byte("S'a\\nb'\n."), "a\nb"},
+		{"quoted string with quotes", []byte("S'it\\'s \"ok\"'\n."), `it's "ok"`},
+		{"double quoted string", []byte("S\"it's\"\n."), "it's"},
+		{"quoted backslash", []byte("S'a\\\\'\n."), `a\`},
+		{"booleans", []byte("(I01\nI00\n\x88\x89t."), []interface{}{int64(1), int64(0), int64(1), int64(0)}},
+		{"small integers", []byte("(K\x07M\x00\x01L42L\nt."), []interface{}{int64(7), int64(256), int64(42)}},
+		{"none", []byte("N."), nil},
+		{"memo", []byte("(X\x01\x00\x00\x00aq\x01h\x01t."), []interface{}{"a", "a"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := unpickle(tt.pickle)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUnpickleInvalid(t *testing.T) {
+	tests := []struct {
+		name   string
+		pickle []byte
+	}{
+		{"empty", []byte{}},
+		{"stop on empty stack", []byte(".")},
+		{"stop with two values", []byte("NN.")},
+		{"mark above the stack", []byte("]K\x01(al.")},
+		{"list without mark", []byte("Nl.")},
+		{"append without list", []byte("NNa.")},
+		{"append to empty stack", []byte("a.")},
+		{"tuple2 of one value", []byte("N\x86.")},
+		{"missing memo", []byte("h\x01.")},
+		{"put on empty stack", []byte("q\x01.")},
+		{"invalid integer", []byte("Iabc\n.")},
+		{"invalid float", []byte("Fabc\n.")},
+		{"invalid string", []byte("S'\n.")},
+		{"unquoted string", []byte("Sabc\n.")},
+		{"mismatched quotes", []byte("S'abc\"\n.")},
+		{"string longer than pickle", []byte("X\xff\x00\x00\x00a.")},
+		{"huge string length", []byte("\x8d\xff\xff\xff\xff\xff\xff\xff\xffa.")},
+		{"global", []byte("cos\nsystem\n.")},
+		{"reduce", []byte("NNR.")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if v, err := unpickle(tt.pickle); err == nil {
+				t.Errorf("got %#v, want an error", v)
+			}
+		})
+	}
+}
+
+// Every prefix of a valid pickle is missing its STOP opcode so it must be
+// rejected, and never make the unpickler panic.
+func TestUnpickleTruncated(t *testing.T) {
+	for _, s := range []string{pickleProtocol0, pickleProtocol1, pickleProtocol2, pickleProtocol4, pickleLong, pickleStrings} {
+		b := pickleFromHex(s)
+		for i := 0; i < len(b); i++ {
+			if v, err := unpickle(b[:i]); err == nil {
+				t.Errorf("%v bytes of %v: got %#v, want an error", i, s, v)
+			}
+		}
+	}
+}
+
+func TestDecodeLong(t *testing.T) {
+	tests := []struct {
+		name string
+		b    []byte
+		want interface{}
+	}{
+		{"empty", []byte{}, int64(0)},
+		{"positive", []byte{0xff, 0x00}, int64(255)},
+		{"negative", []byte{0xff}, int64(-1)},
+		{"negative two bytes", []byte{0xd4, 0xfe}, int64(-300)},
+		{"largest int64", []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x7f}, int64(math.MaxInt64)},
+		{"smallest int64", []byte{0, 0, 0, 0, 0, 0, 0, 0x80}, int64(math.MinInt64)},
+		{"too large for int64", []byte{0, 0, 0, 0, 0, 0, 0, 0, 0x40}, math.Pow(2, 70)},
+		{"too small for int64", []byte{0, 0, 0, 0, 0, 0, 0, 0, 0xc0}, -math.Pow(2, 70)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := decodeLong(tt.b); got != tt.want {
+				t.Errorf("got %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
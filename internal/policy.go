@@ -0,0 +1,226 @@
+package internal
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	pb "github.com/bartmika/tstorage-server/proto"
+)
+
+// rollupSeparator separates the metric name from the step and aggregation in
+// the name of the series holding the rollups, for example
+// `temperature:5m_sum`.
+const rollupSeparator = ":"
+
+// policyFile is the format of the policy file given to the `serve` command.
+//
+// Example:
+//
+//	{
+//	    "policies": [
+//	        {
+//	            "match": "solar_*",
+//	            "retention": "7d",
+//	            "rollups": [
+//	                {"step": "5m", "aggregations": ["avg", "min", "max"], "retention": "90d"},
+//	                {"step": "1h", "aggregations": ["avg"], "retention": "5y"}
+//	            ]
+//	        }
+//	    ]
+//	}
+type policyFile struct {
+	Policies []*policy `json:"policies"`
+}
+
+// policy is the retention and rollup tiers of every metric whose name matches
+// the pattern. The first policy matching a metric is the one used.
+type policy struct {
+	// The pattern, using the syntax of `path.Match`, of the metric names.
+	Match string `json:"match"`
+
+	// How long to keep the raw data points. Zero means forever.
+	Retention jsonDuration `json:"retention"`
+
+	Rollups []*rollupTier `json:"rollups"`
+}
+
+// rollupTier is the step and aggregations of the rollups computed by the
+// rollup job along with how long to keep them.
+type rollupTier struct {
+	Step         jsonDuration `json:"step"`
+	Aggregations []string     `json:"aggregations"`
+	Retention    jsonDuration `json:"retention"`
+
+	// The aggregations the rollups are stored with, which differ from the
+	// configured ones since averages are stored as a sum and a count.
+	stored []pb.Aggregation
+}
+
+// jsonDuration is a duration which is decoded using `parseDuration`.
+type jsonDuration time.Duration
+
+func (d *jsonDuration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+	parsed, err := parseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = jsonDuration(parsed)
+	return nil
+}
+
+// Function returns the duration using `formatDuration`, where zero means
+// forever.
+func (d jsonDuration) String() string {
+	if d <= 0 {
+		return "forever"
+	}
+	return formatDuration(time.Duration(d))
+}
+
+// Function will load and validate the policy file.
+func loadPolicies(filePath string) ([]*policy, error) {
+	b, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read policy file: %w", err)
+	}
+	f := &policyFile{}
+	if err := json.Unmarshal(b, f); err != nil {
+		return nil, fmt.Errorf("failed to decode policy file: %w", err)
+	}
+
+	for _, p := range f.Policies {
+		if _, err := path.Match(p.Match, ""); err != nil {
+			return nil, fmt.Errorf("invalid policy pattern %q: %w", p.Match, err)
+		}
+		for _, tier := range p.Rollups {
+			if tier.Step <= 0 {
+				return nil, fmt.Errorf("policy %q has a rollup without a step", p.Match)
+			}
+			if len(tier.Aggregations) == 0 {
+				return nil, fmt.Errorf("policy %q has a rollup without aggregations", p.Match)
+			}
+			for _, name := range tier.Aggregations {
+				agg, ok := pb.Aggregation_value["AGGREGATION_"+strings.ToUpper(name)]
+				if !ok || agg == int32(pb.Aggregation_AGGREGATION_UNSPECIFIED) {
+					return nil, fmt.Errorf("policy %q has an unsupported rollup aggregation %q", p.Match, name)
+				}
+				stored, ok := rollupAggregations(pb.Aggregation(agg))
+				if !ok {
+					return nil, fmt.Errorf("policy %q has a rollup aggregation %q which can not be merged across buckets", p.Match, name)
+				}
+				for _, a := range stored {
+					if !tier.stores(a) {
+						tier.stored = append(tier.stored, a)
+					}
+				}
+			}
+		}
+
+		// Keep the tiers sorted from the finest to the coarsest step.
+		sort.Slice(p.Rollups, func(i, j int) bool {
+			return p.Rollups[i].Step < p.Rollups[j].Step
+		})
+	}
+	return f.Policies, nil
+}
+
+// Function returns the first policy matching the metric or nil.
+func findPolicy(policies []*policy, metric string) *policy {
+	if metric == "" {
+		return nil
+	}
+	for _, p := range policies {
+		if ok, _ := path.Match(p.Match, metric); ok {
+			return p
+		}
+	}
+	return nil
+}
+
+// Function returns the tier to use for a range query with the given step and
+// aggregation or nil if the raw data points must be used. It is safe to call
+// on a nil policy. The coarsest tier whose step evenly divides the requested
+// step and which stores every aggregation needed is picked.
+func (p *policy) tierFor(step time.Duration, agg pb.Aggregation) *rollupTier {
+	if p == nil {
+		return nil
+	}
+	stored, ok := rollupAggregations(agg)
+	if !ok {
+		return nil
+	}
+	for i := len(p.Rollups) - 1; i >= 0; i-- {
+		tier := p.Rollups[i]
+		if time.Duration(tier.Step) > step || step%time.Duration(tier.Step) != 0 {
+			continue
+		}
+		found := true
+		for _, a := range stored {
+			found = found && tier.stores(a)
+		}
+		if found {
+			return tier
+		}
+	}
+	return nil
+}
+
+// Function returns true if the rollups of the tier are stored with the
+// aggregation.
+func (t *rollupTier) stores(agg pb.Aggregation) bool {
+	for _, a := range t.stored {
+		if a == agg {
+			return true
+		}
+	}
+	return false
+}
+
+// Function returns the aggregations the rollups must have been computed with
+// to answer a query using the aggregation. The boolean is false if the
+// aggregation can not be computed from rollups.
+//
+// DEVELOPERS NOTE:
+// The average of averages is not the average of the data points when the
+// buckets have a different number of data points, so averages are computed
+// from the sum and the count of the data points instead.
+func rollupAggregations(agg pb.Aggregation) ([]pb.Aggregation, bool) {
+	switch agg {
+	case pb.Aggregation_AGGREGATION_AVG:
+		return []pb.Aggregation{pb.Aggregation_AGGREGATION_SUM, pb.Aggregation_AGGREGATION_COUNT}, true
+	case pb.Aggregation_AGGREGATION_MIN, pb.Aggregation_AGGREGATION_MAX, pb.Aggregation_AGGREGATION_SUM, pb.Aggregation_AGGREGATION_COUNT:
+		return []pb.Aggregation{agg}, true
+	}
+	return nil, false
+}
+
+// Function returns the aggregation used to merge the rollups needed by a
+// query using the aggregation into larger buckets, see `rollupAggregations`.
+func mergeAggregation(agg pb.Aggregation) pb.Aggregation {
+	if agg == pb.Aggregation_AGGREGATION_COUNT || agg == pb.Aggregation_AGGREGATION_AVG {
+		return pb.Aggregation_AGGREGATION_SUM
+	}
+	return agg
+}
+
+// Function returns the name identifying the rollups of the metric for the
+// tier, for example `temperature:5m`. The aggregation is appended to get the
+// name of the series holding the rollups, see `rollupMetric`.
+func tierName(metric string, tier *rollupTier) string {
+	return metric + rollupSeparator + formatDuration(time.Duration(tier.Step))
+}
+
+// Function returns the name of the series holding the rollups of the metric
+// for the tier and aggregation, for example `temperature:5m_sum`.
+func rollupMetric(metric string, tier *rollupTier, agg pb.Aggregation) string {
+	return tierName(metric, tier) + "_" + strings.ToLower(strings.TrimPrefix(agg.String(), "AGGREGATION_"))
+}
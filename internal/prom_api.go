@@ -0,0 +1,438 @@
+package internal
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// promMaxPoints is the largest number of steps a range query may have, just
+// like Prometheus.
+const promMaxPoints = 11000
+
+// promVectorSample is a series of an instant vector in the responses of the
+// Prometheus HTTP API.
+type promVectorSample struct {
+	Metric map[string]string `json:"metric"`
+	Value  []interface{}     `json:"value"`
+}
+
+// promMatrixSeries is a series of a range vector in the responses of the
+// Prometheus HTTP API.
+type promMatrixSeries struct {
+	Metric map[string]string `json:"metric"`
+	Values [][]interface{}   `json:"values"`
+}
+
+// promResponse is the envelope of every response of the Prometheus HTTP API.
+type promResponse struct {
+	Status    string      `json:"status"`
+	Data      interface{} `json:"data,omitempty"`
+	ErrorType string      `json:"errorType,omitempty"`
+	Error     string      `json:"error,omitempty"`
+}
+
+// promQueryData is the data of the responses of the query endpoints.
+type promQueryData struct {
+	ResultType string      `json:"resultType"`
+	Result     interface{} `json:"result"`
+}
+
+// Function will evaluate the PromQL expression of the `query` parameter at
+// the time of the `time` parameter, which defaults to now.
+//
+// DEVELOPERS NOTE:
+// The endpoints of the Prometheus HTTP API let Grafana, and other tools
+// supporting Prometheus, use the server as a Prometheus datasource. See
+// `parsePromQL` for the subset of PromQL we support.
+func (s *TStorageServer) handlePromQuery(w http.ResponseWriter, r *http.Request) {
+	impl, t, release, err := s.promRequest(w, r)
+	if err != nil {
+		writePromError(w, err)
+		return
+	}
+	defer release()
+
+	expr, err := parsePromQL(r.Form.Get("query"))
+	if err != nil {
+		writePromError(w, status.Error(codes.InvalidArgument, err.Error()))
+		return
+	}
+	ts, err := parsePromTime(r.Form.Get("time"), time.Now())
+	if err != nil {
+		writePromError(w, err)
+		return
+	}
+	at := timeToUnix(ts, impl.timestampPrecision)
+	e := newPromEvaluator(impl, t, at, at, 1)
+
+	// A range vector selector returns the data points of its window.
+	if sel, ok := expr.(*promSelector); ok && sel.rng != 0 {
+		results, err := e.selectSeries(sel, e.units(sel.rng))
+		if err != nil {
+			writePromError(w, err)
+			return
+		}
+		matrix := []*promMatrixSeries{}
+		for _, ser := range results {
+			out := &promMatrixSeries{Metric: labelsMap(promLabels(ser.metric, ser.labels)), Values: [][]interface{}{}}
+			for _, point := range pointsWindow(ser.points, at-e.units(sel.offset), e.units(sel.rng)) {
+				out.Values = append(out.Values, e.promSample(point.Timestamp, point.Value))
+			}
+			if len(out.Values) > 0 {
+				matrix = append(matrix, out)
+			}
+		}
+		writePromData(w, &promQueryData{ResultType: "matrix", Result: matrix})
+		return
+	}
+
+	v, err := e.eval(expr)
+	if err != nil {
+		writePromError(w, err)
+		return
+	}
+	if v.scalar != nil {
+		writePromData(w, &promQueryData{ResultType: "scalar", Result: e.promSample(at, v.scalar[0])})
+		return
+	}
+	vector := []*promVectorSample{}
+	for _, ser := range v.series {
+		if ser.present[0] {
+			vector = append(vector, &promVectorSample{Metric: labelsMap(ser.labels), Value: e.promSample(at, ser.values[0])})
+		}
+	}
+	writePromData(w, &promQueryData{ResultType: "vector", Result: vector})
+}
+
+// Function will evaluate the PromQL expression of the `query` parameter at
+// every `step` from the `start` to the `end` parameters.
+func (s *TStorageServer) handlePromQueryRange(w http.ResponseWriter, r *http.Request) {
+	impl, t, release, err := s.promRequest(w, r)
+	if err != nil {
+		writePromError(w, err)
+		return
+	}
+	defer release()
+
+	expr, err := parsePromQL(r.Form.Get("query"))
+	if err != nil {
+		writePromError(w, status.Error(codes.InvalidArgument, err.Error()))
+		return
+	}
+	if sel, ok := expr.(*promSelector); ok && sel.rng != 0 {
+		writePromError(w, status.Error(codes.InvalidArgument, "expected an instant vector or scalar, got a range vector"))
+		return
+	}
+	start, err := parsePromTime(r.Form.Get("start"), time.Time{})
+	if err != nil {
+		writePromError(w, err)
+		return
+	}
+	end, err := parsePromTime(r.Form.Get("end"), time.Time{})
+	if err != nil {
+		writePromError(w, err)
+		return
+	}
+	step, err := parsePromStep(r.Form.Get("step"))
+	if err != nil {
+		writePromError(w, err)
+		return
+	}
+	if start.IsZero() || end.IsZero() {
+		writePromError(w, status.Error(codes.InvalidArgument, "start and end must be set"))
+		return
+	}
+	if end.Before(start) {
+		writePromError(w, status.Error(codes.InvalidArgument, "end timestamp must not be before start time"))
+		return
+	}
+	unit := precisionUnit(impl.timestampPrecision)
+	if step < unit {
+		writePromError(w, status.Errorf(codes.InvalidArgument, "step must be at least %v", unit))
+		return
+	}
+	if end.Sub(start)/step >= promMaxPoints {
+		writePromError(w, status.Errorf(codes.InvalidArgument, "exceeded maximum resolution of %d points per timeseries, try decreasing the query resolution (?step=XX)", promMaxPoints))
+		return
+	}
+
+	e := newPromEvaluator(impl, t, timeToUnix(start, impl.timestampPrecision), timeToUnix(end, impl.timestampPrecision), int64(step/unit))
+	v, err := e.eval(expr)
+	if err != nil {
+		writePromError(w, err)
+		return
+	}
+
+	// DEVELOPERS NOTE:
+	// Like Prometheus we return scalars as a series without any labels.
+	if v.scalar != nil {
+		ser := e.newSeries(nil)
+		for i := range e.steps {
+			ser.values[i] = v.scalar[i]
+			ser.present[i] = true
+		}
+		v.series = []*promSeries{ser}
+	}
+
+	matrix := []*promMatrixSeries{}
+	for _, ser := range v.series {
+		out := &promMatrixSeries{Metric: labelsMap(ser.labels), Values: [][]interface{}{}}
+		for i, step := range e.steps {
+			if ser.present[i] {
+				out.Values = append(out.Values, e.promSample(step, ser.values[i]))
+			}
+		}
+		if len(out.Values) > 0 {
+			matrix = append(matrix, out)
+		}
+	}
+	writePromData(w, &promQueryData{ResultType: "matrix", Result: matrix})
+}
+
+// Function will return the labels of every series matching one of the
+// `match[]` selectors within the `start` and `end` parameters.
+func (s *TStorageServer) handlePromSeries(w http.ResponseWriter, r *http.Request) {
+	impl, t, release, err := s.promRequest(w, r)
+	if err != nil {
+		writePromError(w, err)
+		return
+	}
+	defer release()
+
+	if len(r.Form["match[]"]) == 0 {
+		writePromError(w, status.Error(codes.InvalidArgument, "no match[] parameter provided"))
+		return
+	}
+	selectors, err := promSelectors(r, t)
+	if err != nil {
+		writePromError(w, err)
+		return
+	}
+	start, end, err := promTimeRange(r, impl)
+	if err != nil {
+		writePromError(w, err)
+		return
+	}
+
+	seen := map[string]bool{}
+	data := []map[string]string{}
+	for _, sel := range selectors {
+		for _, ser := range impl.catalog.find(sel.metric, sel.matchers, start, end) {
+			id := seriesID(ser.metric, ser.labels)
+			if !seen[id] {
+				seen[id] = true
+				data = append(data, labelsMap(promLabels(ser.metric, ser.labels)))
+			}
+		}
+	}
+	writePromData(w, data)
+}
+
+// Function will return the sorted label names of the series matching one of
+// the `match[]` selectors, or of every series, within the `start` and `end`
+// parameters.
+func (s *TStorageServer) handlePromLabels(w http.ResponseWriter, r *http.Request) {
+	impl, t, release, err := s.promRequest(w, r)
+	if err != nil {
+		writePromError(w, err)
+		return
+	}
+	defer release()
+
+	selectors, err := promSelectors(r, t)
+	if err != nil {
+		writePromError(w, err)
+		return
+	}
+	start, end, err := promTimeRange(r, impl)
+	if err != nil {
+		writePromError(w, err)
+		return
+	}
+
+	names := map[string]bool{}
+	for _, sel := range selectors {
+		if len(impl.catalog.metricNames(sel.matchers, start, end)) > 0 {
+			names[metricNameLabel] = true
+		}
+		for _, name := range impl.catalog.labelNames(sel.metric, sel.matchers, start, end) {
+			names[name] = true
+		}
+	}
+	writePromData(w, sortedKeys(names))
+}
+
+// Function will return the sorted values of the label in the path, such as
+// `/api/v1/label/job/values`, of the series matching one of the `match[]`
+// selectors, or of every series, within the `start` and `end` parameters.
+func (s *TStorageServer) handlePromLabelValues(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(r.URL.Path, "/api/v1/label/")
+	if !strings.HasSuffix(name, "/values") {
+		http.NotFound(w, r)
+		return
+	}
+	name = strings.TrimSuffix(name, "/values")
+	if name == "" {
+		writePromError(w, status.Error(codes.InvalidArgument, "label name must be set"))
+		return
+	}
+
+	impl, t, release, err := s.promRequest(w, r)
+	if err != nil {
+		writePromError(w, err)
+		return
+	}
+	defer release()
+
+	selectors, err := promSelectors(r, t)
+	if err != nil {
+		writePromError(w, err)
+		return
+	}
+	start, end, err := promTimeRange(r, impl)
+	if err != nil {
+		writePromError(w, err)
+		return
+	}
+
+	values := map[string]bool{}
+	for _, sel := range selectors {
+		var found []string
+		if name == metricNameLabel {
+			found = impl.catalog.metricNames(sel.matchers, start, end)
+		} else {
+			found = impl.catalog.labelValues(name, sel.metric, sel.matchers, start, end)
+		}
+		for _, value := range found {
+			values[value] = true
+		}
+	}
+	writePromData(w, sortedKeys(values))
+}
+
+// Function returns the implementation of our gRPC service for the tenant of
+// the request along with the access token, if tokens are enabled, and the
+// function to call once the request is done. The parameters of the request
+// are parsed into `r.Form`.
+func (s *TStorageServer) promRequest(w http.ResponseWriter, r *http.Request) (*TStorageServerImpl, *accessToken, func(), error) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		return nil, nil, nil, status.Error(codes.InvalidArgument, "only GET and POST requests are supported")
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, maxHTTPBodySize)
+	if err := r.ParseForm(); err != nil {
+		return nil, nil, nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	return s.httpTenant(r, scopeRead)
+}
+
+// Function returns the selectors of the `match[]` parameters, or a selector
+// matching every series when there are none, once the token was authorized
+// to access their metrics.
+func promSelectors(r *http.Request, t *accessToken) ([]*promSelector, error) {
+	selectors := []*promSelector{}
+	for _, match := range r.Form["match[]"] {
+		sel, err := parsePromSelector(match)
+		if err != nil {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+		selectors = append(selectors, sel)
+	}
+	if len(selectors) == 0 {
+		selectors = append(selectors, &promSelector{})
+	}
+	if t != nil {
+		for _, sel := range selectors {
+			if err := authorizeMetrics(t, []string{sel.metric}); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return selectors, nil
+}
+
+// Function returns the time range of the `start` and `end` parameters, in
+// the precision of the storage, where `end` is exclusive. The range is not
+// limited when the parameters are missing.
+func promTimeRange(r *http.Request, impl *TStorageServerImpl) (int64, int64, error) {
+	start, end := int64(math.MinInt64), int64(math.MaxInt64)
+	if v := r.Form.Get("start"); v != "" {
+		ts, err := parsePromTime(v, time.Time{})
+		if err != nil {
+			return 0, 0, err
+		}
+		start = timeToUnix(ts, impl.timestampPrecision)
+	}
+	if v := r.Form.Get("end"); v != "" {
+		ts, err := parsePromTime(v, time.Time{})
+		if err != nil {
+			return 0, 0, err
+		}
+		end = timeToUnix(ts, impl.timestampPrecision) + 1
+	}
+	return start, end, nil
+}
+
+// Function will parse the time parameter, which is either a unix timestamp
+// in seconds, with optional decimals, or an RFC 3339 date. The default is
+// returned for an empty parameter.
+func parsePromTime(s string, def time.Time) (time.Time, error) {
+	if s == "" {
+		return def, nil
+	}
+	if f, err := strconv.ParseFloat(s, 64); err == nil {
+		sec, frac := math.Modf(f)
+		return time.Unix(int64(sec), int64(math.Round(frac*1e9))), nil
+	}
+	t, err := time.Parse(time.RFC3339Nano, s)
+	if err != nil {
+		return time.Time{}, status.Errorf(codes.InvalidArgument, "cannot parse %q to a valid timestamp", s)
+	}
+	return t, nil
+}
+
+// Function will parse the step parameter, which is either a number of
+// seconds, with optional decimals, or a duration such as `1m`.
+func parsePromStep(s string) (time.Duration, error) {
+	if f, err := strconv.ParseFloat(s, 64); err == nil {
+		return time.Duration(f * float64(time.Second)), nil
+	}
+	d, err := parseDuration(s)
+	if err != nil {
+		return 0, status.Errorf(codes.InvalidArgument, "cannot parse %q to a valid duration", s)
+	}
+	return d, nil
+}
+
+// Function returns the sample as the `[<unix seconds>, "<value>"]` pair used
+// by the Prometheus HTTP API.
+func (e *promEvaluator) promSample(t int64, v float64) []interface{} {
+	return []interface{}{float64(unixToMillis(t, e.impl.timestampPrecision)) / 1000, formatPromValue(v)}
+}
+
+// Function will write the successful response of the Prometheus HTTP API.
+func writePromData(w http.ResponseWriter, data interface{}) {
+	writeJSON(w, http.StatusOK, &promResponse{Status: "success", Data: data})
+}
+
+// Function will write the error response of the Prometheus HTTP API.
+func writePromError(w http.ResponseWriter, err error) {
+	code := httpStatusCode(err)
+	errorType := "execution"
+	switch code {
+	case http.StatusBadRequest:
+		errorType = "bad_data"
+	case http.StatusNotFound:
+		errorType = "not_found"
+	case http.StatusServiceUnavailable:
+		errorType = "unavailable"
+	case http.StatusInternalServerError:
+		errorType = "internal"
+	}
+	writeJSON(w, code, &promResponse{Status: "error", ErrorType: errorType, Error: status.Convert(err).Message()})
+}
@@ -0,0 +1,591 @@
+package internal
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// promExpr is a node of a parsed PromQL expression, which is one of the
+// `prom*` expression types below.
+type promExpr interface{}
+
+// promNumber is a number literal, such as `1` or `0.5`.
+type promNumber struct {
+	value float64
+}
+
+// promSelector selects series with label matchers, such as `up{job="api"}`.
+// A selector with a range, such as `up[5m]`, is a range vector selector and
+// can only be used as the argument of a range function.
+type promSelector struct {
+	// The metric of the series when the selector matches a single metric
+	// name, otherwise empty.
+	metric   string
+	matchers []*labelMatcher
+	rng      time.Duration
+	offset   time.Duration
+}
+
+// promCall is a call of a range function, such as `rate(requests[5m])`.
+type promCall struct {
+	fn   string
+	args []promExpr
+}
+
+// promAggregation aggregates the series of a vector, such as
+// `sum by (job) (up)`.
+type promAggregation struct {
+	op       string
+	param    promExpr
+	expr     promExpr
+	grouping []string
+	without  bool
+}
+
+// promBinary is an arithmetic or comparison operation, such as `up * 100`.
+type promBinary struct {
+	op  string
+	lhs promExpr
+	rhs promExpr
+}
+
+// promRangeFunctions are the supported range functions along with the
+// number of arguments coming before the range vector.
+var promRangeFunctions = map[string]int{
+	"rate":               0,
+	"irate":              0,
+	"increase":           0,
+	"delta":              0,
+	"idelta":             0,
+	"avg_over_time":      0,
+	"sum_over_time":      0,
+	"min_over_time":      0,
+	"max_over_time":      0,
+	"count_over_time":    0,
+	"stddev_over_time":   0,
+	"last_over_time":     0,
+	"quantile_over_time": 1,
+}
+
+// promAggregations are the supported aggregation operators.
+var promAggregations = map[string]bool{
+	"sum":      true,
+	"avg":      true,
+	"min":      true,
+	"max":      true,
+	"count":    true,
+	"stddev":   true,
+	"quantile": true,
+}
+
+// promOperators are the supported binary operators along with their
+// precedence, where a higher precedence binds tighter.
+var promOperators = map[string]int{
+	"==": 1,
+	"!=": 1,
+	"<=": 1,
+	">=": 1,
+	"<":  1,
+	">":  1,
+	"+":  2,
+	"-":  2,
+	"*":  3,
+	"/":  3,
+	"%":  3,
+	"^":  4,
+}
+
+// promParser is a recursive descent parser for the subset of PromQL we
+// support.
+type promParser struct {
+	input string
+	pos   int
+}
+
+// Function will parse the PromQL expression.
+//
+// DEVELOPERS NOTE:
+// We support number literals, selectors (with ranges and offsets), the range
+// functions of `promRangeFunctions`, the aggregations of `promAggregations`
+// with `by` and `without` and the arithmetic and comparison operators, where
+// comparisons filter the series. Unlike Prometheus, metric names may contain
+// dots so metrics written with the Graphite or StatsD listeners can be
+// selected by name.
+func parsePromQL(input string) (promExpr, error) {
+	p := &promParser{input: input}
+	expr, err := p.parseBinary(0)
+	if err != nil {
+		return nil, err
+	}
+	p.skipSpaces()
+	if p.pos < len(p.input) {
+		return nil, p.errorf("unexpected %q", p.input[p.pos:])
+	}
+	return expr, nil
+}
+
+// Function will parse the series selector used by the `match[]` parameter
+// of our metadata endpoints.
+func parsePromSelector(input string) (*promSelector, error) {
+	expr, err := parsePromQL(input)
+	if err != nil {
+		return nil, err
+	}
+	sel, ok := expr.(*promSelector)
+	if !ok || sel.rng != 0 {
+		return nil, fmt.Errorf("invalid series selector %q", input)
+	}
+	return sel, nil
+}
+
+func (p *promParser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("parse error at char %d: %s", p.pos+1, fmt.Sprintf(format, args...))
+}
+
+func (p *promParser) skipSpaces() {
+	for p.pos < len(p.input) && strings.IndexByte(" \t\r\n", p.input[p.pos]) >= 0 {
+		p.pos++
+	}
+}
+
+// Function returns the next character after any spaces, or zero at the end
+// of the input.
+func (p *promParser) peek() byte {
+	p.skipSpaces()
+	if p.pos < len(p.input) {
+		return p.input[p.pos]
+	}
+	return 0
+}
+
+// Function will consume the next character, which must be `c`.
+func (p *promParser) expect(c byte) error {
+	if p.peek() != c {
+		if p.pos >= len(p.input) {
+			return p.errorf("unexpected end of input, expected %q", c)
+		}
+		return p.errorf("unexpected %q, expected %q", p.input[p.pos], c)
+	}
+	p.pos++
+	return nil
+}
+
+// Function will parse the binary operations whose operators have at least
+// the given precedence.
+func (p *promParser) parseBinary(minPrecedence int) (promExpr, error) {
+	lhs, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		op := p.peekOperator()
+		precedence, ok := promOperators[op]
+		if !ok || precedence < minPrecedence {
+			return lhs, nil
+		}
+		p.pos += len(op)
+
+		// Every operator is left associative except for `^`.
+		next := precedence + 1
+		if op == "^" {
+			next = precedence
+		}
+		rhs, err := p.parseBinary(next)
+		if err != nil {
+			return nil, err
+		}
+		lhs = &promBinary{op: op, lhs: lhs, rhs: rhs}
+	}
+}
+
+// Function returns the binary operator at the current position, if any,
+// without consuming it.
+func (p *promParser) peekOperator() string {
+	p.skipSpaces()
+	rest := p.input[p.pos:]
+	for _, op := range []string{"==", "!=", "<=", ">="} {
+		if strings.HasPrefix(rest, op) {
+			return op
+		}
+	}
+	if len(rest) > 0 && strings.IndexByte("+-*/%^<>", rest[0]) >= 0 {
+		return rest[:1]
+	}
+	return ""
+}
+
+// Function will parse a primary expression along with its unary operators,
+// which bind tighter than every binary operator but `^` so `-2^2` is `-4`
+// like in Prometheus.
+func (p *promParser) parseUnary() (promExpr, error) {
+	switch p.peek() {
+	case '-':
+		p.pos++
+		expr, err := p.parseBinary(promOperators["^"])
+		if err != nil {
+			return nil, err
+		}
+		if n, ok := expr.(*promNumber); ok {
+			return &promNumber{value: -n.value}, nil
+		}
+		return &promBinary{op: "-", lhs: &promNumber{}, rhs: expr}, nil
+	case '+':
+		p.pos++
+		return p.parseBinary(promOperators["^"])
+	}
+	return p.parsePrimary()
+}
+
+func (p *promParser) parsePrimary() (promExpr, error) {
+	c := p.peek()
+	switch {
+	case c == 0:
+		return nil, p.errorf("unexpected end of input")
+	case c == '(':
+		p.pos++
+		expr, err := p.parseBinary(0)
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(')'); err != nil {
+			return nil, err
+		}
+		return expr, nil
+	case c == '{':
+		return p.parseSelector("")
+	case c == '.' || (c >= '0' && c <= '9'):
+		return p.parseNumber()
+	case isPromNameStart(c):
+		start := p.pos
+		name := p.parseName()
+		next := p.peek()
+		if promAggregations[name] && (next == '(' || p.peekKeyword("by") || p.peekKeyword("without")) {
+			return p.parseAggregation(name)
+		}
+		if next == '(' {
+			if _, ok := promRangeFunctions[name]; !ok {
+				p.pos = start
+				return nil, p.errorf("unknown function %q", name)
+			}
+			return p.parseCall(name)
+		}
+		switch strings.ToLower(name) {
+		case "inf":
+			return &promNumber{value: math.Inf(1)}, nil
+		case "nan":
+			return &promNumber{value: math.NaN()}, nil
+		}
+		return p.parseSelector(name)
+	}
+	return nil, p.errorf("unexpected %q", c)
+}
+
+func isPromNameStart(c byte) bool {
+	return c == '_' || c == ':' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isPromNameChar(c byte) bool {
+	return isPromNameStart(c) || c == '.' || (c >= '0' && c <= '9')
+}
+
+// Function will parse a metric or label name.
+func (p *promParser) parseName() string {
+	p.skipSpaces()
+	start := p.pos
+	if p.pos < len(p.input) && isPromNameStart(p.input[p.pos]) {
+		p.pos++
+		for p.pos < len(p.input) && isPromNameChar(p.input[p.pos]) {
+			p.pos++
+		}
+	}
+	return p.input[start:p.pos]
+}
+
+// Function returns true if the keyword is at the current position.
+func (p *promParser) peekKeyword(keyword string) bool {
+	p.skipSpaces()
+	rest := p.input[p.pos:]
+	if !strings.HasPrefix(rest, keyword) {
+		return false
+	}
+	return len(rest) == len(keyword) || !isPromNameChar(rest[len(keyword)])
+}
+
+func (p *promParser) parseNumber() (promExpr, error) {
+	start := p.pos
+	for p.pos < len(p.input) && strings.IndexByte("0123456789.eE", p.input[p.pos]) >= 0 {
+		// Allow the sign of an exponent, such as `1e-3`.
+		if (p.input[p.pos] == 'e' || p.input[p.pos] == 'E') && p.pos+1 < len(p.input) && strings.IndexByte("+-", p.input[p.pos+1]) >= 0 {
+			p.pos++
+		}
+		p.pos++
+	}
+	text := p.input[start:p.pos]
+	v, err := strconv.ParseFloat(text, 64)
+	if err != nil {
+		p.pos = start
+		return nil, p.errorf("invalid number %q", text)
+	}
+	return &promNumber{value: v}, nil
+}
+
+// Function will parse the label matchers, range and offset of a selector
+// following the metric name, if any.
+func (p *promParser) parseSelector(name string) (promExpr, error) {
+	sel := &promSelector{}
+	if name != "" {
+		m, _ := newLabelMatcher(matchEqual, metricNameLabel, name)
+		sel.matchers = append(sel.matchers, m)
+		sel.metric = name
+	}
+
+	if p.peek() == '{' {
+		p.pos++
+		for p.peek() != '}' {
+			label := p.parseName()
+			if label == "" {
+				return nil, p.errorf("expected label name")
+			}
+			typ, err := p.parseMatchType()
+			if err != nil {
+				return nil, err
+			}
+			value, err := p.parseString()
+			if err != nil {
+				return nil, err
+			}
+			m, err := newLabelMatcher(typ, label, value)
+			if err != nil {
+				return nil, err
+			}
+			sel.matchers = append(sel.matchers, m)
+			if label == metricNameLabel && typ == matchEqual {
+				if sel.metric != "" && sel.metric != value {
+					return nil, p.errorf("metric name must not be set twice")
+				}
+				sel.metric = value
+			}
+			if p.peek() != ',' {
+				break
+			}
+			p.pos++
+		}
+		if err := p.expect('}'); err != nil {
+			return nil, err
+		}
+	}
+
+	// DEVELOPERS NOTE:
+	// Like Prometheus we refuse selectors which could match every series,
+	// such as `{job=~".*"}`, since they are most likely a mistake and would
+	// have to read the whole storage.
+	empty := true
+	for _, m := range sel.matchers {
+		if !m.matches("") {
+			empty = false
+		}
+	}
+	if empty {
+		return nil, p.errorf("vector selector must contain at least one non-empty matcher")
+	}
+
+	if p.peek() == '[' {
+		p.pos++
+		d, err := p.parseDuration()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(']'); err != nil {
+			return nil, err
+		}
+		sel.rng = d
+	}
+	if p.peekKeyword("offset") {
+		p.pos += len("offset")
+		d, err := p.parseDuration()
+		if err != nil {
+			return nil, err
+		}
+		sel.offset = d
+	}
+	return sel, nil
+}
+
+func (p *promParser) parseMatchType() (matchType, error) {
+	p.skipSpaces()
+	rest := p.input[p.pos:]
+	switch {
+	case strings.HasPrefix(rest, "=~"):
+		p.pos += 2
+		return matchRegexp, nil
+	case strings.HasPrefix(rest, "!~"):
+		p.pos += 2
+		return matchNotRegexp, nil
+	case strings.HasPrefix(rest, "!="):
+		p.pos += 2
+		return matchNotEqual, nil
+	case strings.HasPrefix(rest, "="):
+		p.pos++
+		return matchEqual, nil
+	}
+	return 0, p.errorf("expected label matching operator")
+}
+
+// Function will parse a string quoted with double quotes, single quotes or
+// backticks, where backticks do not support escape sequences.
+func (p *promParser) parseString() (string, error) {
+	quote := p.peek()
+	if quote != '"' && quote != '\'' && quote != '`' {
+		return "", p.errorf("expected string")
+	}
+	start := p.pos
+	p.pos++
+	for p.pos < len(p.input) && p.input[p.pos] != quote {
+		if p.input[p.pos] == '\\' && quote != '`' {
+			p.pos++
+		}
+		p.pos++
+	}
+	if p.pos >= len(p.input) {
+		p.pos = start
+		return "", p.errorf("unterminated string")
+	}
+	p.pos++
+
+	body := p.input[start+1 : p.pos-1]
+	if quote == '`' {
+		return body, nil
+	}
+	if quote == '\'' {
+		// Turn it into a double quoted string for `strconv.Unquote`.
+		body = strings.ReplaceAll(body, `\'`, `'`)
+		body = strings.ReplaceAll(body, `"`, `\"`)
+	}
+	s, err := strconv.Unquote(`"` + body + `"`)
+	if err != nil {
+		p.pos = start
+		return "", p.errorf("invalid string %s", p.input[start:start+len(body)+2])
+	}
+	return s, nil
+}
+
+// Function will parse a duration, such as `5m` or `1h30m`.
+func (p *promParser) parseDuration() (time.Duration, error) {
+	p.skipSpaces()
+	start := p.pos
+	for p.pos < len(p.input) && isPromDurationChar(p.input[p.pos]) {
+		p.pos++
+	}
+	d, err := parseDuration(p.input[start:p.pos])
+	if err != nil {
+		p.pos = start
+		return 0, p.errorf("%v", err)
+	}
+	if d <= 0 {
+		p.pos = start
+		return 0, p.errorf("duration must be greater than zero")
+	}
+	return d, nil
+}
+
+func isPromDurationChar(c byte) bool {
+	return (c >= '0' && c <= '9') || (c >= 'a' && c <= 'z')
+}
+
+// Function will parse the `by` or `without` clause of an aggregation.
+func (p *promParser) parseGrouping(agg *promAggregation) error {
+	if agg.grouping != nil {
+		return p.errorf("grouping must not be set twice")
+	}
+	agg.without = p.peekKeyword("without")
+	p.parseName()
+	if err := p.expect('('); err != nil {
+		return err
+	}
+	agg.grouping = []string{}
+	for p.peek() != ')' {
+		label := p.parseName()
+		if label == "" {
+			return p.errorf("expected label name")
+		}
+		agg.grouping = append(agg.grouping, label)
+		if p.peek() != ',' {
+			break
+		}
+		p.pos++
+	}
+	return p.expect(')')
+}
+
+func (p *promParser) parseAggregation(op string) (promExpr, error) {
+	agg := &promAggregation{op: op}
+	if p.peekKeyword("by") || p.peekKeyword("without") {
+		if err := p.parseGrouping(agg); err != nil {
+			return nil, err
+		}
+	}
+	if err := p.expect('('); err != nil {
+		return nil, err
+	}
+	if op == "quantile" {
+		param, err := p.parseBinary(0)
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(','); err != nil {
+			return nil, err
+		}
+		agg.param = param
+	}
+	expr, err := p.parseBinary(0)
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expect(')'); err != nil {
+		return nil, err
+	}
+	agg.expr = expr
+	if p.peekKeyword("by") || p.peekKeyword("without") {
+		if err := p.parseGrouping(agg); err != nil {
+			return nil, err
+		}
+	}
+	return agg, nil
+}
+
+func (p *promParser) parseCall(fn string) (promExpr, error) {
+	start := p.pos
+	if err := p.expect('('); err != nil {
+		return nil, err
+	}
+	call := &promCall{fn: fn}
+	for p.peek() != ')' {
+		arg, err := p.parseBinary(0)
+		if err != nil {
+			return nil, err
+		}
+		call.args = append(call.args, arg)
+		if p.peek() != ',' {
+			break
+		}
+		p.pos++
+	}
+	if err := p.expect(')'); err != nil {
+		return nil, err
+	}
+
+	// Every range function takes a range vector as its last argument.
+	params := promRangeFunctions[fn]
+	if len(call.args) != params+1 {
+		p.pos = start
+		return nil, p.errorf("%v expects %d arguments but got %d", fn, params+1, len(call.args))
+	}
+	if sel, ok := call.args[params].(*promSelector); !ok || sel.rng == 0 {
+		p.pos = start
+		return nil, p.errorf("%v expects a range vector as its last argument", fn)
+	}
+	return call, nil
+}
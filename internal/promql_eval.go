@@ -0,0 +1,497 @@
+package internal
+
+import (
+	"math"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/nakabonne/tstorage"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/bartmika/tstorage-server/proto"
+)
+
+// promLookback is how far back an instant vector selector looks for the
+// latest data point of a series, just like Prometheus.
+const promLookback = 5 * time.Minute
+
+// promOverTimeAggregations maps the `<aggregation>_over_time` range functions
+// to our aggregations.
+var promOverTimeAggregations = map[string]pb.Aggregation{
+	"avg_over_time":    pb.Aggregation_AGGREGATION_AVG,
+	"sum_over_time":    pb.Aggregation_AGGREGATION_SUM,
+	"min_over_time":    pb.Aggregation_AGGREGATION_MIN,
+	"max_over_time":    pb.Aggregation_AGGREGATION_MAX,
+	"count_over_time":  pb.Aggregation_AGGREGATION_COUNT,
+	"stddev_over_time": pb.Aggregation_AGGREGATION_STDDEV,
+}
+
+// promAggregationOperators maps the aggregation operators, besides `quantile`
+// which takes a parameter, to our aggregations.
+var promAggregationOperators = map[string]pb.Aggregation{
+	"sum":    pb.Aggregation_AGGREGATION_SUM,
+	"avg":    pb.Aggregation_AGGREGATION_AVG,
+	"min":    pb.Aggregation_AGGREGATION_MIN,
+	"max":    pb.Aggregation_AGGREGATION_MAX,
+	"count":  pb.Aggregation_AGGREGATION_COUNT,
+	"stddev": pb.Aggregation_AGGREGATION_STDDEV,
+}
+
+// promSeries is a series of an evaluated instant vector with a value at
+// every step of the query where `present` is true.
+type promSeries struct {
+	labels  []tstorage.Label
+	values  []float64
+	present []bool
+}
+
+// promValue is the result of evaluating an expression at every step of the
+// query, which is either a scalar or an instant vector.
+type promValue struct {
+	scalar []float64
+	series []*promSeries
+}
+
+// promEvaluator evaluates a PromQL expression at every step of a query using
+// the storage of a tenant.
+type promEvaluator struct {
+	impl  *TStorageServerImpl
+	token *accessToken
+
+	// The timestamps, in the precision of the storage, the expression is
+	// evaluated at.
+	steps []int64
+}
+
+// Function will create an evaluator for the steps from `start` to `end`
+// (inclusive) which are `step` apart, all in the precision of the storage.
+func newPromEvaluator(impl *TStorageServerImpl, token *accessToken, start int64, end int64, step int64) *promEvaluator {
+	e := &promEvaluator{impl: impl, token: token}
+	for t := start; t <= end; t += step {
+		e.steps = append(e.steps, t)
+	}
+	return e
+}
+
+// Function returns the duration in the precision of the storage.
+func (e *promEvaluator) units(d time.Duration) int64 {
+	return int64(d / precisionUnit(e.impl.timestampPrecision))
+}
+
+// Function will evaluate the expression at every step.
+func (e *promEvaluator) eval(expr promExpr) (*promValue, error) {
+	switch expr := expr.(type) {
+	case *promNumber:
+		return e.constant(expr.value), nil
+	case *promSelector:
+		if expr.rng != 0 {
+			return nil, status.Error(codes.InvalidArgument, "range vector must be used with a range function")
+		}
+		return e.evalSelector(expr)
+	case *promCall:
+		return e.evalCall(expr)
+	case *promAggregation:
+		return e.evalAggregation(expr)
+	case *promBinary:
+		return e.evalBinary(expr)
+	}
+	return nil, status.Errorf(codes.Internal, "unsupported expression %T", expr)
+}
+
+// Function returns a scalar with the same value at every step.
+func (e *promEvaluator) constant(v float64) *promValue {
+	scalar := make([]float64, len(e.steps))
+	for i := range scalar {
+		scalar[i] = v
+	}
+	return &promValue{scalar: scalar}
+}
+
+// Function will select the series of the selector with the data points
+// needed to evaluate every step, where `window` is how far back each step
+// looks, in the precision of the storage.
+func (e *promEvaluator) selectSeries(sel *promSelector, window int64) ([]*series, error) {
+	if e.token != nil {
+		if err := authorizeMetrics(e.token, []string{sel.metric}); err != nil {
+			return nil, err
+		}
+	}
+	offset := e.units(sel.offset)
+	return e.impl.selectSeries(&seriesQuery{
+		metric:   sel.metric,
+		matchers: sel.matchers,
+		start:    e.steps[0] - offset - window + 1,
+		end:      e.steps[len(e.steps)-1] - offset + 1,
+	})
+}
+
+// Function returns the data points of the window ending at `end`
+// (inclusive) and which is `window` long. The points must be sorted.
+func pointsWindow(points []*tstorage.DataPoint, end int64, window int64) []*tstorage.DataPoint {
+	from := sort.Search(len(points), func(i int) bool { return points[i].Timestamp > end-window })
+	to := sort.Search(len(points), func(i int) bool { return points[i].Timestamp > end })
+	return points[from:to]
+}
+
+// Function will evaluate the instant vector selector, where every step has
+// the latest value within `promLookback` of the step.
+func (e *promEvaluator) evalSelector(sel *promSelector) (*promValue, error) {
+	lookback := e.units(promLookback)
+	results, err := e.selectSeries(sel, lookback)
+	if err != nil {
+		return nil, err
+	}
+	offset := e.units(sel.offset)
+
+	v := &promValue{}
+	for _, ser := range results {
+		out := e.newSeries(promLabels(ser.metric, ser.labels))
+		for i, t := range e.steps {
+			if window := pointsWindow(ser.points, t-offset, lookback); len(window) > 0 {
+				out.values[i] = window[len(window)-1].Value
+				out.present[i] = true
+			}
+		}
+		v.series = append(v.series, out)
+	}
+	return v, nil
+}
+
+// Function will evaluate the range function for the data points of the
+// range vector before every step.
+func (e *promEvaluator) evalCall(call *promCall) (*promValue, error) {
+	params := promRangeFunctions[call.fn]
+	var param *promValue
+	if params > 0 {
+		var err error
+		if param, err = e.evalScalar(call.args[0]); err != nil {
+			return nil, err
+		}
+	}
+	sel := call.args[params].(*promSelector)
+	rng := e.units(sel.rng)
+	results, err := e.selectSeries(sel, rng)
+	if err != nil {
+		return nil, err
+	}
+	offset := e.units(sel.offset)
+
+	v := &promValue{}
+	for _, ser := range results {
+		out := e.newSeries(dropMetricName(promLabels(ser.metric, ser.labels)))
+		for i, t := range e.steps {
+			window := pointsWindow(ser.points, t-offset, rng)
+			var q float64
+			if param != nil {
+				q = param.scalar[i]
+			}
+			value, ok, err := e.rangeFunction(call.fn, window, sel.rng, q)
+			if err != nil {
+				return nil, err
+			}
+			out.values[i] = value
+			out.present[i] = ok
+		}
+		v.series = append(v.series, out)
+	}
+	return v, nil
+}
+
+// Function will apply the range function to the data points of a window
+// which is `rng` long. The boolean is false if the window does not have
+// enough data points to compute a value.
+//
+// DEVELOPERS NOTE:
+// The counter functions are computed exactly like our `RangeQuery` does, so
+// they do not extrapolate to the edges of the window like Prometheus does.
+func (e *promEvaluator) rangeFunction(fn string, window []*tstorage.DataPoint, rng time.Duration, q float64) (float64, bool, error) {
+	switch fn {
+	case "rate":
+		return counterValue(pb.Function_FUNCTION_RATE, window, rng.Seconds(), e.impl.timestampPrecision)
+	case "irate":
+		return counterValue(pb.Function_FUNCTION_IRATE, window, rng.Seconds(), e.impl.timestampPrecision)
+	case "increase":
+		return counterValue(pb.Function_FUNCTION_INCREASE, window, rng.Seconds(), e.impl.timestampPrecision)
+	case "delta":
+		return counterValue(pb.Function_FUNCTION_DELTA, window, rng.Seconds(), e.impl.timestampPrecision)
+	case "idelta":
+		if len(window) < 2 {
+			return 0, false, nil
+		}
+		return window[len(window)-1].Value - window[len(window)-2].Value, true, nil
+	}
+
+	if len(window) == 0 {
+		return 0, false, nil
+	}
+	switch fn {
+	case "last_over_time":
+		return window[len(window)-1].Value, true, nil
+	case "quantile_over_time":
+		return quantile(math.Max(0, math.Min(1, q)), pointValues(window)), true, nil
+	}
+	agg, ok := promOverTimeAggregations[fn]
+	if !ok {
+		return 0, false, status.Errorf(codes.InvalidArgument, "unsupported function %q", fn)
+	}
+	v, err := aggregateValues(agg, pointValues(window))
+	return v, err == nil, err
+}
+
+// Function will evaluate the aggregation by grouping the series of the
+// vector at every step.
+func (e *promEvaluator) evalAggregation(agg *promAggregation) (*promValue, error) {
+	var param *promValue
+	if agg.param != nil {
+		var err error
+		if param, err = e.evalScalar(agg.param); err != nil {
+			return nil, err
+		}
+	}
+	inner, err := e.evalVector(agg.expr)
+	if err != nil {
+		return nil, err
+	}
+
+	// Assign every series to its group while keeping the groups in the order
+	// they were first seen.
+	groups := map[string][]*promSeries{}
+	outputs := []*promSeries{}
+	for _, ser := range inner.series {
+		labels := groupLabels(ser.labels, agg.grouping, agg.without)
+		key := seriesKey(labels)
+		if _, ok := groups[key]; !ok {
+			outputs = append(outputs, e.newSeries(labels))
+		}
+		groups[key] = append(groups[key], ser)
+	}
+
+	v := &promValue{}
+	for _, out := range outputs {
+		members := groups[seriesKey(out.labels)]
+		for i := range e.steps {
+			values := []float64{}
+			for _, ser := range members {
+				if ser.present[i] {
+					values = append(values, ser.values[i])
+				}
+			}
+			if len(values) == 0 {
+				continue
+			}
+			if agg.op == "quantile" {
+				out.values[i] = quantile(math.Max(0, math.Min(1, param.scalar[i])), values)
+			} else {
+				if out.values[i], err = aggregateValues(promAggregationOperators[agg.op], values); err != nil {
+					return nil, status.Error(codes.InvalidArgument, err.Error())
+				}
+			}
+			out.present[i] = true
+		}
+		v.series = append(v.series, out)
+	}
+	return v, nil
+}
+
+// Function returns the labels of the group the series belongs to. The metric
+// name is always removed.
+func groupLabels(labels []tstorage.Label, grouping []string, without bool) []tstorage.Label {
+	listed := map[string]bool{}
+	for _, name := range grouping {
+		listed[name] = true
+	}
+	out := []tstorage.Label{}
+	for _, label := range labels {
+		if label.Name == metricNameLabel {
+			continue
+		}
+		if listed[label.Name] != without {
+			out = append(out, label)
+		}
+	}
+	return out
+}
+
+// Function will evaluate the arithmetic or comparison operation between two
+// scalars, a vector and a scalar or two vectors. Series of two vectors are
+// matched when they have the same labels besides the metric name.
+func (e *promEvaluator) evalBinary(bin *promBinary) (*promValue, error) {
+	lhs, err := e.eval(bin.lhs)
+	if err != nil {
+		return nil, err
+	}
+	rhs, err := e.eval(bin.rhs)
+	if err != nil {
+		return nil, err
+	}
+	comparison := promOperators[bin.op] == 1
+
+	if lhs.scalar != nil && rhs.scalar != nil {
+		if comparison {
+			return nil, status.Error(codes.InvalidArgument, "comparisons between scalars are not supported")
+		}
+		scalar := make([]float64, len(e.steps))
+		for i := range scalar {
+			scalar[i], _ = applyPromOperator(bin.op, lhs.scalar[i], rhs.scalar[i])
+		}
+		return &promValue{scalar: scalar}, nil
+	}
+
+	// Every series of the vector on the left is matched with at most one
+	// series of the vector on the right.
+	matches := map[string]*promSeries{}
+	if rhs.scalar == nil && lhs.scalar == nil {
+		for _, ser := range rhs.series {
+			key := seriesKey(dropMetricName(ser.labels))
+			if _, ok := matches[key]; ok {
+				return nil, status.Error(codes.InvalidArgument, "found duplicate series on the right hand side of the operation")
+			}
+			matches[key] = ser
+		}
+	}
+	vector := lhs
+	if lhs.scalar != nil {
+		vector = rhs
+	}
+
+	v := &promValue{}
+	for _, ser := range vector.series {
+		var other *promSeries
+		if lhs.scalar == nil && rhs.scalar == nil {
+			if other = matches[seriesKey(dropMetricName(ser.labels))]; other == nil {
+				continue
+			}
+		}
+		labels := ser.labels
+		if !comparison {
+			labels = dropMetricName(labels)
+		}
+		out := e.newSeries(labels)
+		for i := range e.steps {
+			if !ser.present[i] || (other != nil && !other.present[i]) {
+				continue
+			}
+			var a, b float64
+			switch {
+			case other != nil:
+				a, b = ser.values[i], other.values[i]
+			case lhs.scalar != nil:
+				a, b = lhs.scalar[i], ser.values[i]
+			default:
+				a, b = ser.values[i], rhs.scalar[i]
+			}
+			result, keep := applyPromOperator(bin.op, a, b)
+			if comparison {
+				// Comparisons keep the value of the vector.
+				result = ser.values[i]
+			}
+			out.values[i] = result
+			out.present[i] = keep
+		}
+		v.series = append(v.series, out)
+	}
+	return v, nil
+}
+
+// Function returns the result of the operator, where the boolean is the
+// result of comparison operators and always true for arithmetic operators.
+func applyPromOperator(op string, a float64, b float64) (float64, bool) {
+	switch op {
+	case "+":
+		return a + b, true
+	case "-":
+		return a - b, true
+	case "*":
+		return a * b, true
+	case "/":
+		return a / b, true
+	case "%":
+		return math.Mod(a, b), true
+	case "^":
+		return math.Pow(a, b), true
+	case "==":
+		return 0, a == b
+	case "!=":
+		return 0, a != b
+	case "<":
+		return 0, a < b
+	case ">":
+		return 0, a > b
+	case "<=":
+		return 0, a <= b
+	case ">=":
+		return 0, a >= b
+	}
+	return 0, false
+}
+
+// Function will evaluate the expression which must result in a scalar.
+func (e *promEvaluator) evalScalar(expr promExpr) (*promValue, error) {
+	v, err := e.eval(expr)
+	if err != nil {
+		return nil, err
+	}
+	if v.scalar == nil {
+		return nil, status.Error(codes.InvalidArgument, "expected a scalar parameter")
+	}
+	return v, nil
+}
+
+// Function will evaluate the expression which must result in an instant
+// vector.
+func (e *promEvaluator) evalVector(expr promExpr) (*promValue, error) {
+	v, err := e.eval(expr)
+	if err != nil {
+		return nil, err
+	}
+	if v.scalar != nil {
+		return nil, status.Error(codes.InvalidArgument, "expected an instant vector")
+	}
+	return v, nil
+}
+
+// Function returns a new series without any values.
+func (e *promEvaluator) newSeries(labels []tstorage.Label) *promSeries {
+	return &promSeries{
+		labels:  labels,
+		values:  make([]float64, len(e.steps)),
+		present: make([]bool, len(e.steps)),
+	}
+}
+
+// Function returns the labels of the series with the metric as the
+// `__name__` label, sorted by name.
+func promLabels(metric string, labels []tstorage.Label) []tstorage.Label {
+	out := make([]tstorage.Label, 0, len(labels)+1)
+	out = append(out, tstorage.Label{Name: metricNameLabel, Value: metric})
+	out = append(out, labels...)
+	return sortedLabels(out)
+}
+
+// Function returns the labels without the `__name__` label.
+func dropMetricName(labels []tstorage.Label) []tstorage.Label {
+	out := make([]tstorage.Label, 0, len(labels))
+	for _, label := range labels {
+		if label.Name != metricNameLabel {
+			out = append(out, label)
+		}
+	}
+	return out
+}
+
+// Function returns the labels as a map, which is how the Prometheus HTTP API
+// returns the labels of a series.
+func labelsMap(labels []tstorage.Label) map[string]string {
+	m := make(map[string]string, len(labels))
+	for _, label := range labels {
+		m[label.Name] = label.Value
+	}
+	return m
+}
+
+// Function returns the value formatted like the Prometheus HTTP API does.
+func formatPromValue(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
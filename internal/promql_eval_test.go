@@ -0,0 +1,224 @@
+package internal
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/nakabonne/tstorage"
+)
+
+// promTestBase is the time of the first data point of the test series, in
+// seconds.
+const promTestBase = 1600000020
+
+// Function returns the storage of a test server with the test series, which
+// have a data point every minute for five minutes:
+//
+//   - http_requests_total, a counter of instance a which is reset on the
+//     fourth minute, and of instance b,
+//   - up, a gauge of the instances a, b and c.
+func newPromTestImpl(t *testing.T) *TStorageServerImpl {
+	t.Helper()
+	s := newTestServer(t)
+	impl, release, err := s.tenants.acquire("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(release)
+
+	labels := func(job string, instance string) []tstorage.Label {
+		return []tstorage.Label{{Name: "job", Value: job}, {Name: "instance", Value: instance}}
+	}
+	series := []struct {
+		metric string
+		labels []tstorage.Label
+		values []float64
+	}{
+		{"http_requests_total", labels("api", "a"), []float64{0, 60, 120, 10, 70}},
+		{"http_requests_total", labels("api", "b"), []float64{0, 30, 60, 90, 120}},
+		{"up", labels("api", "a"), []float64{1, 1, 1, 1, 1}},
+		{"up", labels("api", "b"), []float64{0, 0, 0, 0, 0}},
+		{"up", labels("db", "c"), []float64{1, 1, 1, 1, 1}},
+	}
+	rows := []tstorage.Row{}
+	for _, ser := range series {
+		for i, v := range ser.values {
+			rows = append(rows, tstorage.Row{Metric: ser.metric, Labels: ser.labels, DataPoint: tstorage.DataPoint{Timestamp: promTestBase + int64(i)*60, Value: v}})
+		}
+	}
+	if err := impl.insertRows(rows); err != nil {
+		t.Fatal(err)
+	}
+	return impl
+}
+
+// Function returns the evaluated value as one line per series, with the
+// labels followed by the value at every step or `_` when there is none. The
+// series without any value are left out like our API does.
+func formatPromTestValue(v *promValue) []string {
+	if v.scalar != nil {
+		values := []string{"scalar"}
+		for _, value := range v.scalar {
+			values = append(values, formatPromValue(value))
+		}
+		return []string{strings.Join(values, " ")}
+	}
+	lines := []string{}
+	for _, ser := range v.series {
+		labels := []string{}
+		for _, label := range sortedLabels(ser.labels) {
+			labels = append(labels, fmt.Sprintf("%v=%q", label.Name, label.Value))
+		}
+		values := []string{"{" + strings.Join(labels, ",") + "}"}
+		present := false
+		for i, value := range ser.values {
+			if ser.present[i] {
+				values = append(values, formatPromValue(value))
+				present = true
+			} else {
+				values = append(values, "_")
+			}
+		}
+		if present {
+			lines = append(lines, strings.Join(values, " "))
+		}
+	}
+	return lines
+}
+
+func TestPromEvaluator(t *testing.T) {
+	impl := newPromTestImpl(t)
+
+	const (
+		a = `{instance="a",job="api"}`
+		b = `{instance="b",job="api"}`
+	)
+	tests := []struct {
+		name  string
+		query string
+		want  []string
+	}{
+		{"selector", `up{job="api"}`, []string{
+			`{__name__="up",instance="a",job="api"} 1 1 1 1 _`,
+			`{__name__="up",instance="b",job="api"} 0 0 0 0 _`,
+		}},
+		{"regexp is anchored", `up{job=~"ap"}`, []string{}},
+		{"regexp", `up{job=~"ap.*|d."}`, []string{
+			`{__name__="up",instance="a",job="api"} 1 1 1 1 _`,
+			`{__name__="up",instance="b",job="api"} 0 0 0 0 _`,
+			`{__name__="up",instance="c",job="db"} 1 1 1 1 _`,
+		}},
+		{"missing series", `up{job="cache"}`, []string{}},
+		{"offset", `http_requests_total{instance="a"} offset 1m`, []string{
+			`{__name__="http_requests_total",instance="a",job="api"} 60 10 70 70 _`,
+		}},
+		{"increase with counter reset", "increase(http_requests_total[4m])", []string{a + " 120 130 60 _ _", b + " 60 90 30 _ _"}},
+		{"rate", `rate(http_requests_total{instance="b"}[4m])`, []string{b + " 0.25 0.375 0.125 _ _"}},
+		{"irate with counter reset", `irate(http_requests_total{instance="a"}[4m] offset 1m)`, []string{a + " 1 0.16666666666666666 1 _ _"}},
+		{"delta ignores counter resets", `delta(http_requests_total{instance="a"}[4m])`, []string{a + " 120 10 60 _ _"}},
+		{"idelta", `idelta(http_requests_total{instance="a"}[4m])`, []string{a + " 60 60 60 _ _"}},
+		{"sum over time", `sum_over_time(http_requests_total{instance="b"}[4m])`, []string{b + " 90 300 210 _ _"}},
+		{"count over time", `count_over_time(http_requests_total{instance="b"}[4m])`, []string{b + " 3 4 2 _ _"}},
+		{"min over time", `min_over_time(http_requests_total{instance="b"}[4m])`, []string{b + " 0 30 90 _ _"}},
+		{"last over time", `last_over_time(http_requests_total{instance="b"}[4m])`, []string{b + " 60 120 120 _ _"}},
+		{"stddev over time", `stddev_over_time(up{instance="a"}[4m])`, []string{a + " 0 0 0 _ _"}},
+		{"quantile over time", `quantile_over_time(0.5, http_requests_total{instance="b"}[4m])`, []string{b + " 30 75 105 _ _"}},
+		{"sum", "sum(up)", []string{"{} 2 2 2 2 _"}},
+		{"avg", "avg(up)", []string{"{} 0.6666666666666666 0.6666666666666666 0.6666666666666666 0.6666666666666666 _"}},
+		{"sum by", "sum by (job) (up)", []string{`{job="api"} 1 1 1 1 _`, `{job="db"} 1 1 1 1 _`}},
+		{"count without", "count(up) without (instance)", []string{`{job="api"} 2 2 2 2 _`, `{job="db"} 1 1 1 1 _`}},
+		{"quantile", "quantile(0.5, up)", []string{"{} 1 1 1 1 _"}},
+		{"aggregated range function", "sum(increase(http_requests_total[4m]))", []string{"{} 180 220 90 _ _"}},
+		{"vector and scalar", `up{job="api"} * 100`, []string{a + " 100 100 100 100 _", b + " 0 0 0 0 _"}},
+		{"scalar and vector", `1 - up{job="api"}`, []string{a + " 0 0 0 0 _", b + " 1 1 1 1 _"}},
+		{"vectors", "http_requests_total - http_requests_total offset 2m", []string{a + " 120 -50 0 0 _", b + " 60 60 0 0 _"}},
+		{"comparison filters", "up == 1", []string{
+			`{__name__="up",instance="a",job="api"} 1 1 1 1 _`,
+			`{__name__="up",instance="c",job="db"} 1 1 1 1 _`,
+		}},
+		{"comparison with scalar on the left", "100 < http_requests_total", []string{
+			`{__name__="http_requests_total",instance="a",job="api"} 120 _ _ _ _`,
+			`{__name__="http_requests_total",instance="b",job="api"} _ 120 120 120 _`,
+		}},
+		{"scalar", "2 ^ 3 * 2", []string{"scalar 16 16 16 16 16"}},
+		{"unary minus", "-2 ^ 2", []string{"scalar -4 -4 -4 -4 -4"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expr, err := parsePromQL(tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// The steps are two minutes apart, from two minutes after
+			// the first data point to six minutes after the last one, so
+			// the last step is outside of the lookback of every series.
+			e := newPromEvaluator(impl, nil, promTestBase+120, promTestBase+600, 120)
+			v, err := e.eval(expr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := formatPromTestValue(v)
+			if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("got\n%v\nwant\n%v", strings.Join(got, "\n"), strings.Join(tt.want, "\n"))
+			}
+		})
+	}
+}
+
+func TestPromEvaluatorErrors(t *testing.T) {
+	impl := newPromTestImpl(t)
+	tests := []struct {
+		name  string
+		query string
+		token *accessToken
+		want  string
+	}{
+		{"range vector", "up[5m]", nil, "range vector must be used with a range function"},
+		{"aggregation of a scalar", "sum(1)", nil, "expected an instant vector"},
+		{"vector parameter", "quantile(up, up)", nil, "expected a scalar parameter"},
+		{"comparison of scalars", "1 == 1", nil, "comparisons between scalars are not supported"},
+		{"duplicate series", `up + {__name__=~"up|http_requests_total"}`, nil, "duplicate series"},
+		{"metric not allowed", "rate(http_requests_total[5m])", &accessToken{Name: "reader", Metrics: []string{"up"}}, "http_requests_total"},
+		{"metric regexp not allowed", `{__name__=~"up"}`, &accessToken{Name: "reader", Metrics: []string{"up"}}, "PermissionDenied"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expr, err := parsePromQL(tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			e := newPromEvaluator(impl, tt.token, promTestBase, promTestBase+240, 60)
+			v, err := e.eval(expr)
+			if err == nil {
+				t.Fatalf("got %v, want an error", formatPromTestValue(v))
+			}
+			if !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("got error %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestGroupLabels(t *testing.T) {
+	labels := []tstorage.Label{{Name: metricNameLabel, Value: "up"}, {Name: "instance", Value: "a"}, {Name: "job", Value: "api"}}
+	tests := []struct {
+		name     string
+		grouping []string
+		without  bool
+		want     []tstorage.Label
+	}{
+		{"no grouping", nil, false, []tstorage.Label{}},
+		{"by", []string{"job", "missing"}, false, []tstorage.Label{{Name: "job", Value: "api"}}},
+		{"by metric name", []string{metricNameLabel}, false, []tstorage.Label{}},
+		{"without", []string{"instance"}, true, []tstorage.Label{{Name: "job", Value: "api"}}},
+		{"without nothing", []string{}, true, []tstorage.Label{{Name: "instance", Value: "a"}, {Name: "job", Value: "api"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := groupLabels(labels, tt.grouping, tt.without); seriesKey(got) != seriesKey(tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,170 @@
+package internal
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// Function returns the parsed expression with every operation in
+// parentheses, so the tests can check how the expression was parsed.
+func formatPromExpr(expr promExpr) string {
+	switch expr := expr.(type) {
+	case *promNumber:
+		return strconv.FormatFloat(expr.value, 'g', -1, 64)
+	case *promSelector:
+		ops := map[matchType]string{matchEqual: "=", matchNotEqual: "!=", matchRegexp: "=~", matchNotRegexp: "!~"}
+		matchers := make([]string, 0, len(expr.matchers))
+		for _, m := range expr.matchers {
+			matchers = append(matchers, fmt.Sprintf("%v%v%q", m.name, ops[m.typ], m.value))
+		}
+		s := expr.metric + "{" + strings.Join(matchers, ",") + "}"
+		if expr.rng != 0 {
+			s += "[" + expr.rng.String() + "]"
+		}
+		if expr.offset != 0 {
+			s += " offset " + expr.offset.String()
+		}
+		return s
+	case *promCall:
+		args := make([]string, 0, len(expr.args))
+		for _, arg := range expr.args {
+			args = append(args, formatPromExpr(arg))
+		}
+		return expr.fn + "(" + strings.Join(args, ", ") + ")"
+	case *promAggregation:
+		s := expr.op
+		if expr.grouping != nil {
+			keyword := " by "
+			if expr.without {
+				keyword = " without "
+			}
+			s += keyword + "(" + strings.Join(expr.grouping, ", ") + ")"
+		}
+		if expr.param != nil {
+			return s + "(" + formatPromExpr(expr.param) + ", " + formatPromExpr(expr.expr) + ")"
+		}
+		return s + "(" + formatPromExpr(expr.expr) + ")"
+	case *promBinary:
+		return "(" + formatPromExpr(expr.lhs) + " " + expr.op + " " + formatPromExpr(expr.rhs) + ")"
+	}
+	return fmt.Sprintf("%T", expr)
+}
+
+func TestParsePromQL(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{"number", "1.5", "1.5"},
+		{"number with exponent", "1e-3", "0.001"},
+		{"number without integer part", ".5", "0.5"},
+		{"infinity", "Inf", "+Inf"},
+		{"negative number", "-1", "-1"},
+		{"metric", "up", `up{__name__="up"}`},
+		{"metric with dots and colons", "api.requests:rate5m", `api.requests:rate5m{__name__="api.requests:rate5m"}`},
+		{"matchers", `up{job="api", instance!='a', path=~"/v1/.*", code!~` + "`5..`" + `,}`, `up{__name__="up",job="api",instance!="a",path=~"/v1/.*",code!~"5.."}`},
+		{"escaped strings", `up{path="a\"b", name='it\'s'}`, `up{__name__="up",path="a\"b",name="it's"}`},
+		{"metric name matcher", `{__name__="up", job="api"}`, `up{__name__="up",job="api"}`},
+		{"metric name regexp", `{__name__=~"up|down"}`, `{__name__=~"up|down"}`},
+		{"range and offset", "up[5m] offset 1h", `up{__name__="up"}[5m0s] offset 1h0m0s`},
+		{"range function", "rate(http_requests_total[5m])", `rate(http_requests_total{__name__="http_requests_total"}[5m0s])`},
+		{"range function with parameter", "quantile_over_time(0.9, latency[1h])", `quantile_over_time(0.9, latency{__name__="latency"}[1h0m0s])`},
+		{"aggregation", "sum(up)", `sum(up{__name__="up"})`},
+		{"aggregation grouping first", "sum by (job, instance) (up)", `sum by (job, instance)(up{__name__="up"})`},
+		{"aggregation grouping last", "avg(up) without (instance)", `avg without (instance)(up{__name__="up"})`},
+		{"quantile", "quantile(0.5, up)", `quantile(0.5, up{__name__="up"})`},
+		{"metric named like an aggregation", "count", `count{__name__="count"}`},
+		{"multiplication before addition", "1 + 2 * 3", "(1 + (2 * 3))"},
+		{"left associative", "1 - 2 - 3", "((1 - 2) - 3)"},
+		{"power is right associative", "2 ^ 3 ^ 2", "(2 ^ (3 ^ 2))"},
+		{"unary minus after power", "-2 ^ 2", "(0 - (2 ^ 2))"},
+		{"unary minus before multiplication", "-up * 2", `((0 - up{__name__="up"}) * 2)`},
+		{"negative exponent", "2 ^ -1", "(2 ^ -1)"},
+		{"unary plus", "+up", `up{__name__="up"}`},
+		{"comparison after arithmetic", "up * 2 >= 1 + 1", `((up{__name__="up"} * 2) >= (1 + 1))`},
+		{"parentheses", "(1 + 2) * 3", "((1 + 2) * 3)"},
+		{"spaces", " sum ( rate ( a [ 1m ] ) ) ", `sum(rate(a{__name__="a"}[1m0s]))`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expr, err := parsePromQL(tt.input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := formatPromExpr(expr); got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParsePromQLErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{"empty", "", "unexpected end of input"},
+		{"trailing input", "up up", `unexpected "up"`},
+		{"unknown function", "foo(up)", `unknown function "foo"`},
+		{"instant vector for range function", "rate(up)", "expects a range vector"},
+		{"missing argument", "quantile_over_time(up[5m])", "expects 2 arguments but got 1"},
+		{"empty selector", "{}", "at least one non-empty matcher"},
+		{"selector matching everything", `{job=~".*"}`, "at least one non-empty matcher"},
+		{"metric name set twice", `up{__name__="down"}`, "metric name must not be set twice"},
+		{"missing label name", `up{="a"}`, "expected label name"},
+		{"missing match operator", `up{job "a"}`, "expected label matching operator"},
+		{"unquoted value", `up{job=a}`, "expected string"},
+		{"unterminated string", `up{job="a}`, "unterminated string"},
+		{"invalid regexp", `up{job=~"("}`, "invalid regular expression"},
+		{"unterminated matchers", `up{job="a"`, "unexpected end of input"},
+		{"invalid range", "up[5]", "parse error"},
+		{"zero range", "up[0s]", "duration must be greater than zero"},
+		{"unterminated range", "up[5m", `expected ']'`},
+		{"invalid number", "1.2.3", `invalid number "1.2.3"`},
+		{"unbalanced parentheses", "(1 + 2", `expected ')'`},
+		{"missing operand", "1 +", "unexpected end of input"},
+		{"grouping set twice", "sum by (job) (up) by (job)", "grouping must not be set twice"},
+		{"missing quantile parameter", "quantile(up)", `expected ','`},
+		{"invalid character", "# comment", `unexpected '#'`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expr, err := parsePromQL(tt.input)
+			if err == nil {
+				t.Fatalf("got %v, want an error", formatPromExpr(expr))
+			}
+			if !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("got error %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestParsePromSelector(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    string
+		wantErr bool
+	}{
+		{`up{job="api"}`, `up{__name__="up",job="api"}`, false},
+		{`{__name__=~"up|down"}`, `{__name__=~"up|down"}`, false},
+		{"up[5m]", "", true},
+		{"sum(up)", "", true},
+		{"up + 1", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			sel, err := parsePromSelector(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+			}
+			if err == nil && formatPromExpr(sel) != tt.want {
+				t.Errorf("got %v, want %v", formatPromExpr(sel), tt.want)
+			}
+		})
+	}
+}
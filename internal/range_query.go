@@ -0,0 +1,207 @@
+package internal
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/nakabonne/tstorage"
+
+	pb "github.com/bartmika/tstorage-server/proto"
+)
+
+// maxBucketsPerSeries is the maximum number of buckets a range query may
+// return for a single series. This protects the server from requests with a
+// tiny step over a huge time range.
+const maxBucketsPerSeries = 11000
+
+// rangeQuery describes how the data points of every series should be
+// grouped into buckets of a fixed step.
+type rangeQuery struct {
+	// The width of every bucket in the timestamp precision of the storage.
+	step        int64
+	aggregation pb.Aggregation
+	fill        pb.Fill
+
+	// The counter function to use instead of the aggregation, if any.
+	function  pb.Function
+	precision tstorage.TimestampPrecision
+}
+
+// Function will convert the step duration into the timestamp precision of
+// the storage and validate the number of buckets the query will produce.
+func (s *TStorageServerImpl) toRangeQuery(in *pb.RangeQueryRequest, q *seriesQuery) (*rangeQuery, error) {
+	if in.Step == nil {
+		return nil, fmt.Errorf("step must be set")
+	}
+	step := in.Step.AsDuration()
+	if step < precisionUnit(s.timestampPrecision) {
+		return nil, fmt.Errorf("step must be at least %v", precisionUnit(s.timestampPrecision))
+	}
+	if q.start >= q.end {
+		return nil, fmt.Errorf("start must be before end")
+	}
+	if in.Function == pb.Function_FUNCTION_NONE && in.Aggregation == pb.Aggregation_AGGREGATION_UNSPECIFIED {
+		return nil, fmt.Errorf("aggregation or function must be set")
+	}
+
+	r := &rangeQuery{
+		step:        int64(step / precisionUnit(s.timestampPrecision)),
+		aggregation: in.Aggregation,
+		fill:        in.Fill,
+		function:    in.Function,
+		precision:   s.timestampPrecision,
+	}
+	if buckets := (q.end - q.start + r.step - 1) / r.step; buckets > maxBucketsPerSeries {
+		return nil, fmt.Errorf("query would return %v buckets per series which exceeds the limit of %v, please use a larger step", buckets, maxBucketsPerSeries)
+	}
+	return r, nil
+}
+
+// Function will group the data points, which must be sorted by timestamp,
+// into buckets of `step` width starting at `start`. Every bucket is reduced
+// into a single data point, timestamped at the start of the bucket, using the
+// aggregation function or the counter function if one was selected. Empty
+// buckets are filled using the fill behaviour where a `FILL_NULL` bucket is
+// returned with a `NaN` value.
+func (r *rangeQuery) bucketize(points []*tstorage.DataPoint, start int64, end int64) ([]*tstorage.DataPoint, error) {
+	values, filled, err := r.bucketValues(points, start, end)
+	if err != nil {
+		return nil, err
+	}
+	return r.fillBuckets(values, filled, start), nil
+}
+
+// Function returns the value of every bucket between `start` and `end` along
+// with whether the bucket had any data points, see `bucketize`.
+func (r *rangeQuery) bucketValues(points []*tstorage.DataPoint, start int64, end int64) ([]float64, []bool, error) {
+	numBuckets := int((end - start + r.step - 1) / r.step)
+	values := make([]float64, numBuckets)
+	filled := make([]bool, numBuckets)
+
+	var err error
+	if r.function == pb.Function_FUNCTION_NONE {
+		err = r.aggregateBuckets(points, start, end, values, filled)
+	} else {
+		err = r.counterBuckets(points, start, end, values, filled)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return values, filled, nil
+}
+
+// Function returns one data point per bucket starting at `start` where the
+// empty buckets are handled using the fill behaviour.
+func (r *rangeQuery) fillBuckets(values []float64, filled []bool, start int64) []*tstorage.DataPoint {
+	out := make([]*tstorage.DataPoint, 0, len(values))
+	for i := range values {
+		ts := start + int64(i)*r.step
+		if filled[i] {
+			out = append(out, &tstorage.DataPoint{Timestamp: ts, Value: values[i]})
+			continue
+		}
+
+		switch r.fill {
+		case pb.Fill_FILL_NULL:
+			out = append(out, &tstorage.DataPoint{Timestamp: ts, Value: math.NaN()})
+		case pb.Fill_FILL_PREVIOUS:
+			if prev := previousFilled(filled, i); prev >= 0 {
+				out = append(out, &tstorage.DataPoint{Timestamp: ts, Value: values[prev]})
+			}
+		case pb.Fill_FILL_LINEAR:
+			prev, next := previousFilled(filled, i), nextFilled(filled, i)
+			if prev >= 0 && next >= 0 {
+				weight := float64(i-prev) / float64(next-prev)
+				value := values[prev] + (values[next]-values[prev])*weight
+				out = append(out, &tstorage.DataPoint{Timestamp: ts, Value: value})
+			}
+		}
+	}
+	return out
+}
+
+// Function will reduce the values of the data points found in every bucket
+// using the aggregation function.
+func (r *rangeQuery) aggregateBuckets(points []*tstorage.DataPoint, start int64, end int64, values []float64, filled []bool) error {
+	// Group the values of the data points by bucket.
+	buckets := make([][]float64, len(values))
+	for _, point := range points {
+		if point.Timestamp < start || point.Timestamp >= end {
+			continue
+		}
+		i := (point.Timestamp - start) / r.step
+		buckets[i] = append(buckets[i], point.Value)
+	}
+
+	// Reduce every bucket into a single value.
+	for i, bucket := range buckets {
+		if len(bucket) == 0 {
+			continue
+		}
+		value, err := aggregateValues(r.aggregation, bucket)
+		if err != nil {
+			return err
+		}
+		values[i] = value
+		filled[i] = true
+	}
+	return nil
+}
+
+// Function will apply the counter function to the data points found in every
+// bucket. The last data point before a bucket is included with the data
+// points of that bucket so changes happening between two buckets are not
+// lost; this is also why the caller should select the data points starting
+// one step before `start`.
+func (r *rangeQuery) counterBuckets(points []*tstorage.DataPoint, start int64, end int64, values []float64, filled []bool) error {
+	seconds := float64(r.step) * precisionUnit(r.precision).Seconds()
+	first := 0
+	for i := range values {
+		bucketStart := start + int64(i)*r.step
+		bucketEnd := bucketStart + r.step
+		if bucketEnd > end {
+			bucketEnd = end
+		}
+
+		// Find the data points inside of the bucket.
+		for first < len(points) && points[first].Timestamp < bucketStart {
+			first++
+		}
+		last := first
+		for last < len(points) && points[last].Timestamp < bucketEnd {
+			last++
+		}
+		window := points[first:last]
+		if first > 0 {
+			window = points[first-1 : last]
+		}
+
+		value, ok, err := counterValue(r.function, window, seconds, r.precision)
+		if err != nil {
+			return err
+		}
+		values[i] = value
+		filled[i] = ok
+	}
+	return nil
+}
+
+// Function returns the index of the closest filled bucket before `i` or -1.
+func previousFilled(filled []bool, i int) int {
+	for j := i - 1; j >= 0; j-- {
+		if filled[j] {
+			return j
+		}
+	}
+	return -1
+}
+
+// Function returns the index of the closest filled bucket after `i` or -1.
+func nextFilled(filled []bool, i int) int {
+	for j := i + 1; j < len(filled); j++ {
+		if filled[j] {
+			return j
+		}
+	}
+	return -1
+}
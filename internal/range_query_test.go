@@ -0,0 +1,122 @@
+package internal
+
+import (
+	"math"
+	"testing"
+
+	"github.com/nakabonne/tstorage"
+
+	pb "github.com/bartmika/tstorage-server/proto"
+)
+
+func TestRangeQueryBucketize(t *testing.T) {
+	// Buckets of 10 starting at 0: [0,10) holds 1 and 3, [10,20) is empty,
+	// [20,30) holds 6 and [30,50) are empty. The data points outside of the
+	// range must be ignored.
+	points := []*tstorage.DataPoint{
+		{Timestamp: -5, Value: 100},
+		{Timestamp: 0, Value: 1},
+		{Timestamp: 5, Value: 3},
+		{Timestamp: 20, Value: 6},
+		{Timestamp: 50, Value: 100},
+	}
+	nan := math.NaN()
+	tests := []struct {
+		name string
+		fill pb.Fill
+		want []tstorage.DataPoint
+	}{
+		{"none", pb.Fill_FILL_NONE, []tstorage.DataPoint{
+			{Timestamp: 0, Value: 2},
+			{Timestamp: 20, Value: 6},
+		}},
+		{"null", pb.Fill_FILL_NULL, []tstorage.DataPoint{
+			{Timestamp: 0, Value: 2},
+			{Timestamp: 10, Value: nan},
+			{Timestamp: 20, Value: 6},
+			{Timestamp: 30, Value: nan},
+			{Timestamp: 40, Value: nan},
+		}},
+		{"previous", pb.Fill_FILL_PREVIOUS, []tstorage.DataPoint{
+			{Timestamp: 0, Value: 2},
+			{Timestamp: 10, Value: 2},
+			{Timestamp: 20, Value: 6},
+			{Timestamp: 30, Value: 6},
+			{Timestamp: 40, Value: 6},
+		}},
+		{"linear", pb.Fill_FILL_LINEAR, []tstorage.DataPoint{
+			{Timestamp: 0, Value: 2},
+			{Timestamp: 10, Value: 4},
+			{Timestamp: 20, Value: 6},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &rangeQuery{step: 10, aggregation: pb.Aggregation_AGGREGATION_AVG, fill: tt.fill}
+			got, err := r.bucketize(points, 0, 50)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assertDataPoints(t, got, tt.want)
+		})
+	}
+}
+
+func TestRangeQueryBucketizeEmpty(t *testing.T) {
+	tests := []struct {
+		name string
+		fill pb.Fill
+		want int
+	}{
+		{"none", pb.Fill_FILL_NONE, 0},
+		{"null", pb.Fill_FILL_NULL, 3},
+		{"previous", pb.Fill_FILL_PREVIOUS, 0},
+		{"linear", pb.Fill_FILL_LINEAR, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &rangeQuery{step: 10, aggregation: pb.Aggregation_AGGREGATION_SUM, fill: tt.fill}
+			got, err := r.bucketize(nil, 0, 30)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != tt.want {
+				t.Errorf("got %v data points, want %v", len(got), tt.want)
+			}
+		})
+	}
+}
+
+func TestRangeQueryBucketizePartialBucket(t *testing.T) {
+	// The last bucket is cut short by the end of the range.
+	points := []*tstorage.DataPoint{
+		{Timestamp: 0, Value: 1},
+		{Timestamp: 10, Value: 2},
+		{Timestamp: 14, Value: 3},
+		{Timestamp: 15, Value: 4},
+	}
+	r := &rangeQuery{step: 10, aggregation: pb.Aggregation_AGGREGATION_COUNT}
+	got, err := r.bucketize(points, 0, 15)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertDataPoints(t, got, []tstorage.DataPoint{
+		{Timestamp: 0, Value: 1},
+		{Timestamp: 10, Value: 2},
+	})
+}
+
+// Function will fail the test if the data points are not equal where two
+// `NaN` values are considered equal.
+func assertDataPoints(t *testing.T, got []*tstorage.DataPoint, want []tstorage.DataPoint) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("got %v data points, want %v", len(got), len(want))
+	}
+	for i := range want {
+		sameValue := got[i].Value == want[i].Value || (math.IsNaN(got[i].Value) && math.IsNaN(want[i].Value))
+		if got[i].Timestamp != want[i].Timestamp || !sameValue {
+			t.Errorf("data point %v: got %+v, want %+v", i, *got[i], want[i])
+		}
+	}
+}
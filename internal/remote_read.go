@@ -0,0 +1,149 @@
+package internal
+
+import (
+	"net/http"
+	"sort"
+
+	"github.com/golang/snappy"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/bartmika/tstorage-server/proto/prompb"
+)
+
+// Function will answer the queries of a Prometheus remote read request with
+// the samples found in the storage of the tenant of the request.
+//
+// DEVELOPERS NOTE:
+// We only support returning samples, which every Prometheus supports, and not
+// the streamed chunks.
+func (s *TStorageServer) handleRemoteRead(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	impl, t, release, err := s.httpTenant(r, scopeRead)
+	if err != nil {
+		writeHTTPError(w, err)
+		return
+	}
+	defer release()
+
+	req := &prompb.ReadRequest{}
+	if err := decodeSnappyRequest(w, r, req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !acceptsSamples(req.AcceptedResponseTypes) {
+		http.Error(w, "only the samples response type is supported", http.StatusBadRequest)
+		return
+	}
+
+	res := &prompb.ReadResponse{}
+	for _, query := range req.Queries {
+		q, err := impl.fromPromQuery(query)
+		if err != nil {
+			writeHTTPError(w, err)
+			return
+		}
+		if t != nil {
+			if err := authorizeMetrics(t, []string{q.metric}); err != nil {
+				writeHTTPError(w, err)
+				return
+			}
+		}
+		results, err := impl.selectSeries(q)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		res.Results = append(res.Results, &prompb.QueryResult{Timeseries: impl.toPromTimeSeries(results)})
+	}
+
+	b, err := proto.Marshal(res)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/x-protobuf")
+	w.Header().Set("Content-Encoding", "snappy")
+	w.Write(snappy.Encode(nil, b))
+}
+
+// Function returns true if the client accepts samples in the response, which
+// is assumed when the client did not say what it accepts.
+func acceptsSamples(types []prompb.ReadRequest_ResponseType) bool {
+	if len(types) == 0 {
+		return true
+	}
+	for _, typ := range types {
+		if typ == prompb.ReadRequest_SAMPLES {
+			return true
+		}
+	}
+	return false
+}
+
+// Function will convert the Prometheus query into a series query. The time
+// range of Prometheus queries includes the end timestamp.
+func (s *TStorageServerImpl) fromPromQuery(query *prompb.Query) (*seriesQuery, error) {
+	q := &seriesQuery{
+		start: millisToUnix(query.StartTimestampMs, s.timestampPrecision),
+		end:   millisToUnix(query.EndTimestampMs, s.timestampPrecision) + 1,
+	}
+	for _, m := range query.Matchers {
+		var typ matchType
+		switch m.Type {
+		case prompb.LabelMatcher_EQ:
+			typ = matchEqual
+		case prompb.LabelMatcher_NEQ:
+			typ = matchNotEqual
+		case prompb.LabelMatcher_RE:
+			typ = matchRegexp
+		case prompb.LabelMatcher_NRE:
+			typ = matchNotRegexp
+		default:
+			return nil, status.Errorf(codes.InvalidArgument, "unsupported matcher type %v", m.Type)
+		}
+		matcher, err := newLabelMatcher(typ, m.Name, m.Value)
+		if err != nil {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+		q.matchers = append(q.matchers, matcher)
+
+		// Only look at the series of the metric when the query names it.
+		if typ == matchEqual && m.Name == metricNameLabel {
+			q.metric = m.Value
+		}
+	}
+	if len(q.matchers) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "query must have at least one matcher")
+	}
+	return q, nil
+}
+
+// Function will convert the series into Prometheus time series, where the
+// metric becomes the `__name__` label and the labels are sorted by name.
+func (s *TStorageServerImpl) toPromTimeSeries(results []*series) []*prompb.TimeSeries {
+	timeseries := make([]*prompb.TimeSeries, 0, len(results))
+	for _, ser := range results {
+		labels := make([]*prompb.Label, 0, len(ser.labels)+1)
+		labels = append(labels, &prompb.Label{Name: metricNameLabel, Value: ser.metric})
+		for _, label := range ser.labels {
+			labels = append(labels, &prompb.Label{Name: label.Name, Value: label.Value})
+		}
+		sort.Slice(labels, func(i, j int) bool {
+			return labels[i].Name < labels[j].Name
+		})
+
+		samples := make([]*prompb.Sample, 0, len(ser.points))
+		for _, point := range ser.points {
+			samples = append(samples, &prompb.Sample{Value: point.Value, Timestamp: unixToMillis(point.Timestamp, s.timestampPrecision)})
+		}
+		timeseries = append(timeseries, &prompb.TimeSeries{Labels: labels, Samples: samples})
+	}
+	return timeseries
+}
@@ -0,0 +1,177 @@
+package internal
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/golang/snappy"
+	"github.com/nakabonne/tstorage"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/bartmika/tstorage-server/proto/prompb"
+)
+
+func TestFromPromQuery(t *testing.T) {
+	tests := []struct {
+		name      string
+		precision tstorage.TimestampPrecision
+		query     *prompb.Query
+		want      *seriesQuery
+		wantErr   bool
+	}{
+		{"matchers", tstorage.Milliseconds, &prompb.Query{StartTimestampMs: 1600000000000, EndTimestampMs: 1600000060000, Matchers: []*prompb.LabelMatcher{
+			{Type: prompb.LabelMatcher_EQ, Name: "__name__", Value: "up"},
+			{Type: prompb.LabelMatcher_NEQ, Name: "job", Value: "node"},
+			{Type: prompb.LabelMatcher_RE, Name: "instance", Value: "web.*"},
+			{Type: prompb.LabelMatcher_NRE, Name: "dc", Value: "eu|us"},
+		}}, &seriesQuery{metric: "up", start: 1600000000000, end: 1600000060001, matchers: []*labelMatcher{
+			{name: "__name__", value: "up", typ: matchEqual},
+			{name: "job", value: "node", typ: matchNotEqual},
+			{name: "instance", value: "web.*", typ: matchRegexp},
+			{name: "dc", value: "eu|us", typ: matchNotRegexp},
+		}}, false},
+		{"metric name regexp", tstorage.Milliseconds, &prompb.Query{Matchers: []*prompb.LabelMatcher{
+			{Type: prompb.LabelMatcher_RE, Name: "__name__", Value: "up|down"},
+		}}, &seriesQuery{end: 1, matchers: []*labelMatcher{
+			{name: "__name__", value: "up|down", typ: matchRegexp},
+		}}, false},
+		{"end includes the whole second", tstorage.Seconds, &prompb.Query{StartTimestampMs: 1600000000500, EndTimestampMs: 1600000001500, Matchers: []*prompb.LabelMatcher{
+			{Type: prompb.LabelMatcher_EQ, Name: "__name__", Value: "up"},
+		}}, &seriesQuery{metric: "up", start: 1600000000, end: 1600000002, matchers: []*labelMatcher{
+			{name: "__name__", value: "up", typ: matchEqual},
+		}}, false},
+		{"no matchers", tstorage.Milliseconds, &prompb.Query{}, nil, true},
+		{"invalid regexp", tstorage.Milliseconds, &prompb.Query{Matchers: []*prompb.LabelMatcher{
+			{Type: prompb.LabelMatcher_RE, Name: "job", Value: "("},
+		}}, nil, true},
+		{"unsupported matcher type", tstorage.Milliseconds, &prompb.Query{Matchers: []*prompb.LabelMatcher{
+			{Type: prompb.LabelMatcher_Type(9), Name: "job", Value: "node"},
+		}}, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &TStorageServerImpl{timestampPrecision: tt.precision}
+			got, err := s.fromPromQuery(tt.query)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+			}
+			if tt.want == nil {
+				return
+			}
+			if got.metric != tt.want.metric || got.start != tt.want.start || got.end != tt.want.end || len(got.matchers) != len(tt.want.matchers) {
+				t.Fatalf("got %+v, want %+v", got, tt.want)
+			}
+			for i, want := range tt.want.matchers {
+				if m := got.matchers[i]; m.name != want.name || m.value != want.value || m.typ != want.typ {
+					t.Errorf("matcher %v: got %+v, want %+v", i, m, want)
+				}
+			}
+		})
+	}
+}
+
+func TestAcceptsSamples(t *testing.T) {
+	tests := []struct {
+		name  string
+		types []prompb.ReadRequest_ResponseType
+		want  bool
+	}{
+		{"not set", nil, true},
+		{"samples", []prompb.ReadRequest_ResponseType{prompb.ReadRequest_SAMPLES}, true},
+		{"chunks preferred", []prompb.ReadRequest_ResponseType{prompb.ReadRequest_STREAMED_XOR_CHUNKS, prompb.ReadRequest_SAMPLES}, true},
+		{"only chunks", []prompb.ReadRequest_ResponseType{prompb.ReadRequest_STREAMED_XOR_CHUNKS}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := acceptsSamples(tt.types); got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRemoteRead(t *testing.T) {
+	s := newTestServer(t)
+	impl, release, err := s.tenants.acquire("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows := []tstorage.Row{}
+	for _, host := range []string{"a", "b"} {
+		for i, ts := range []int64{1600000000, 1600000001, 1600000002} {
+			rows = append(rows, tstorage.Row{Metric: "up", Labels: []tstorage.Label{{Name: "host", Value: host}}, DataPoint: tstorage.DataPoint{Timestamp: ts, Value: float64(i)}})
+		}
+	}
+	err = impl.insertRows(rows)
+	release()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	query := &prompb.Query{StartTimestampMs: 1600000000000, EndTimestampMs: 1600000001000, Matchers: []*prompb.LabelMatcher{
+		{Type: prompb.LabelMatcher_EQ, Name: "__name__", Value: "up"},
+		{Type: prompb.LabelMatcher_NEQ, Name: "host", Value: "b"},
+	}}
+	tests := []struct {
+		name string
+		req  *prompb.ReadRequest
+		want int
+	}{
+		{"samples", &prompb.ReadRequest{Queries: []*prompb.Query{query}}, http.StatusOK},
+		{"only chunks", &prompb.ReadRequest{Queries: []*prompb.Query{query}, AcceptedResponseTypes: []prompb.ReadRequest_ResponseType{prompb.ReadRequest_STREAMED_XOR_CHUNKS}}, http.StatusBadRequest},
+		{"query without matchers", &prompb.ReadRequest{Queries: []*prompb.Query{{}}}, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, err := proto.Marshal(tt.req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			w := httptest.NewRecorder()
+			s.handleRemoteRead(w, httptest.NewRequest(http.MethodPost, "/api/v1/read", bytes.NewReader(snappy.Encode(nil, b))))
+			if w.Code != tt.want {
+				t.Fatalf("got status %v, want %v: %v", w.Code, tt.want, w.Body.String())
+			}
+			if w.Code != http.StatusOK {
+				return
+			}
+
+			if got := w.Header().Get("Content-Encoding"); got != "snappy" {
+				t.Errorf("got the content encoding %q, want snappy", got)
+			}
+			body, err := ioutil.ReadAll(w.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			decoded, err := snappy.Decode(nil, body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			res := &prompb.ReadResponse{}
+			if err := proto.Unmarshal(decoded, res); err != nil {
+				t.Fatal(err)
+			}
+
+			// The sample at the end of the query is included.
+			if len(res.Results) != 1 || len(res.Results[0].Timeseries) != 1 {
+				t.Fatalf("got %v, want a single time series", res)
+			}
+			ts := res.Results[0].Timeseries[0]
+			if len(ts.Labels) != 2 || ts.Labels[0].Name != "__name__" || ts.Labels[0].Value != "up" || ts.Labels[1].Name != "host" || ts.Labels[1].Value != "a" {
+				t.Errorf("got the labels %v, want __name__=up and host=a", ts.Labels)
+			}
+			if len(ts.Samples) != 2 || ts.Samples[0].Timestamp != 1600000000000 || ts.Samples[1].Timestamp != 1600000001000 || ts.Samples[1].Value != 1 {
+				t.Errorf("got the samples %v, want the samples at 1600000000000 and 1600000001000", ts.Samples)
+			}
+		})
+	}
+
+	w := httptest.NewRecorder()
+	s.handleRemoteRead(w, httptest.NewRequest(http.MethodPost, "/api/v1/read", bytes.NewReader([]byte("not snappy"))))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("got status %v for a body which is not snappy, want %v", w.Code, http.StatusBadRequest)
+	}
+}
@@ -0,0 +1,139 @@
+package internal
+
+import (
+	"fmt"
+	"io/ioutil"
+	"math"
+	"net/http"
+
+	"github.com/golang/snappy"
+	"github.com/nakabonne/tstorage"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/bartmika/tstorage-server/proto/prompb"
+)
+
+// staleNaN is the bit pattern of the NaN Prometheus writes to mark a series as
+// stale, which is not an actual value.
+const staleNaN uint64 = 0x7ff0000000000002
+
+// Function will write the samples of a Prometheus remote write request into
+// the storage of the tenant of the request.
+//
+// DEVELOPERS NOTE:
+// Prometheus retries the requests which fail with a 5xx status code and drops
+// the ones which fail with a 4xx status code, so only storage errors are
+// reported as server errors. The whole request is validated before anything
+// is written and then written at once, so a retried request was never
+// partially written before.
+func (s *TStorageServer) handleRemoteWrite(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	impl, t, release, err := s.httpTenant(r, scopeWrite)
+	if err != nil {
+		writeHTTPError(w, err)
+		return
+	}
+	defer release()
+
+	req := &prompb.WriteRequest{}
+	if err := decodeSnappyRequest(w, r, req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	rows, err := impl.fromPromTimeSeries(req.Timeseries)
+	if err != nil {
+		writeHTTPError(w, err)
+		return
+	}
+	if t != nil {
+		metrics := make([]string, 0, len(rows))
+		for _, row := range rows {
+			metrics = append(metrics, row.Metric)
+		}
+		if err := authorizeMetrics(t, metrics); err != nil {
+			writeHTTPError(w, err)
+			return
+		}
+	}
+
+	if len(rows) > 0 {
+		if err := impl.insertRows(rows); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Function will convert the Prometheus time series into the row format used
+// by `tstorage`, where the `__name__` label becomes the metric and the other
+// labels are kept as they are. An error is returned if any of the time series
+// is invalid.
+func (s *TStorageServerImpl) fromPromTimeSeries(timeseries []*prompb.TimeSeries) ([]tstorage.Row, error) {
+	rows := []tstorage.Row{}
+	for _, ts := range timeseries {
+		var metric string
+		labels := make([]tstorage.Label, 0, len(ts.Labels))
+		seen := map[string]bool{}
+		for _, label := range ts.Labels {
+			if seen[label.Name] {
+				return nil, status.Errorf(codes.InvalidArgument, "time series has the %q label more than once", label.Name)
+			}
+			seen[label.Name] = true
+
+			switch {
+			case label.Name == metricNameLabel:
+				metric = label.Value
+			case label.Name == "" || label.Value == "":
+				// Prometheus treats a label with an empty value as missing.
+			default:
+				labels = append(labels, tstorage.Label{Name: label.Name, Value: label.Value})
+			}
+		}
+		if metric == "" {
+			return nil, status.Errorf(codes.InvalidArgument, "time series is missing the %v label", metricNameLabel)
+		}
+
+		for _, sample := range ts.Samples {
+			if math.Float64bits(sample.Value) == staleNaN {
+				continue
+			}
+			timestamp, ok := millisToUnixChecked(sample.Timestamp, s.timestampPrecision)
+			if !ok {
+				return nil, status.Errorf(codes.InvalidArgument, "timestamp %v of the %q metric is out of range", sample.Timestamp, metric)
+			}
+			rows = append(rows, tstorage.Row{
+				Metric:    metric,
+				Labels:    labels,
+				DataPoint: tstorage.DataPoint{Timestamp: timestamp, Value: sample.Value},
+			})
+		}
+	}
+	return rows, nil
+}
+
+// Function will decode the snappy compressed protocol buffer message sent as
+// the body of the request, which is how Prometheus sends its remote write and
+// remote read requests.
+func decodeSnappyRequest(w http.ResponseWriter, r *http.Request, msg proto.Message) error {
+	compressed, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxHTTPBodySize))
+	if err != nil {
+		return err
+	}
+	b, err := snappy.Decode(nil, compressed)
+	if err != nil {
+		return fmt.Errorf("failed to decompress request: %w", err)
+	}
+	if err := proto.Unmarshal(b, msg); err != nil {
+		return fmt.Errorf("failed to decode request: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+package internal
+
+import (
+	"bytes"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/golang/snappy"
+	"github.com/nakabonne/tstorage"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/bartmika/tstorage-server/proto/prompb"
+)
+
+// Function returns the Prometheus time series with a single sample.
+func promTestSeries(ts int64, value float64, labels ...string) *prompb.TimeSeries {
+	series := &prompb.TimeSeries{Samples: []*prompb.Sample{{Timestamp: ts, Value: value}}}
+	for i := 0; i+1 < len(labels); i += 2 {
+		series.Labels = append(series.Labels, &prompb.Label{Name: labels[i], Value: labels[i+1]})
+	}
+	return series
+}
+
+func TestFromPromTimeSeries(t *testing.T) {
+	tests := []struct {
+		name      string
+		precision tstorage.TimestampPrecision
+		series    *prompb.TimeSeries
+		want      []tstorage.Row
+		wantErr   bool
+	}{
+		{"labels", tstorage.Seconds, promTestSeries(1600000000500, 1, "__name__", "up", "job", "node", "empty", ""),
+			[]tstorage.Row{{Metric: "up", Labels: []tstorage.Label{{Name: "job", Value: "node"}}, DataPoint: tstorage.DataPoint{Timestamp: 1600000000, Value: 1}}}, false},
+		{"nanoseconds", tstorage.Nanoseconds, promTestSeries(1600000000500, 1, "__name__", "up"),
+			[]tstorage.Row{{Metric: "up", Labels: []tstorage.Label{}, DataPoint: tstorage.DataPoint{Timestamp: 1600000000500000000, Value: 1}}}, false},
+		{"stale marker is skipped", tstorage.Seconds, promTestSeries(1600000000000, math.Float64frombits(staleNaN), "__name__", "up"), []tstorage.Row{}, false},
+		{"missing metric name", tstorage.Seconds, promTestSeries(1600000000000, 1, "job", "node"), nil, true},
+		{"duplicate label", tstorage.Seconds, promTestSeries(1600000000000, 1, "__name__", "up", "job", "a", "job", "b"), nil, true},
+		{"timestamp out of range", tstorage.Nanoseconds, promTestSeries(math.MaxInt64/1000, 1, "__name__", "up"), nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &TStorageServerImpl{timestampPrecision: tt.precision}
+			got, err := s.fromPromTimeSeries([]*prompb.TimeSeries{tt.series})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %v rows, want %v", len(got), len(tt.want))
+			}
+			for i := range tt.want {
+				if got[i].Metric != tt.want[i].Metric || got[i].DataPoint != tt.want[i].DataPoint || seriesKey(got[i].Labels) != seriesKey(tt.want[i].Labels) {
+					t.Errorf("row %v: got %+v, want %+v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestRemoteWriteRejectsWholeRequest(t *testing.T) {
+	s := newTestServer(t)
+	tests := []struct {
+		name   string
+		series []*prompb.TimeSeries
+		want   int
+		stored int
+	}{
+		{"invalid series after a valid one", []*prompb.TimeSeries{
+			promTestSeries(1600000000000, 1, "__name__", "up"),
+			promTestSeries(1600000001000, 1, "job", "node"),
+		}, http.StatusBadRequest, 0},
+		{"valid", []*prompb.TimeSeries{
+			promTestSeries(1600000000000, 1, "__name__", "up"),
+			promTestSeries(1600000001000, 2, "__name__", "up"),
+		}, http.StatusNoContent, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, err := proto.Marshal(&prompb.WriteRequest{Timeseries: tt.series})
+			if err != nil {
+				t.Fatal(err)
+			}
+			w := httptest.NewRecorder()
+			s.handleRemoteWrite(w, httptest.NewRequest(http.MethodPost, "/api/v1/write", bytes.NewReader(snappy.Encode(nil, b))))
+			if w.Code != tt.want {
+				t.Fatalf("got status %v, want %v: %v", w.Code, tt.want, w.Body.String())
+			}
+			if got := len(selectTestPoints(t, s, "", "up")); got != tt.stored {
+				t.Errorf("got %v stored data points, want %v", got, tt.stored)
+			}
+		})
+	}
+}
@@ -0,0 +1,471 @@
+package internal
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/nakabonne/tstorage"
+
+	pb "github.com/bartmika/tstorage-server/proto"
+)
+
+const (
+	// rollupsFileName is the name of the file, saved inside the data path,
+	// which holds how far every series was rolled up between restarts.
+	rollupsFileName = "rollups.json"
+
+	// rollupInterval is how often the rollup job runs.
+	rollupInterval = time.Minute
+
+	// rollupGracePeriod is how long the rollup job waits after a bucket has
+	// ended before computing it, giving late data points a chance to arrive.
+	rollupGracePeriod = time.Minute
+
+	// rollupsDirName is the name of the directory, inside the data path, which
+	// holds the storage of every rollup tier.
+	rollupsDirName = "rollups"
+
+	// rollupBucketsPerPartition is the minimum number of buckets of a tier
+	// every partition of the storage of the tier spans.
+	rollupBucketsPerPartition = 4
+
+	// rollupForever is the retention of the rollups of the tiers which are
+	// kept forever.
+	rollupForever = time.Duration(math.MaxInt64)
+)
+
+// rollupStore keeps track of the time range every series was rolled up for
+// in every tier.
+type rollupStore struct {
+	mu sync.RWMutex
+
+	// The location of the file the watermarks are persisted to. If empty then
+	// the watermarks are only kept in memory.
+	path string
+
+	// The watermarks grouped by the unique key produced by `seriesID` for the
+	// tier name and labels of the series.
+	watermarks map[string]*rollupWatermark
+}
+
+// rollupWatermark is the time range of a series for which the rollups of a
+// tier were computed where `From` is inclusive and `To` is exclusive. Both are
+// aligned to the step of the tier.
+type rollupWatermark struct {
+	Tier   string           `json:"tier"`
+	Labels []tstorage.Label `json:"labels"`
+	From   int64            `json:"from"`
+	To     int64            `json:"to"`
+}
+
+// Function will create our rollup store and load the previously saved
+// watermarks from the file path, if there are any.
+func newRollupStore(path string) (*rollupStore, error) {
+	r := &rollupStore{
+		path:       path,
+		watermarks: map[string]*rollupWatermark{},
+	}
+	if path == "" {
+		return r, nil
+	}
+
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return r, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read rollups: %w", err)
+	}
+	saved := []*rollupWatermark{}
+	if err := json.Unmarshal(b, &saved); err != nil {
+		return nil, fmt.Errorf("failed to decode rollups: %w", err)
+	}
+	for _, w := range saved {
+		r.watermarks[seriesID(w.Tier, w.Labels)] = w
+	}
+	return r, nil
+}
+
+// Function returns a copy of the watermark of the series for the tier.
+func (r *rollupStore) get(tier string, labels []tstorage.Label) (rollupWatermark, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	w, ok := r.watermarks[seriesID(tier, sortedLabels(labels))]
+	if !ok {
+		return rollupWatermark{}, false
+	}
+	return *w, true
+}
+
+// Function will update the watermark of the series for the tier.
+func (r *rollupStore) set(w rollupWatermark) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	w.Labels = sortedLabels(w.Labels)
+	r.watermarks[seriesID(w.Tier, w.Labels)] = &w
+}
+
+// Function will save the watermarks to disk.
+func (r *rollupStore) save() error {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if r.path == "" {
+		return nil
+	}
+
+	saved := make([]*rollupWatermark, 0, len(r.watermarks))
+	for _, w := range r.watermarks {
+		saved = append(saved, w)
+	}
+	b, err := json.Marshal(saved)
+	if err != nil {
+		return fmt.Errorf("failed to encode rollups: %w", err)
+	}
+
+	// Write to a temporary file first so a crash will never leave us with a
+	// partially written file.
+	tmp := r.path + ".tmp"
+	if err := ioutil.WriteFile(tmp, b, 0644); err != nil {
+		return fmt.Errorf("failed to write rollups: %w", err)
+	}
+	if err := os.Rename(tmp, r.path); err != nil {
+		return fmt.Errorf("failed to write rollups: %w", err)
+	}
+	return nil
+}
+
+// Function will apply the policies to every open tenant every
+// `rollupInterval` until the server is stopped.
+func (s *TStorageServer) runRollups() {
+	if len(s.policies) == 0 {
+		return
+	}
+
+	ticker := time.NewTicker(rollupInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-ticker.C:
+			s.tenants.each(func(impl *TStorageServerImpl) {
+				impl.applyPolicies(time.Now())
+			})
+		}
+	}
+}
+
+// Function will compute the rollups of every series matching a policy and
+// hide the raw data points which are older than the retention of the policy.
+//
+// DEVELOPERS NOTE:
+// The `tstorage` package can only remove whole partitions once they are older
+// than the global retention, so the shorter raw data retention of a policy is
+// applied by extending a tombstone covering everything before the retention.
+// The data points are physically removed with their partition. The rollups do
+// not have this problem since every tier has its own storage and retention,
+// which is why this tombstone is kept apart from the deleted ranges and does
+// not hide the rollups.
+func (s *TStorageServerImpl) applyPolicies(now time.Time) {
+	nowTs := timeToUnix(now, s.timestampPrecision)
+	for _, metric := range s.catalog.metricNames(nil, math.MinInt64, math.MaxInt64) {
+		p := findPolicy(s.policies, metric)
+		if p == nil {
+			continue
+		}
+		for _, ser := range s.catalog.find(metric, nil, math.MinInt64, math.MaxInt64) {
+			for _, tier := range p.Rollups {
+				if err := s.rollupSeries(ser, tier, nowTs); err != nil {
+					log.Printf("failed to roll up %v: %v", tierName(ser.metric, tier), err)
+				}
+			}
+
+			// The raw data points are hidden last so they are rolled up first.
+			if p.Retention > 0 {
+				s.tombstones.retain(ser, nowTs-s.toUnits(time.Duration(p.Retention)))
+			}
+		}
+	}
+
+	if err := s.tombstones.flush(); err != nil {
+		log.Printf("failed to save tombstones: %v", err)
+	}
+	if err := s.rollups.save(); err != nil {
+		log.Printf("failed to save rollups: %v", err)
+	}
+}
+
+// Function will compute the rollups of the series for every bucket of the
+// tier which ended since the last run and write them into the storage of the
+// tier.
+func (s *TStorageServerImpl) rollupSeries(ser *series, tier *rollupTier, now int64) error {
+	step := s.toUnits(time.Duration(tier.Step))
+	end := alignDown(now-s.toUnits(rollupGracePeriod), step)
+
+	// DEVELOPERS NOTE:
+	// The `tstorage` package silently drops data points older than its
+	// writable partitions, so we never compute buckets starting earlier than
+	// half a partition of the storage of the tier ago. The buckets before the
+	// watermark are computed from the raw data points at query time instead,
+	// see `tierPoints`.
+	window := rollupPartitionDuration(s.partitionDuration, time.Duration(tier.Step)) / 2
+	oldest := alignDown(now-s.toUnits(window)+step-1, step)
+	w, ok := s.rollups.get(tierName(ser.metric, tier), ser.labels)
+	if !ok || w.To < oldest {
+		w = rollupWatermark{Tier: tierName(ser.metric, tier), Labels: ser.labels, From: oldest, To: oldest}
+	}
+
+	// Once the rollups expired the raw data points are used instead.
+	if tier.Retention > 0 {
+		if expired := alignDown(now-s.toUnits(time.Duration(tier.Retention))+step-1, step); w.From < expired {
+			w.From = expired
+		}
+	}
+	if w.To < w.From {
+		w.To = w.From
+	}
+	if end <= w.To {
+		s.rollups.set(w)
+		return nil
+	}
+
+	points, err := s.selectPoints(ser.metric, ser.labels, w.To, end)
+	if err != nil {
+		return err
+	}
+	rows := []tstorage.Row{}
+	for _, agg := range tier.stored {
+		r := &rangeQuery{step: step, aggregation: agg, fill: pb.Fill_FILL_NONE, precision: s.timestampPrecision}
+		buckets, err := r.bucketize(points, w.To, end)
+		if err != nil {
+			return err
+		}
+		for _, bucket := range buckets {
+			rows = append(rows, tstorage.Row{
+				Metric:    rollupMetric(ser.metric, tier, agg),
+				Labels:    sortedLabels(ser.labels),
+				DataPoint: *bucket,
+			})
+		}
+	}
+
+	// DEVELOPERS NOTE:
+	// The rollups are written straight into the storage of the tier instead of
+	// going through `insertRows`, so they are never added to our catalog and
+	// never show up next to the raw series.
+	if len(rows) > 0 {
+		if err := s.rollupStorages[time.Duration(tier.Step)].InsertRows(rows); err != nil {
+			return err
+		}
+	}
+
+	w.To = end
+	s.rollups.set(w)
+	return nil
+}
+
+// Function returns the rollup tier which can answer the range query or nil if
+// the raw data points must be used. The buckets of the query must line up with
+// the buckets of the tier, so the query must start at the start of a bucket of
+// the tier and its step must be a multiple of the step of the tier.
+func (s *TStorageServerImpl) rollupTier(q *seriesQuery, r *rangeQuery) *rollupTier {
+	if r.function != pb.Function_FUNCTION_NONE {
+		return nil
+	}
+	step := time.Duration(r.step) * precisionUnit(s.timestampPrecision)
+	tier := findPolicy(s.policies, q.metric).tierFor(step, r.aggregation)
+	if tier == nil || alignDown(q.start, s.toUnits(time.Duration(tier.Step))) != q.start {
+		return nil
+	}
+	return tier
+}
+
+// Function returns every series matching the query with one data point per
+// bucket of the range query, merged from the buckets of the tier instead of
+// the raw data points.
+func (s *TStorageServerImpl) selectRollupSeries(q *seriesQuery, tier *rollupTier, r *rangeQuery) ([]*series, error) {
+	merge := &rangeQuery{step: r.step, aggregation: mergeAggregation(r.aggregation), fill: pb.Fill_FILL_NONE, precision: s.timestampPrecision}
+
+	results := []*series{}
+	for _, ser := range s.findSeries(q) {
+		var values []float64
+		var filled []bool
+		if r.aggregation == pb.Aggregation_AGGREGATION_AVG {
+			// The average is the sum of every bucket divided by its count.
+			sums, err := s.tierPoints(ser, tier, pb.Aggregation_AGGREGATION_SUM, q.start, q.end)
+			if err != nil {
+				return nil, err
+			}
+			counts, err := s.tierPoints(ser, tier, pb.Aggregation_AGGREGATION_COUNT, q.start, q.end)
+			if err != nil {
+				return nil, err
+			}
+			if values, filled, err = merge.bucketValues(sums, q.start, q.end); err != nil {
+				return nil, err
+			}
+			totals, _, err := merge.bucketValues(counts, q.start, q.end)
+			if err != nil {
+				return nil, err
+			}
+			for i := range values {
+				filled[i] = filled[i] && totals[i] > 0
+				if filled[i] {
+					values[i] /= totals[i]
+				}
+			}
+		} else {
+			points, err := s.tierPoints(ser, tier, r.aggregation, q.start, q.end)
+			if err != nil {
+				return nil, err
+			}
+			if values, filled, err = merge.bucketValues(points, q.start, q.end); err != nil {
+				return nil, err
+			}
+		}
+		// Skip the series without any data points in the time range.
+		if previousFilled(filled, len(filled)) < 0 {
+			continue
+		}
+		ser.points = r.fillBuckets(values, filled, q.start)
+		results = append(results, ser)
+	}
+	return results, nil
+}
+
+// Function returns one data point per bucket of the tier, computed with the
+// aggregation, for the data points of the series between `start`, which must
+// be aligned to the step of the tier, and `end`. The rollups already computed
+// by the background job are used where possible and the remaining buckets are
+// computed from the raw data points.
+func (s *TStorageServerImpl) tierPoints(ser *series, tier *rollupTier, agg pb.Aggregation, start int64, end int64) ([]*tstorage.DataPoint, error) {
+	step := s.toUnits(time.Duration(tier.Step))
+	r := &rangeQuery{step: step, aggregation: agg, fill: pb.Fill_FILL_NONE, precision: s.timestampPrecision}
+
+	// Function will compute the buckets between `start` and `end` from the
+	// raw data points of the series.
+	fromRaw := func(start int64, end int64) ([]*tstorage.DataPoint, error) {
+		if start >= end {
+			return nil, nil
+		}
+		points, err := s.selectPoints(ser.metric, ser.labels, start, end)
+		if err != nil || len(points) == 0 {
+			return nil, err
+		}
+		return r.bucketize(points, start, end)
+	}
+
+	// Only the whole buckets of the tier which were rolled up can be read
+	// from the storage of the tier.
+	w, ok := s.rollups.get(tierName(ser.metric, tier), ser.labels)
+	from, to := start, alignDown(end, step)
+	if from < w.From {
+		from = w.From
+	}
+	if to > w.To {
+		to = w.To
+	}
+	if !ok || from >= to {
+		return fromRaw(start, end)
+	}
+
+	points, err := fromRaw(start, from)
+	if err != nil {
+		return nil, err
+	}
+	rolled, err := selectStoragePoints(s.rollupStorages[time.Duration(tier.Step)], rollupMetric(ser.metric, tier, agg), ser.labels, from, to)
+	if err != nil {
+		return nil, err
+	}
+	// The rollups of deleted data points are hidden along with them, unlike
+	// the rollups of the data points older than the raw data retention.
+	points = append(points, s.tombstones.filterDeleted(ser.metric, ser.labels, rolled)...)
+	tail, err := fromRaw(to, end)
+	if err != nil {
+		return nil, err
+	}
+	return append(points, tail...), nil
+}
+
+// Function will open the storage of every rollup tier of the policies where
+// the tiers with the same step share their storage.
+//
+// DEVELOPERS NOTE:
+// Every tier has its own `tstorage` instance so its rollups can be kept
+// longer than the raw data points, and so its partitions can span enough
+// buckets of the tier for the rollups to still be writable once computed.
+func (s *TStorageServer) openRollupStorages(dataPath string) (map[time.Duration]tstorage.Storage, error) {
+	storages := map[time.Duration]tstorage.Storage{}
+	for _, p := range s.policies {
+		for _, tier := range p.Rollups {
+			step := time.Duration(tier.Step)
+			if _, ok := storages[step]; ok {
+				continue
+			}
+			retention := time.Duration(tier.Retention)
+			if retention <= 0 {
+				retention = rollupForever
+			}
+			storage, err := tstorage.NewStorage(
+				tstorage.WithDataPath(dataFilePath(dataPath, filepath.Join(rollupsDirName, formatDuration(step)))),
+				tstorage.WithTimestampPrecision(s.timestampPrecision),
+				tstorage.WithPartitionDuration(rollupPartitionDuration(s.partitionDuration, step)),
+				tstorage.WithWriteTimeout(s.writeTimeout),
+				tstorage.WithRetention(retention),
+			)
+			if err != nil {
+				closeRollupStorages(storages)
+				return nil, err
+			}
+			storages[step] = storage
+		}
+	}
+	return storages, nil
+}
+
+// Function will close the storage of every rollup tier.
+func closeRollupStorages(storages map[time.Duration]tstorage.Storage) error {
+	var firstErr error
+	for _, storage := range storages {
+		if err := storage.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// Function returns the partition duration of the storage of the rollup tier
+// with the step. The partitions must span several buckets of the tier,
+// otherwise the buckets would be older than the writable partitions by the
+// time they can be computed and `tstorage` would drop them.
+func rollupPartitionDuration(partitionDuration time.Duration, step time.Duration) time.Duration {
+	if min := rollupBucketsPerPartition * step; partitionDuration < min {
+		return min
+	}
+	return partitionDuration
+}
+
+// Function will convert the duration into the timestamp precision of the
+// storage.
+func (s *TStorageServerImpl) toUnits(d time.Duration) int64 {
+	return int64(d / precisionUnit(s.timestampPrecision))
+}
+
+// Function returns the start of the bucket of `step` width, counting from the
+// unix epoch, which the timestamp belongs to.
+func alignDown(ts int64, step int64) int64 {
+	remainder := ts % step
+	if remainder < 0 {
+		remainder += step
+	}
+	return ts - remainder
+}
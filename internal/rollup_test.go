@@ -0,0 +1,226 @@
+package internal
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/nakabonne/tstorage"
+
+	pb "github.com/bartmika/tstorage-server/proto"
+)
+
+// rollupTestNow is the time the rollup job runs at in the tests, aligned to
+// every step used by the tests.
+const rollupTestNow = 1600003600
+
+// Function returns the implementation of a tenant kept in memory with the
+// policy which rolls up the `cpu` metric into buckets of 10 seconds.
+func newRollupTestImpl(t *testing.T) *TStorageServerImpl {
+	t.Helper()
+	tier := &rollupTier{Step: jsonDuration(10 * time.Second), Aggregations: []string{"avg", "max"}}
+	for _, agg := range []pb.Aggregation{pb.Aggregation_AGGREGATION_SUM, pb.Aggregation_AGGREGATION_COUNT, pb.Aggregation_AGGREGATION_MAX} {
+		tier.stored = append(tier.stored, agg)
+	}
+	server := &TStorageServer{
+		timestampPrecision: tstorage.Seconds,
+		partitionDuration:  time.Hour,
+		writeTimeout:       time.Second,
+		retention:          defaultRetention,
+		policies:           []*policy{{Match: "cpu", Rollups: []*rollupTier{tier}}},
+		metrics:            newServerMetrics(),
+	}
+	impl, err := server.openTenant("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { impl.close() })
+	return impl
+}
+
+// Function will insert a data point of the `cpu` series.
+func insertRollupTestPoint(t *testing.T, s *TStorageServerImpl, ts int64, value float64) {
+	t.Helper()
+	row := tstorage.Row{
+		Metric:    "cpu",
+		Labels:    []tstorage.Label{{Name: "host", Value: "a"}},
+		DataPoint: tstorage.DataPoint{Timestamp: ts, Value: value},
+	}
+	if err := s.insertRows([]tstorage.Row{row}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRollupTier(t *testing.T) {
+	s := newRollupTestImpl(t)
+	tests := []struct {
+		name     string
+		start    int64
+		step     int64
+		agg      pb.Aggregation
+		function pb.Function
+		want     bool
+	}{
+		{"aligned", 1600000000, 20, pb.Aggregation_AGGREGATION_AVG, pb.Function_FUNCTION_NONE, true},
+		{"same step", 1600000000, 10, pb.Aggregation_AGGREGATION_MAX, pb.Function_FUNCTION_NONE, true},
+		{"sum from average rollups", 1600000000, 10, pb.Aggregation_AGGREGATION_SUM, pb.Function_FUNCTION_NONE, true},
+		{"start not aligned", 1600000005, 20, pb.Aggregation_AGGREGATION_AVG, pb.Function_FUNCTION_NONE, false},
+		{"step not a multiple", 1600000000, 15, pb.Aggregation_AGGREGATION_AVG, pb.Function_FUNCTION_NONE, false},
+		{"step smaller than tier", 1600000000, 5, pb.Aggregation_AGGREGATION_AVG, pb.Function_FUNCTION_NONE, false},
+		{"aggregation not stored", 1600000000, 20, pb.Aggregation_AGGREGATION_MIN, pb.Function_FUNCTION_NONE, false},
+		{"aggregation not mergeable", 1600000000, 20, pb.Aggregation_AGGREGATION_P50, pb.Function_FUNCTION_NONE, false},
+		{"counter function", 1600000000, 20, pb.Aggregation_AGGREGATION_UNSPECIFIED, pb.Function_FUNCTION_RATE, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := &seriesQuery{metric: "cpu", start: tt.start, end: tt.start + 100}
+			r := &rangeQuery{step: tt.step, aggregation: tt.agg, function: tt.function, precision: tstorage.Seconds}
+			if got := s.rollupTier(q, r) != nil; got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSelectRollupSeries(t *testing.T) {
+	s := newRollupTestImpl(t)
+
+	// The first bucket of the tier has two data points and the second has
+	// one, so the average of the averages would be (2 + 10) / 2 = 6.
+	start := int64(rollupTestNow - 600)
+	insertRollupTestPoint(t, s, start, 1)
+	insertRollupTestPoint(t, s, start+5, 3)
+	insertRollupTestPoint(t, s, start+10, 10)
+	insertRollupTestPoint(t, s, start+20, 7)
+	s.applyPolicies(time.Unix(rollupTestNow, 0))
+
+	// The data points arriving once the buckets were rolled up are only seen
+	// by the queries reading the raw data points.
+	insertRollupTestPoint(t, s, start+2, 100)
+	insertRollupTestPoint(t, s, start+32, 1)
+
+	tests := []struct {
+		name string
+		agg  pb.Aggregation
+		fill pb.Fill
+		end  int64
+		want []tstorage.DataPoint
+	}{
+		{"average", pb.Aggregation_AGGREGATION_AVG, pb.Fill_FILL_NONE, start + 40, []tstorage.DataPoint{
+			{Timestamp: start, Value: 14.0 / 3},
+			{Timestamp: start + 20, Value: 7},
+		}},
+		{"count", pb.Aggregation_AGGREGATION_COUNT, pb.Fill_FILL_NONE, start + 40, []tstorage.DataPoint{
+			{Timestamp: start, Value: 3},
+			{Timestamp: start + 20, Value: 1},
+		}},
+		{"max", pb.Aggregation_AGGREGATION_MAX, pb.Fill_FILL_NONE, start + 40, []tstorage.DataPoint{
+			{Timestamp: start, Value: 10},
+			{Timestamp: start + 20, Value: 7},
+		}},
+		{"fill", pb.Aggregation_AGGREGATION_AVG, pb.Fill_FILL_PREVIOUS, start + 60, []tstorage.DataPoint{
+			{Timestamp: start, Value: 14.0 / 3},
+			{Timestamp: start + 20, Value: 7},
+			{Timestamp: start + 40, Value: 7},
+		}},
+		{"partial last bucket is read from the raw data points", pb.Aggregation_AGGREGATION_AVG, pb.Fill_FILL_NONE, start + 35, []tstorage.DataPoint{
+			{Timestamp: start, Value: 14.0 / 3},
+			{Timestamp: start + 20, Value: 4},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := &seriesQuery{metric: "cpu", labels: []tstorage.Label{{Name: "host", Value: "a"}}, start: start, end: tt.end}
+			r := &rangeQuery{step: 20, aggregation: tt.agg, fill: tt.fill, precision: tstorage.Seconds}
+			tier := s.rollupTier(q, r)
+			if tier == nil {
+				t.Fatal("expected a rollup tier")
+			}
+			results, err := s.selectRollupSeries(q, tier, r)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(results) != 1 {
+				t.Fatalf("got %v series, want 1", len(results))
+			}
+			assertDataPoints(t, results[0].points, tt.want)
+		})
+	}
+}
+
+func TestRollupsStayOutOfCatalog(t *testing.T) {
+	s := newRollupTestImpl(t)
+	insertRollupTestPoint(t, s, rollupTestNow-600, 1)
+	s.applyPolicies(time.Unix(rollupTestNow, 0))
+
+	names := s.catalog.metricNames(nil, math.MinInt64, math.MaxInt64)
+	if len(names) != 1 || names[0] != "cpu" {
+		t.Errorf("metricNames() = %v, want [cpu]", names)
+	}
+	tier := s.policies[0].Rollups[0]
+	points, err := selectStoragePoints(s.rollupStorages[time.Duration(tier.Step)], rollupMetric("cpu", tier, pb.Aggregation_AGGREGATION_SUM), []tstorage.Label{{Name: "host", Value: "a"}}, math.MinInt64, math.MaxInt64)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(points) != 1 || points[0].Value != 1 {
+		t.Errorf("got %v rollups, want a single rollup of 1", len(points))
+	}
+}
+
+func TestRollupsOutliveRawRetention(t *testing.T) {
+	s := newRollupTestImpl(t)
+	s.policies[0].Retention = jsonDuration(100 * time.Second)
+
+	start := int64(rollupTestNow - 600)
+	insertRollupTestPoint(t, s, start, 1)
+	insertRollupTestPoint(t, s, start+5, 3)
+	insertRollupTestPoint(t, s, start+10, 10)
+	insertRollupTestPoint(t, s, start+20, 7)
+	s.applyPolicies(time.Unix(rollupTestNow, 0))
+
+	labels := []tstorage.Label{{Name: "host", Value: "a"}}
+	points, err := s.selectPoints("cpu", labels, math.MinInt64, math.MaxInt64)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(points) != 0 {
+		t.Errorf("got %v raw data points, want none past the retention", len(points))
+	}
+
+	tests := []struct {
+		name    string
+		deleted *tombstone
+		want    []tstorage.DataPoint
+	}{
+		{"rollups are kept", nil, []tstorage.DataPoint{
+			{Timestamp: start, Value: 14.0 / 3},
+			{Timestamp: start + 20, Value: 7},
+		}},
+		{"rollups of deleted data points are hidden", &tombstone{Metric: "cpu", Labels: labels, Start: start, End: start + 20}, []tstorage.DataPoint{
+			{Timestamp: start + 20, Value: 7},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.deleted != nil {
+				if err := s.tombstones.add([]*tombstone{tt.deleted}); err != nil {
+					t.Fatal(err)
+				}
+			}
+			q := &seriesQuery{metric: "cpu", labels: labels, start: start, end: start + 40}
+			r := &rangeQuery{step: 20, aggregation: pb.Aggregation_AGGREGATION_AVG, fill: pb.Fill_FILL_NONE, precision: tstorage.Seconds}
+			tier := s.rollupTier(q, r)
+			if tier == nil {
+				t.Fatal("expected a rollup tier")
+			}
+			results, err := s.selectRollupSeries(q, tier, r)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(results) != 1 {
+				t.Fatalf("got %v series, want 1", len(results))
+			}
+			assertDataPoints(t, results[0].points, tt.want)
+		})
+	}
+}
@@ -0,0 +1,158 @@
+package internal
+
+import (
+	"errors"
+	"math"
+
+	"github.com/nakabonne/tstorage"
+
+	pb "github.com/bartmika/tstorage-server/proto"
+)
+
+// series represents the data points belonging to a single metric and label
+// set combination.
+type series struct {
+	metric string
+	labels []tstorage.Label
+	points []*tstorage.DataPoint
+}
+
+// seriesQuery describes which series and time range should be selected.
+type seriesQuery struct {
+	metric string
+
+	// The exact label set of the series. Only used if there are no matchers.
+	labels []tstorage.Label
+
+	// The matchers every returned series must satisfy.
+	matchers []*labelMatcher
+
+	// The time range to select, `start` is inclusive and `end` is exclusive.
+	start int64
+	end   int64
+}
+
+// Function will convert the protocol buffer filter into a series query. Any
+// exact labels provided alongside matchers are treated as equality matchers.
+func (s *TStorageServerImpl) toSeriesQuery(in *pb.Filter) (*seriesQuery, error) {
+	matchers, err := toLabelMatchers(in.Matchers)
+	if err != nil {
+		return nil, err
+	}
+	if in.Metric == "" && len(matchers) == 0 {
+		return nil, errors.New("metric must be set")
+	}
+
+	labels := toStorageLabels(in.Labels)
+	if len(matchers) > 0 {
+		for _, label := range labels {
+			m, _ := newLabelMatcher(matchEqual, label.Name, label.Value)
+			matchers = append(matchers, m)
+		}
+	}
+
+	return &seriesQuery{
+		metric:   in.Metric,
+		labels:   labels,
+		matchers: matchers,
+		start:    toUnixTimestamp(in.Start, s.timestampPrecision),
+		end:      toUnixTimestamp(in.End, s.timestampPrecision),
+	}, nil
+}
+
+// Function will return every series matching the query with the data points
+// found within the time range. Series without any data points in the range
+// are not returned.
+func (s *TStorageServerImpl) selectSeries(q *seriesQuery) ([]*series, error) {
+	results := []*series{}
+	for _, candidate := range s.findSeries(q) {
+		points, err := s.selectPoints(candidate.metric, candidate.labels, q.start, q.end)
+		if err != nil {
+			return nil, err
+		}
+		if len(points) == 0 {
+			continue
+		}
+		candidate.points = points
+		results = append(results, candidate)
+	}
+	return results, nil
+}
+
+// Function returns the series, without their data points, which the query
+// applies to.
+func (s *TStorageServerImpl) findSeries(q *seriesQuery) []*series {
+	// DEVELOPERS NOTE:
+	// Without matchers we keep the original behaviour of selecting the series
+	// with the exact label set, otherwise we use our catalog to find the
+	// label sets of every matching series.
+	if len(q.matchers) == 0 {
+		return []*series{{metric: q.metric, labels: sortedLabels(q.labels)}}
+	}
+	return s.catalog.find(q.metric, q.matchers, q.start, q.end)
+}
+
+// Function returns the data points of a single series within the `start`
+// (inclusive) and `end` (exclusive) range, excluding the deleted ones.
+func (s *TStorageServerImpl) selectPoints(metric string, labels []tstorage.Label, start int64, end int64) ([]*tstorage.DataPoint, error) {
+	points, err := selectStoragePoints(s.storage, metric, labels, start, end)
+	if err != nil {
+		return nil, err
+	}
+
+	// Hide the data points which were deleted.
+	return s.tombstones.filter(metric, labels, points), nil
+}
+
+// Function returns the data points of the series found in the storage
+// between `start` (inclusive) and `end` (exclusive).
+func selectStoragePoints(storage tstorage.Storage, metric string, labels []tstorage.Label, start int64, end int64) ([]*tstorage.DataPoint, error) {
+	// DEVELOPERS NOTE:
+	// The in-memory partitions of `tstorage` do not handle an `end` before
+	// their newest data point correctly and may return too few data points or
+	// even panic, so we always select everything after `start` and remove the
+	// data points past `end` ourselves.
+	points, err := storage.Select(metric, labels, start, math.MaxInt64)
+	if errors.Is(err, tstorage.ErrNoDataPoints) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	for len(points) > 0 && points[len(points)-1].Timestamp >= end {
+		points = points[:len(points)-1]
+	}
+	return points, nil
+}
+
+// Function will convert the series into the protocol buffer format.
+func (s *TStorageServerImpl) toSeriesResponse(ser *series) *pb.Series {
+	points := make([]*pb.DataPoint, 0, len(ser.points))
+	for _, point := range ser.points {
+		ts := fromUnixTimestamp(point.Timestamp, s.timestampPrecision)
+		points = append(points, &pb.DataPoint{Value: point.Value, Timestamp: ts})
+	}
+	return &pb.Series{
+		Metric: ser.metric,
+		Labels: toProtoLabels(ser.labels),
+		Points: points,
+	}
+}
+
+// Function will convert the protocol buffer labels into `tstorage` labels.
+func toStorageLabels(labels []*pb.Label) []tstorage.Label {
+	out := []tstorage.Label{}
+	for _, label := range labels {
+		out = append(out, tstorage.Label{Name: label.Name, Value: label.Value})
+	}
+	return out
+}
+
+// Function will convert the `tstorage` labels into protocol buffer labels.
+func toProtoLabels(labels []tstorage.Label) []*pb.Label {
+	out := []*pb.Label{}
+	for _, label := range labels {
+		out = append(out, &pb.Label{Name: label.Name, Value: label.Value})
+	}
+	return out
+}
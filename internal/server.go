@@ -1,28 +1,64 @@
 package internal
 
 import (
+	"context"
 	"fmt"
+	"io"
 	"log"
 	"net"
+	"net/http"
+	"path/filepath"
 	"time"
 
 	"github.com/nakabonne/tstorage"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
 
 	pb "github.com/bartmika/tstorage-server/proto"
 )
 
 type TStorageServer struct {
-	port               int
-	dataPath           string
-	timestampPrecision tstorage.TimestampPrecision
-	partitionDuration  time.Duration
-	writeTimeout       time.Duration
-	storage            tstorage.Storage
-	grpcServer         *grpc.Server
+	port                  int
+	dataPath              string
+	timestampPrecision    tstorage.TimestampPrecision
+	partitionDuration     time.Duration
+	writeTimeout          time.Duration
+	retention             time.Duration
+	insertBatchSize       int
+	policyFile            string
+	tlsCertFile           string
+	tlsKeyFile            string
+	tlsClientCAFile       string
+	tokenFile             string
+	tokens                map[string]*accessToken
+	policies              []*policy
+	multiTenant           bool
+	maxOpenTenants        int
+	tenants               *tenantPool
+	impl                  *TStorageServerImpl
+	httpPort              int
+	metrics               *serverMetrics
+	grpcServer            *grpc.Server
+	httpServer            *http.Server
+	gatewayPort           int
+	gatewayServer         *http.Server
+	influxUDPPort         int
+	graphitePort          int
+	graphitePicklePort    int
+	graphiteTemplateSpecs []string
+	graphiteTemplates     []*graphiteTemplate
+	statsdPort            int
+	statsdFlushInterval   time.Duration
+	listenerTenantName    string
+	listeners             []io.Closer
+	health                *health.Server
+	done                  chan struct{}
+	stopped               chan struct{}
 }
 
-func New(port int, dataPath string, timestampPrecision string, partitionDuration time.Duration, writeTimeout time.Duration) *TStorageServer {
+func New(port int, dataPath string, timestampPrecision string, partitionDuration time.Duration, writeTimeout time.Duration, opts ...Option) *TStorageServer {
 	// Conver to the format that is accepted by the library.
 	var tsp tstorage.TimestampPrecision
 	switch timestampPrecision {
@@ -36,15 +72,42 @@ func New(port int, dataPath string, timestampPrecision string, partitionDuration
 		tsp = tstorage.Seconds
 	}
 
-	return &TStorageServer{
-		port:               port,
-		dataPath:           dataPath,
-		timestampPrecision: tsp,
-		partitionDuration:  partitionDuration,
-		writeTimeout:       writeTimeout,
-		storage:            nil,
-		grpcServer:         nil,
+	s := &TStorageServer{
+		port:                port,
+		dataPath:            dataPath,
+		timestampPrecision:  tsp,
+		partitionDuration:   partitionDuration,
+		writeTimeout:        writeTimeout,
+		retention:           defaultRetention,
+		insertBatchSize:     defaultInsertBatchSize,
+		maxOpenTenants:      defaultMaxOpenTenants,
+		statsdFlushInterval: defaultStatsdFlushInterval,
+		tenants:             nil,
+		impl:                nil,
+		metrics:             newServerMetrics(),
+		grpcServer:          nil,
+		httpServer:          nil,
+		gatewayServer:       nil,
+		health:              nil,
+		done:                make(chan struct{}),
+		stopped:             make(chan struct{}),
 	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	if s.retention <= 0 {
+		s.retention = defaultRetention
+	}
+	if s.insertBatchSize <= 0 {
+		s.insertBatchSize = defaultInsertBatchSize
+	}
+	if s.maxOpenTenants <= 0 {
+		s.maxOpenTenants = defaultMaxOpenTenants
+	}
+	if s.statsdFlushInterval <= 0 {
+		s.statsdFlushInterval = defaultStatsdFlushInterval
+	}
+	return s
 }
 
 // Function will consume the main runtime loop and run the business logic
@@ -58,32 +121,86 @@ func (s *TStorageServer) RunMainRuntimeLoop() {
 	}
 
 	// Initialize our gRPC server using our TCP server.
-	grpcServer := grpc.NewServer()
-
-	// Initialize our fast time-series database.
-	storage, _ := tstorage.NewStorage(
-		tstorage.WithDataPath(s.dataPath),
-		tstorage.WithTimestampPrecision(s.timestampPrecision),
-		tstorage.WithPartitionDuration(s.partitionDuration),
-		tstorage.WithWriteTimeout(s.writeTimeout),
+	opts, err := s.serverOptions()
+	if err != nil {
+		log.Fatalf("failed to setup TLS: %v", err)
+	}
+
+	// Measure every request, including the ones which are rejected.
+	opts = append(opts,
+		grpc.ChainUnaryInterceptor(s.metrics.unaryInterceptor()),
+		grpc.ChainStreamInterceptor(s.metrics.streamInterceptor()),
 	)
 
+	// Require every request to have a valid bearer token if we were given
+	// the tokens to accept.
+	if s.tokenFile != "" {
+		tokens, err := loadTokens(s.tokenFile)
+		if err != nil {
+			log.Fatalf("failed to load tokens: %v", err)
+		}
+		s.tokens = tokens
+		opts = append(opts,
+			grpc.ChainUnaryInterceptor(s.unaryAuthInterceptor()),
+			grpc.ChainStreamInterceptor(s.streamAuthInterceptor()),
+		)
+	}
+	grpcServer := grpc.NewServer(opts...)
+
+	// Load the retention and rollup policies of our metrics, if any.
+	if s.policyFile != "" {
+		policies, err := loadPolicies(s.policyFile)
+		if err != nil {
+			log.Fatalf("failed to load policies: %v", err)
+		}
+		s.checkPolicies(policies)
+		s.policies = policies
+	}
+
 	// Save reference to our application state.
 	s.grpcServer = grpcServer
-	s.storage = storage
+	s.tenants = newTenantPool(s, s.multiTenant, s.maxOpenTenants)
+	s.impl = &TStorageServerImpl{
+		// DEVELOPERS NOTE:
+		// We want to attach to every gRPC call the following variables...
+		timestampPrecision: s.timestampPrecision,
+		partitionDuration:  s.partitionDuration,
+		insertBatchSize:    s.insertBatchSize,
+		policies:           s.policies,
+		tenants:            s.tenants,
+		metrics:            s.metrics,
+	}
+
+	// Register our health service, which reports that we are not serving
+	// until our storage is open, and the reflection service so clients can
+	// discover our services without needing our `.proto` file.
+	s.registerHealthServer(grpcServer)
+	reflection.Register(grpcServer)
+
+	// DEVELOPERS NOTE:
+	// Opening the storage may take a while if there are many partitions on
+	// disk, so we do it in the background to be able to answer health checks
+	// in the meantime.
+	go s.openStorage()
+
+	// Expose our metrics and HTTP APIs, and receive the data sent to our
+	// listeners, if enabled.
+	s.runHTTPServer()
+	s.runGatewayServer()
+	s.runListeners()
 
 	// For debugging purposes only.
 	log.Printf("gRPC server is running on port %v", s.port)
+	log.Printf("Data is kept for %v", formatDuration(s.retention))
 
 	// Block the main runtime loop for accepting and processing gRPC requests.
-	pb.RegisterTStorageServer(grpcServer, &TStorageServerImpl{
-		// DEVELOPERS NOTE:
-		// We want to attach to every gRPC call the following variables...
-		storage: s.storage,
-	})
+	pb.RegisterTStorageServer(grpcServer, s.impl)
 	if err := grpcServer.Serve(lis); err != nil {
 		log.Fatalf("failed to serve: %v", err)
 	}
+
+	// Wait for the state of every tenant to be saved before exiting.
+	<-s.stopped
 }
 
 // Function will tell the application to stop the main runtime loop when
@@ -91,10 +208,127 @@ func (s *TStorageServer) RunMainRuntimeLoop() {
 func (s *TStorageServer) StopMainRuntimeLoop() {
 	log.Printf("Starting graceful shutdown now...")
 
-	// Finish our database operations running.
-	s.storage.Close()
+	// Tell our health checks we are going away so no new requests are sent.
+	// The health service does not exist yet if we are stopped while starting.
+	if s.health != nil {
+		s.health.Shutdown()
+	}
+
+	// Finish any RPC communication and HTTP request taking place at the
+	// moment before shutting down our servers, so no request is still using
+	// the storage of a tenant once it is closed.
+	if s.grpcServer != nil {
+		s.grpcServer.GracefulStop()
+	}
+	if s.httpServer != nil {
+		s.httpServer.Shutdown(context.Background())
+	}
+	if s.gatewayServer != nil {
+		s.gatewayServer.Shutdown(context.Background())
+	}
+
+	// Finish our database operations running, write the data buffered by our
+	// listeners and save the final state of every tenant.
+	close(s.done)
+	s.closeListeners()
+	if s.tenants != nil {
+		s.tenants.closeAll()
+	}
+	close(s.stopped)
+}
+
+// Function will save the series catalog of every open tenant every
+// `catalogSaveInterval` until the server is stopped.
+func (s *TStorageServer) runCatalogSaver() {
+	ticker := time.NewTicker(catalogSaveInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-ticker.C:
+			s.tenants.each(func(impl *TStorageServerImpl) {
+				if err := impl.catalog.save(); err != nil {
+					log.Printf("failed to save series catalog: %v", err)
+				}
+			})
+		}
+	}
+}
+
+// Function will register our health service with the gRPC server, which
+// reports that we are not serving until `openStorage` is done.
+func (s *TStorageServer) registerHealthServer(grpcServer *grpc.Server) {
+	s.health = health.NewServer()
+	s.setServingStatus(healthpb.HealthCheckResponse_NOT_SERVING)
+	healthpb.RegisterHealthServer(grpcServer, s.health)
+}
+
+// Function will open our storage, report that we are serving and start our
+// maintenance tasks. Without multi-tenancy we open our storage right away,
+// otherwise the storage of every tenant is opened on its first request.
+func (s *TStorageServer) openStorage() {
+	if !s.multiTenant {
+		_, release, err := s.tenants.acquire("")
+		if err != nil {
+			select {
+			case <-s.done:
+				// We were stopped before our storage could be opened.
+				return
+			default:
+				log.Fatalf("failed to open storage: %v", err)
+			}
+		}
+		release()
+	}
+	s.setServingStatus(healthpb.HealthCheckResponse_SERVING)
 
-	// Finish any RPC communication taking place at the moment before
-	// shutting down the gRPC server.
-	s.grpcServer.GracefulStop()
+	// Run our maintenance tasks in the background.
+	go s.runCatalogSaver()
+	go s.runJanitor()
+	go s.runRollups()
+}
+
+// Function will set the serving status reported by our health service for
+// the server as a whole and for our `TStorage` service.
+func (s *TStorageServer) setServingStatus(servingStatus healthpb.HealthCheckResponse_ServingStatus) {
+	s.health.SetServingStatus("", servingStatus)
+	s.health.SetServingStatus(pb.TStorage_ServiceDesc.ServiceName, servingStatus)
+}
+
+// Function returns the location of the file inside of the data path or an
+// empty string if no data path was provided.
+func dataFilePath(dataPath string, name string) string {
+	if dataPath == "" {
+		return ""
+	}
+	return filepath.Join(dataPath, name)
+}
+
+// Function will make sure the policies can be applied with the settings of
+// the server and stop the server otherwise.
+//
+// DEVELOPERS NOTE:
+// The raw data points of every metric share the same storage, so a policy can
+// not keep them longer than the retention of the server. The rollups do not
+// have this limit since every tier has its own storage, but the tiers sharing
+// the same step must agree on how long to keep it.
+func (s *TStorageServer) checkPolicies(policies []*policy) {
+	unit := precisionUnit(s.timestampPrecision)
+	retentions := map[time.Duration]jsonDuration{}
+	for _, p := range policies {
+		if time.Duration(p.Retention) > s.retention {
+			log.Fatalf("policy %q keeps raw data for %v but the retention of the server is %v", p.Match, formatDuration(time.Duration(p.Retention)), formatDuration(s.retention))
+		}
+		for _, tier := range p.Rollups {
+			step := time.Duration(tier.Step)
+			if step%unit != 0 {
+				log.Fatalf("policy %q has a rollup step of %v which is not a multiple of the timestamp precision", p.Match, step)
+			}
+			if retention, ok := retentions[step]; ok && retention != tier.Retention {
+				log.Fatalf("policy %q keeps the %v rollups for a different time than another policy (%v and %v)", p.Match, formatDuration(step), tier.Retention, retention)
+			}
+			retentions[step] = tier.Retention
+		}
+	}
 }
@@ -2,99 +2,512 @@ package internal
 
 import (
 	"context"
+	"errors"
 	"io"
+	"time"
 
+	"github.com/golang/protobuf/ptypes"
 	"github.com/golang/protobuf/ptypes/empty"
-	tspb "github.com/golang/protobuf/ptypes/timestamp"
 	"github.com/nakabonne/tstorage"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 
 	pb "github.com/bartmika/tstorage-server/proto"
 )
 
 type TStorageServerImpl struct {
-	storage tstorage.Storage
+	storage            tstorage.Storage
+	tenant             string
+	dataPath           string
+	timestampPrecision tstorage.TimestampPrecision
+	partitionDuration  time.Duration
+	insertBatchSize    int
+	catalog            *seriesCatalog
+	tombstones         *tombstoneStore
+	policies           []*policy
+	rollups            *rollupStore
+	rollupStorages     map[time.Duration]tstorage.Storage
+	tenants            *tenantPool
+	metrics            *serverMetrics
 	pb.TStorageServer
 }
 
 func (s *TStorageServerImpl) InsertRow(ctx context.Context, in *pb.TimeSeriesDatum) (*empty.Empty, error) {
-	// Generate our labels, if there are any.
-	labels := []tstorage.Label{}
-	for _, label := range in.Labels {
-		labels = append(labels, tstorage.Label{Name: label.Name, Value: label.Value})
+	s, release, err := s.forTenant(ctx)
+	if err != nil {
+		return nil, err
 	}
+	defer release()
 
-	// Generate our datapoint.
-	dataPoint := tstorage.DataPoint{Timestamp: in.Timestamp.Seconds, Value: in.Value}
+	row, err := s.toRow(in)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
 
-	err := s.storage.InsertRows([]tstorage.Row{
-		{
-			Metric:    in.Metric,
-			Labels:    labels,
-			DataPoint: dataPoint,
-		},
-	})
+	err = s.insertRows([]tstorage.Row{row})
 	return &empty.Empty{}, err
 }
 
 func (s *TStorageServerImpl) InsertRows(stream pb.TStorage_InsertRowsServer) error {
+	s, release, err := s.forTenant(stream.Context())
+	if err != nil {
+		return err
+	}
+	defer release()
+
 	// DEVELOPERS NOTE:
 	// If you don't understand how server side streaming works using gRPC then
 	// please visit the documentation to get an understanding:
 	// https://grpc.io/docs/languages/go/basics/#server-side-streaming-rpc-1
 
+	res := &pb.InsertRowsResponse{}
+
+	// DEVELOPERS NOTE:
+	// Rows are buffered and written in batches to reduce the number of calls
+	// made to the storage. We keep track of the position each buffered row had
+	// in the stream so we can report which rows were rejected if the batch
+	// could not be written.
+	batch := make([]tstorage.Row, 0, s.insertBatchSize)
+	indexes := make([]uint64, 0, s.insertBatchSize)
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		if err := s.insertRows(batch); err != nil {
+			for _, index := range indexes {
+				res.Errors = append(res.Errors, &pb.RowError{Index: index, Reason: err.Error()})
+			}
+			res.Rejected += uint64(len(batch))
+		} else {
+			res.Accepted += uint64(len(batch))
+		}
+		batch = batch[:0]
+		indexes = indexes[:0]
+	}
+
 	// Wait and receieve the stream from the client.
-	for {
+	for index := uint64(0); ; index++ {
 		datum, err := stream.Recv()
 		if err == io.EOF {
-			return stream.SendAndClose(&empty.Empty{})
+			flush()
+			return stream.SendAndClose(res)
 		}
 		if err != nil {
 			return err
 		}
 
-		// Generate our labels, if there are any.
-		labels := []tstorage.Label{}
-		for _, label := range datum.Labels {
-			labels = append(labels, tstorage.Label{Name: label.Name, Value: label.Value})
+		row, err := s.toRow(datum)
+		if err != nil {
+			res.Errors = append(res.Errors, &pb.RowError{Index: index, Reason: err.Error()})
+			res.Rejected++
+			continue
 		}
 
-		// Generate our datapoint.
-		dataPoint := tstorage.DataPoint{Timestamp: datum.Timestamp.Seconds, Value: datum.Value}
+		batch = append(batch, row)
+		indexes = append(indexes, index)
+		if len(batch) >= s.insertBatchSize {
+			flush()
+		}
+	}
+}
 
-		err = s.storage.InsertRows([]tstorage.Row{
-			{
-				Metric:    datum.Metric,
-				Labels:    labels,
-				DataPoint: dataPoint,
-			},
-		})
+func (s *TStorageServerImpl) StreamInsert(stream pb.TStorage_StreamInsertServer) error {
+	s, release, err := s.forTenant(stream.Context())
+	if err != nil {
+		return err
 	}
+	defer release()
 
-	return nil
+	// DEVELOPERS NOTE:
+	// If you don't understand how bidirectional streaming works using gRPC
+	// then please visit the documentation to get an understanding:
+	// https://grpc.io/docs/languages/go/basics/#bidirectional-streaming-rpc-1
+	//
+	// Every batch is written with a single call to the storage and then
+	// acknowledged using the sequence number provided by the client. A batch
+	// is either written entirely or not at all; therefore clients can safely
+	// resend any batch which was not acknowledged to get at-least-once
+	// delivery.
+
+	for {
+		batch, err := stream.Recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		ack := &pb.InsertAck{Sequence: batch.Sequence}
+
+		// Validate every row before writing anything so the batch is never
+		// partially written.
+		rows := make([]tstorage.Row, 0, len(batch.Rows))
+		for index, datum := range batch.Rows {
+			row, err := s.toRow(datum)
+			if err != nil {
+				ack.Errors = append(ack.Errors, &pb.RowError{Index: uint64(index), Reason: err.Error()})
+				continue
+			}
+			rows = append(rows, row)
+		}
+
+		if len(ack.Errors) > 0 {
+			ack.Reason = "batch contains invalid rows"
+		} else if len(rows) > 0 {
+			if err := s.insertRows(rows); err != nil {
+				ack.Reason = err.Error()
+			} else {
+				ack.Ok = true
+			}
+		} else {
+			ack.Ok = true
+		}
+
+		if err := stream.Send(ack); err != nil {
+			return err
+		}
+	}
 }
 
 func (s *TStorageServerImpl) Select(in *pb.Filter, stream pb.TStorage_SelectServer) error {
-	// Generate our labels, if there are any.
-	labels := []tstorage.Label{}
-	for _, label := range in.Labels {
-		labels = append(labels, tstorage.Label{Name: label.Name, Value: label.Value})
+	s, release, err := s.forTenant(stream.Context())
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	q, err := s.toSeriesQuery(in)
+	if err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
 	}
 
-	points, err := s.storage.Select(in.Metric, labels, in.Start.Seconds, in.End.Seconds)
+	results, err := s.selectSeries(q)
 	if err != nil {
 		return err
 	}
 
-	for _, point := range points {
-		ts := &tspb.Timestamp{
-			Seconds: point.Timestamp,
-			Nanos:   0,
+	// DEVELOPERS NOTE:
+	// When matchers select more than one series, the data points of every
+	// series are sent one series after another. Use `SelectSeries` if you
+	// need to know which series a data point belongs to.
+	for _, ser := range results {
+		for _, point := range ser.points {
+			ts := fromUnixTimestamp(point.Timestamp, s.timestampPrecision)
+			dataPoint := &pb.DataPoint{Value: point.Value, Timestamp: ts}
+			if err := stream.Send(dataPoint); err != nil {
+				return err
+			}
 		}
-		dataPoint := &pb.DataPoint{Value: point.Value, Timestamp: ts}
-		if err := stream.Send(dataPoint); err != nil {
+	}
+
+	return nil
+}
+
+func (s *TStorageServerImpl) SelectSeries(in *pb.Filter, stream pb.TStorage_SelectSeriesServer) error {
+	s, release, err := s.forTenant(stream.Context())
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	q, err := s.toSeriesQuery(in)
+	if err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	results, err := s.selectSeries(q)
+	if err != nil {
+		return err
+	}
+
+	// Every series is sent as a single message so the client knows which
+	// metric and labels the data points belong to.
+	for _, ser := range results {
+		if err := stream.Send(s.toSeriesResponse(ser)); err != nil {
 			return err
 		}
 	}
 
 	return nil
 }
+
+func (s *TStorageServerImpl) ListMetrics(ctx context.Context, in *pb.MetadataFilter) (*pb.ListMetricsResponse, error) {
+	s, release, err := s.forTenant(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	matchers, err := toLabelMatchers(in.Matchers)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	if in.Metric != "" {
+		m, _ := newLabelMatcher(matchEqual, metricNameLabel, in.Metric)
+		matchers = append(matchers, m)
+	}
+	start, end := toUnixTimeRange(in.Start, in.End, s.timestampPrecision)
+
+	return &pb.ListMetricsResponse{
+		Metrics: s.catalog.metricNames(matchers, start, end),
+	}, nil
+}
+
+func (s *TStorageServerImpl) ListLabelNames(ctx context.Context, in *pb.MetadataFilter) (*pb.ListLabelNamesResponse, error) {
+	s, release, err := s.forTenant(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	matchers, err := toLabelMatchers(in.Matchers)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	start, end := toUnixTimeRange(in.Start, in.End, s.timestampPrecision)
+
+	return &pb.ListLabelNamesResponse{
+		Names: s.catalog.labelNames(in.Metric, matchers, start, end),
+	}, nil
+}
+
+func (s *TStorageServerImpl) ListLabelValues(ctx context.Context, in *pb.ListLabelValuesRequest) (*pb.ListLabelValuesResponse, error) {
+	s, release, err := s.forTenant(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	if in.Name == "" {
+		return nil, status.Error(codes.InvalidArgument, "label name must be set")
+	}
+	filter := in.Filter
+	if filter == nil {
+		filter = &pb.MetadataFilter{}
+	}
+	matchers, err := toLabelMatchers(filter.Matchers)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	start, end := toUnixTimeRange(filter.Start, filter.End, s.timestampPrecision)
+
+	return &pb.ListLabelValuesResponse{
+		Values: s.catalog.labelValues(in.Name, filter.Metric, matchers, start, end),
+	}, nil
+}
+
+func (s *TStorageServerImpl) Aggregate(ctx context.Context, in *pb.AggregateRequest) (*pb.AggregateResponse, error) {
+	s, release, err := s.forTenant(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	if in.Filter == nil {
+		return nil, status.Error(codes.InvalidArgument, "filter must be set")
+	}
+	if in.Function == pb.Function_FUNCTION_NONE && in.Aggregation == pb.Aggregation_AGGREGATION_UNSPECIFIED {
+		return nil, status.Error(codes.InvalidArgument, "aggregation or function must be set")
+	}
+	q, err := s.toSeriesQuery(in.Filter)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	results, err := s.selectSeries(q)
+	if err != nil {
+		return nil, err
+	}
+
+	// DEVELOPERS NOTE:
+	// The aggregation runs inside the server so only a single value for
+	// every series has to be sent back to the client.
+	res := &pb.AggregateResponse{}
+	for _, ser := range results {
+		var value float64
+		if in.Function == pb.Function_FUNCTION_NONE {
+			value, err = aggregateValues(in.Aggregation, pointValues(ser.points))
+		} else {
+			// The counter function is applied over the entire time range.
+			var ok bool
+			seconds := float64(q.end-q.start) * precisionUnit(s.timestampPrecision).Seconds()
+			value, ok, err = counterValue(in.Function, ser.points, seconds, s.timestampPrecision)
+			if err == nil && !ok {
+				continue
+			}
+		}
+		if err != nil {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+		res.Series = append(res.Series, &pb.AggregatedSeries{
+			Metric: ser.metric,
+			Labels: toProtoLabels(ser.labels),
+			Value:  value,
+			Count:  uint64(len(ser.points)),
+		})
+	}
+	return res, nil
+}
+
+func (s *TStorageServerImpl) RangeQuery(in *pb.RangeQueryRequest, stream pb.TStorage_RangeQueryServer) error {
+	s, release, err := s.forTenant(stream.Context())
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	if in.Filter == nil {
+		return status.Error(codes.InvalidArgument, "filter must be set")
+	}
+	q, err := s.toSeriesQuery(in.Filter)
+	if err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	r, err := s.toRangeQuery(in, q)
+	if err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	// DEVELOPERS NOTE:
+	// If the policy of the metric has a rollup tier fitting the query then we
+	// merge the rollups of the tier instead of reading every raw data point.
+	// Counter functions need the last data point before every bucket, so we
+	// look back one additional step when selecting the data points.
+	start := q.start
+	tier := s.rollupTier(q, r)
+	var results []*series
+	if tier != nil {
+		results, err = s.selectRollupSeries(q, tier, r)
+	} else {
+		if r.function != pb.Function_FUNCTION_NONE {
+			q.start -= r.step
+		}
+		results, err = s.selectSeries(q)
+	}
+	if err != nil {
+		return err
+	}
+
+	// Every series is sent with one data point per bucket instead of the
+	// raw data points.
+	for _, ser := range results {
+		if tier == nil {
+			points, err := r.bucketize(ser.points, start, q.end)
+			if err != nil {
+				return status.Error(codes.InvalidArgument, err.Error())
+			}
+			ser.points = points
+		}
+		if err := stream.Send(s.toSeriesResponse(ser)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *TStorageServerImpl) Delete(ctx context.Context, in *pb.Filter) (*pb.DeleteResponse, error) {
+	s, release, err := s.forTenant(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	q, err := s.toSeriesQuery(in)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	// A missing start or end will delete everything before or after.
+	start, end := toUnixTimeRange(in.Start, in.End, s.timestampPrecision)
+	if start >= end {
+		return nil, status.Error(codes.InvalidArgument, "start must be before end")
+	}
+
+	// Find every series to delete. Without matchers we only delete the
+	// series with the exact label set.
+	deleted := s.catalog.lookup(q.metric, q.labels, start, end)
+	if len(q.matchers) > 0 {
+		deleted = s.catalog.find(q.metric, q.matchers, start, end)
+	}
+	if len(deleted) == 0 {
+		return &pb.DeleteResponse{Series: 0}, nil
+	}
+
+	// DEVELOPERS NOTE:
+	// The deleted data points are hidden right away by the tombstones and
+	// will be removed from the disk once their partition expires. Without an
+	// end we only delete up to the newest data point of every series, since
+	// a tombstone without an end would hide the data points written after
+	// the delete and could never be purged.
+	tombstones := make([]*tombstone, 0, len(deleted))
+	for _, ser := range deleted {
+		to := end
+		if newest := s.catalog.newest(ser.metric, ser.labels); in.End == nil && newest < end {
+			to = newest + 1
+		}
+		tombstones = append(tombstones, &tombstone{Metric: ser.metric, Labels: ser.labels, Start: start, End: to})
+	}
+	if err := s.tombstones.add(tombstones); err != nil {
+		return nil, err
+	}
+	s.catalog.remove(deleted, start, end)
+
+	// Save the catalog right away so the log can not bring back the removed
+	// series after a crash.
+	if err := s.catalog.save(); err != nil {
+		return nil, err
+	}
+
+	return &pb.DeleteResponse{Series: uint64(len(deleted))}, nil
+}
+
+// Function will write the rows to the storage and keep track of their series
+// in our catalog. All inserts must go through this function.
+//
+// DEVELOPERS NOTE:
+// The series are added to our catalog first so a series can never be in the
+// storage without being in the catalog, even if the server crashes right
+// after writing the rows.
+func (s *TStorageServerImpl) insertRows(rows []tstorage.Row) error {
+	if err := s.catalog.add(rows); err != nil {
+		return err
+	}
+	if err := s.storage.InsertRows(rows); err != nil {
+		return err
+	}
+	s.metrics.addRowsInserted(len(rows))
+	return nil
+}
+
+// Function will validate the time-series datum sent by the client and convert
+// it into the row format used by `tstorage`.
+func (s *TStorageServerImpl) toRow(datum *pb.TimeSeriesDatum) (tstorage.Row, error) {
+	if datum.Metric == "" {
+		return tstorage.Row{}, errors.New("metric must be set")
+	}
+
+	// Generate our labels, if there are any.
+	labels := []tstorage.Label{}
+	for _, label := range datum.Labels {
+		if label.Name == "" || label.Value == "" {
+			return tstorage.Row{}, errors.New("label name and value must be set")
+		}
+		labels = append(labels, tstorage.Label{Name: label.Name, Value: label.Value})
+	}
+
+	// Generate our datapoint. If the client did not provide a timestamp then
+	// we will use the current time of the server.
+	ts := datum.Timestamp
+	if ts == nil {
+		ts = ptypes.TimestampNow()
+	}
+	dataPoint := tstorage.DataPoint{Timestamp: toUnixTimestamp(ts, s.timestampPrecision), Value: datum.Value}
+
+	return tstorage.Row{
+		Metric:    datum.Metric,
+		Labels:    labels,
+		DataPoint: dataPoint,
+	}, nil
+}
@@ -0,0 +1,380 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	tspb "github.com/golang/protobuf/ptypes/timestamp"
+	"github.com/nakabonne/tstorage"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/bartmika/tstorage-server/proto"
+)
+
+// Function returns a server, with its storage kept in memory and a timestamp
+// precision of seconds, which is ready to handle requests without listening
+// on any port.
+func newTestServer(t *testing.T, opts ...Option) *TStorageServer {
+	t.Helper()
+	s := New(0, "", "s", time.Hour, time.Second, opts...)
+	s.tenants = newTenantPool(s, s.multiTenant, s.maxOpenTenants)
+	s.impl = &TStorageServerImpl{
+		timestampPrecision: s.timestampPrecision,
+		partitionDuration:  s.partitionDuration,
+		insertBatchSize:    s.insertBatchSize,
+		policies:           s.policies,
+		tenants:            s.tenants,
+		metrics:            s.metrics,
+	}
+	t.Cleanup(s.tenants.closeAll)
+	return s
+}
+
+// Function returns every data point of the series stored by the tenant.
+func selectTestPoints(t *testing.T, s *TStorageServer, tenant string, metric string, labels ...tstorage.Label) []*tstorage.DataPoint {
+	t.Helper()
+	impl, release, err := s.tenants.acquire(tenant)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer release()
+	points, err := impl.selectPoints(metric, labels, 0, 1<<62)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return points
+}
+
+// testInsertRowsStream sends the time-series data to `InsertRows` and keeps
+// the response.
+type testInsertRowsStream struct {
+	gatewayStream
+	data []*pb.TimeSeriesDatum
+	res  *pb.InsertRowsResponse
+}
+
+func (s *testInsertRowsStream) Recv() (*pb.TimeSeriesDatum, error) {
+	if len(s.data) == 0 {
+		return nil, io.EOF
+	}
+	datum := s.data[0]
+	s.data = s.data[1:]
+	return datum, nil
+}
+
+func (s *testInsertRowsStream) SendAndClose(res *pb.InsertRowsResponse) error {
+	s.res = res
+	return nil
+}
+
+// Function returns a datum of the `cpu` metric at the time in seconds.
+func testDatum(seconds int64, value float64, labels ...*pb.Label) *pb.TimeSeriesDatum {
+	return &pb.TimeSeriesDatum{Metric: "cpu", Labels: labels, Value: value, Timestamp: &tspb.Timestamp{Seconds: seconds}}
+}
+
+func TestInsertRows(t *testing.T) {
+	tests := []struct {
+		name      string
+		batchSize int
+		data      []*pb.TimeSeriesDatum
+		accepted  uint64
+		errors    []uint64
+	}{
+		{"valid", 1000, []*pb.TimeSeriesDatum{testDatum(1600000000, 1), testDatum(1600000001, 2)}, 2, nil},
+		{"invalid rows", 1000, []*pb.TimeSeriesDatum{
+			testDatum(1600000000, 1),
+			{Value: 2},
+			testDatum(1600000002, 3),
+			testDatum(1600000003, 4, &pb.Label{Name: "host"}),
+		}, 2, []uint64{1, 3}},
+		{"invalid rows across batches", 2, []*pb.TimeSeriesDatum{
+			testDatum(1600000000, 1),
+			{Value: 2},
+			testDatum(1600000002, 3),
+			testDatum(1600000003, 4),
+			testDatum(1600000004, 5, &pb.Label{Value: "web01"}),
+			testDatum(1600000005, 6),
+			testDatum(1600000006, 7),
+		}, 5, []uint64{1, 4}},
+		{"only invalid rows", 2, []*pb.TimeSeriesDatum{{Value: 1}, {Value: 2}}, 0, []uint64{0, 1}},
+		{"empty", 2, nil, 0, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestServer(t, WithInsertBatchSize(tt.batchSize))
+			stream := &testInsertRowsStream{gatewayStream: gatewayStream{ctx: context.Background()}, data: tt.data}
+			if err := s.impl.InsertRows(stream); err != nil {
+				t.Fatal(err)
+			}
+			res := stream.res
+			if res.Accepted != tt.accepted || res.Rejected != uint64(len(tt.errors)) || len(res.Errors) != len(tt.errors) {
+				t.Fatalf("got %v, want %v accepted and %v rejected rows", res, tt.accepted, tt.errors)
+			}
+			for i, index := range tt.errors {
+				if res.Errors[i].Index != index || res.Errors[i].Reason == "" {
+					t.Errorf("error %v: got %v, want the index %v with a reason", i, res.Errors[i], index)
+				}
+			}
+			if n := len(selectTestPoints(t, s, "", "cpu")); uint64(n) != tt.accepted {
+				t.Errorf("got %v stored data points, want %v", n, tt.accepted)
+			}
+		})
+	}
+}
+
+// testStreamInsertStream sends the batches to `StreamInsert` and keeps the
+// acknowledgements.
+type testStreamInsertStream struct {
+	gatewayStream
+	batches []*pb.InsertBatch
+	acks    []*pb.InsertAck
+}
+
+func (s *testStreamInsertStream) Recv() (*pb.InsertBatch, error) {
+	if len(s.batches) == 0 {
+		return nil, io.EOF
+	}
+	batch := s.batches[0]
+	s.batches = s.batches[1:]
+	return batch, nil
+}
+
+func (s *testStreamInsertStream) Send(ack *pb.InsertAck) error {
+	s.acks = append(s.acks, ack)
+	return nil
+}
+
+func TestStreamInsert(t *testing.T) {
+	s := newTestServer(t)
+	stream := &testStreamInsertStream{gatewayStream: gatewayStream{ctx: context.Background()}, batches: []*pb.InsertBatch{
+		{Sequence: 1, Rows: []*pb.TimeSeriesDatum{testDatum(1600000000, 1), testDatum(1600000001, 2)}},
+		{Sequence: 2, Rows: []*pb.TimeSeriesDatum{testDatum(1600000002, 3), {Value: 4}, testDatum(1600000004, 5, &pb.Label{Name: "host"})}},
+		{Sequence: 3},
+		{Sequence: 4, Rows: []*pb.TimeSeriesDatum{testDatum(1600000005, 6)}},
+	}}
+	if err := s.impl.StreamInsert(stream); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		sequence uint64
+		ok       bool
+		errors   []uint64
+	}{
+		{1, true, nil},
+		{2, false, []uint64{1, 2}},
+		{3, true, nil},
+		{4, true, nil},
+	}
+	if len(stream.acks) != len(tests) {
+		t.Fatalf("got %v acknowledgements, want %v", len(stream.acks), len(tests))
+	}
+	for i, tt := range tests {
+		ack := stream.acks[i]
+		if ack.Sequence != tt.sequence || ack.Ok != tt.ok || (ack.Reason == "") != tt.ok || len(ack.Errors) != len(tt.errors) {
+			t.Fatalf("acknowledgement %v: got %v, want sequence %v acknowledged %v with the errors %v", i, ack, tt.sequence, tt.ok, tt.errors)
+		}
+		for j, index := range tt.errors {
+			if ack.Errors[j].Index != index {
+				t.Errorf("acknowledgement %v: got error %v, want the index %v", i, ack.Errors[j], index)
+			}
+		}
+	}
+
+	// Nothing of the rejected batch is written, not even its valid row.
+	points := selectTestPoints(t, s, "", "cpu")
+	want := []float64{1, 2, 6}
+	if len(points) != len(want) {
+		t.Fatalf("got %v stored data points, want %v", len(points), len(want))
+	}
+	for i, point := range points {
+		if point.Value != want[i] {
+			t.Errorf("data point %v: got %v, want %v", i, point.Value, want[i])
+		}
+	}
+}
+
+// testSelectSeriesStream keeps the series sent by `SelectSeries`.
+type testSelectSeriesStream struct {
+	gatewayStream
+	series []*pb.Series
+}
+
+func (s *testSelectSeriesStream) Send(ser *pb.Series) error {
+	s.series = append(s.series, ser)
+	return nil
+}
+
+// Function returns the series as its metric and labels followed by the time
+// and value of every data point, for example `cpu{host=a} 1600000000=1`.
+func formatTestSeries(ser *pb.Series) string {
+	labels := []string{}
+	for _, label := range ser.Labels {
+		labels = append(labels, label.Name+"="+label.Value)
+	}
+	s := ser.Metric + "{" + strings.Join(labels, ",") + "}"
+	for _, point := range ser.Points {
+		s += fmt.Sprintf(" %v=%v", point.Timestamp.Seconds, point.Value)
+	}
+	return s
+}
+
+func TestSelectSeries(t *testing.T) {
+	s := newTestServer(t)
+	stream := &testInsertRowsStream{gatewayStream: gatewayStream{ctx: context.Background()}, data: []*pb.TimeSeriesDatum{
+		testDatum(1600000000, 1, &pb.Label{Name: "host", Value: "a"}),
+		testDatum(1600000001, 2, &pb.Label{Name: "host", Value: "a"}),
+		testDatum(1600000002, 3, &pb.Label{Name: "host", Value: "a"}),
+		testDatum(1600000000, 10, &pb.Label{Name: "host", Value: "b"}),
+		testDatum(1600000002, 30, &pb.Label{Name: "host", Value: "b"}),
+	}}
+	if err := s.impl.InsertRows(stream); err != nil {
+		t.Fatal(err)
+	}
+
+	hosts := []*pb.LabelMatcher{{Name: "host", Value: ".+", Type: pb.LabelMatcher_RE}}
+	tests := []struct {
+		name   string
+		filter *pb.Filter
+		want   []string
+	}{
+		{"every series", &pb.Filter{Metric: "cpu", Matchers: hosts, Start: &tspb.Timestamp{Seconds: 1600000000}, End: &tspb.Timestamp{Seconds: 1600000003}}, []string{
+			"cpu{host=a} 1600000000=1 1600000001=2 1600000002=3",
+			"cpu{host=b} 1600000000=10 1600000002=30",
+		}},
+		{"time range", &pb.Filter{Metric: "cpu", Matchers: hosts, Start: &tspb.Timestamp{Seconds: 1600000001}, End: &tspb.Timestamp{Seconds: 1600000002}}, []string{
+			"cpu{host=a} 1600000001=2",
+		}},
+		{"exact labels", &pb.Filter{Metric: "cpu", Labels: []*pb.Label{{Name: "host", Value: "b"}}, Start: &tspb.Timestamp{Seconds: 1600000000}, End: &tspb.Timestamp{Seconds: 1600000003}}, []string{
+			"cpu{host=b} 1600000000=10 1600000002=30",
+		}},
+		{"outside of the time range", &pb.Filter{Metric: "cpu", Matchers: hosts, Start: &tspb.Timestamp{Seconds: 1600000003}, End: &tspb.Timestamp{Seconds: 1600000004}}, []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stream := &testSelectSeriesStream{gatewayStream: gatewayStream{ctx: context.Background()}}
+			if err := s.impl.SelectSeries(tt.filter, stream); err != nil {
+				t.Fatal(err)
+			}
+			got := []string{}
+			for _, ser := range stream.series {
+				got = append(got, formatTestSeries(ser))
+			}
+			sort.Strings(got)
+			if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("got\n%v\nwant\n%v", strings.Join(got, "\n"), strings.Join(tt.want, "\n"))
+			}
+		})
+	}
+
+	err := s.impl.SelectSeries(&pb.Filter{}, &testSelectSeriesStream{gatewayStream: gatewayStream{ctx: context.Background()}})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("got error %v, want %v", err, codes.InvalidArgument)
+	}
+}
+
+func TestDelete(t *testing.T) {
+	tests := []struct {
+		name    string
+		filter  *pb.Filter
+		deleted uint64
+		a       int
+		b       int
+		metrics []string
+	}{
+		{"time range", &pb.Filter{Metric: "cpu", Labels: []*pb.Label{{Name: "host", Value: "a"}}, Start: &tspb.Timestamp{Seconds: 1600000001}, End: &tspb.Timestamp{Seconds: 1600000002}}, 1, 2, 2, []string{"cpu"}},
+		{"without start", &pb.Filter{Metric: "cpu", Labels: []*pb.Label{{Name: "host", Value: "a"}}, End: &tspb.Timestamp{Seconds: 1600000002}}, 1, 1, 2, []string{"cpu"}},
+		{"without end", &pb.Filter{Metric: "cpu", Labels: []*pb.Label{{Name: "host", Value: "a"}}, Start: &tspb.Timestamp{Seconds: 1600000001}}, 1, 1, 2, []string{"cpu"}},
+		{"series", &pb.Filter{Metric: "cpu", Labels: []*pb.Label{{Name: "host", Value: "a"}}}, 1, 0, 2, []string{"cpu"}},
+		{"metric", &pb.Filter{Metric: "cpu", Matchers: []*pb.LabelMatcher{{Name: "host", Value: ".+", Type: pb.LabelMatcher_RE}}}, 2, 0, 0, []string{}},
+		{"no matching series", &pb.Filter{Metric: "mem"}, 0, 3, 2, []string{"cpu"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestServer(t)
+			ctx := context.Background()
+			a := &pb.Label{Name: "host", Value: "a"}
+			b := &pb.Label{Name: "host", Value: "b"}
+			insert := func(data ...*pb.TimeSeriesDatum) {
+				if err := s.impl.InsertRows(&testInsertRowsStream{gatewayStream: gatewayStream{ctx: ctx}, data: data}); err != nil {
+					t.Fatal(err)
+				}
+			}
+			insert(testDatum(1600000000, 1, a), testDatum(1600000001, 2, a), testDatum(1600000002, 3, a), testDatum(1600000000, 10, b), testDatum(1600000002, 30, b))
+
+			res, err := s.impl.Delete(ctx, tt.filter)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res.Series != tt.deleted {
+				t.Errorf("got %v deleted series, want %v", res.Series, tt.deleted)
+			}
+			if n := len(selectTestPoints(t, s, "", "cpu", tstorage.Label{Name: "host", Value: "a"})); n != tt.a {
+				t.Errorf("got %v data points of host a, want %v", n, tt.a)
+			}
+			if n := len(selectTestPoints(t, s, "", "cpu", tstorage.Label{Name: "host", Value: "b"})); n != tt.b {
+				t.Errorf("got %v data points of host b, want %v", n, tt.b)
+			}
+			metrics, err := s.impl.ListMetrics(ctx, &pb.MetadataFilter{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if strings.Join(metrics.Metrics, ",") != strings.Join(tt.metrics, ",") {
+				t.Errorf("got the metrics %v, want %v", metrics.Metrics, tt.metrics)
+			}
+
+			// The data points written after the delete are not hidden and
+			// bring back the series.
+			insert(testDatum(1600000010, 4, a), testDatum(1600000010, 40, b))
+			if n := len(selectTestPoints(t, s, "", "cpu", tstorage.Label{Name: "host", Value: "a"})); n != tt.a+1 {
+				t.Errorf("got %v data points of host a after inserting, want %v", n, tt.a+1)
+			}
+			if n := len(selectTestPoints(t, s, "", "cpu", tstorage.Label{Name: "host", Value: "b"})); n != tt.b+1 {
+				t.Errorf("got %v data points of host b after inserting, want %v", n, tt.b+1)
+			}
+			metrics, err = s.impl.ListMetrics(ctx, &pb.MetadataFilter{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if strings.Join(metrics.Metrics, ",") != "cpu" {
+				t.Errorf("got the metrics %v after inserting, want cpu", metrics.Metrics)
+			}
+		})
+	}
+}
+
+func TestHealth(t *testing.T) {
+	s := newTestServer(t)
+	s.registerHealthServer(grpc.NewServer())
+
+	assertServingStatus := func(want healthpb.HealthCheckResponse_ServingStatus) {
+		t.Helper()
+		for _, service := range []string{"", pb.TStorage_ServiceDesc.ServiceName} {
+			res, err := s.health.Check(context.Background(), &healthpb.HealthCheckRequest{Service: service})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res.Status != want {
+				t.Errorf("service %q: got %v, want %v", service, res.Status, want)
+			}
+		}
+	}
+
+	assertServingStatus(healthpb.HealthCheckResponse_NOT_SERVING)
+	s.openStorage()
+	assertServingStatus(healthpb.HealthCheckResponse_SERVING)
+	s.StopMainRuntimeLoop()
+	assertServingStatus(healthpb.HealthCheckResponse_NOT_SERVING)
+
+	// Nothing is reported as serving once we were stopped.
+	s.setServingStatus(healthpb.HealthCheckResponse_SERVING)
+	assertServingStatus(healthpb.HealthCheckResponse_NOT_SERVING)
+}
@@ -0,0 +1,291 @@
+package internal
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nakabonne/tstorage"
+)
+
+// defaultStatsdFlushInterval is how often the StatsD metrics are aggregated
+// and written to storage by default.
+const defaultStatsdFlushInterval = 10 * time.Second
+
+// statsdPercentiles are the percentiles computed for every timer.
+var statsdPercentiles = []float64{50, 90, 95, 99}
+
+// The types of the StatsD metrics.
+const (
+	statsdCounter = "c"
+	statsdGauge   = "g"
+	statsdTimer   = "ms"
+	statsdSet     = "s"
+)
+
+// statsdSample is a single value sent to our StatsD listener, such as
+// `api.requests:1|c|@0.5|#route:login`.
+type statsdSample struct {
+	name   string
+	typ    string
+	raw    string
+	value  float64
+	rate   float64
+	labels []tstorage.Label
+}
+
+// statsdMetric is the state of a metric, with its labels, aggregated since
+// the last flush.
+type statsdMetric struct {
+	name   string
+	typ    string
+	labels []tstorage.Label
+
+	// Whether the metric received a sample since the last flush.
+	updated bool
+
+	// The sum of a counter or the last value of a gauge.
+	value float64
+
+	// The values of a timer along with how many values they stand for once
+	// the sample rates are taken into account.
+	values []float64
+	count  float64
+
+	// The unique values of a set.
+	set map[string]bool
+}
+
+// statsdAggregator aggregates the samples received by our StatsD listener and
+// writes the aggregated rows every flush interval.
+//
+// DEVELOPERS NOTE:
+// Every flush writes, for every metric which received a sample:
+//
+//   - for counters, `<name>.count` with the sum of the values and
+//     `<name>.rate` with the sum per second,
+//   - for gauges, `<name>` with the last value,
+//   - for timers, histograms and distributions, `<name>.count`, `.sum`,
+//     `.mean`, `.min`, `.max` and the `.p50`, `.p90`, `.p95` and `.p99`
+//     percentiles,
+//   - for sets, `<name>.count` with the number of unique values.
+//
+// The value of gauges is kept between flushes so the `+` and `-` relative
+// changes of gauges keep working.
+type statsdAggregator struct {
+	mu sync.Mutex
+
+	server   *TStorageServer
+	batcher  *rowBatcher
+	interval time.Duration
+	metrics  map[string]*statsdMetric
+}
+
+// Function will create our aggregator and start flushing it in the
+// background until the server is stopped.
+func (s *TStorageServer) newStatsdAggregator(batcher *rowBatcher) *statsdAggregator {
+	a := &statsdAggregator{
+		server:   s,
+		batcher:  batcher,
+		interval: s.statsdFlushInterval,
+		metrics:  map[string]*statsdMetric{},
+	}
+	go func() {
+		ticker := time.NewTicker(a.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-s.done:
+				return
+			case <-ticker.C:
+				a.flush()
+			}
+		}
+	}()
+	return a
+}
+
+// Function will parse the lines of the packet and aggregate their samples.
+// Invalid lines are logged and skipped.
+func (a *statsdAggregator) handlePacket(packet []byte) {
+	for _, line := range strings.Split(string(packet), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		sample, err := parseStatsdLine(line)
+		if err != nil {
+			log.Printf("statsd udp: %v", err)
+			continue
+		}
+		a.add(sample)
+	}
+}
+
+// Function will aggregate the sample.
+func (a *statsdAggregator) add(sample *statsdSample) {
+	labels := sortedLabels(sample.labels)
+	key := sample.typ + "\x00" + seriesID(sample.name, labels)
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	m, ok := a.metrics[key]
+	if !ok {
+		m = &statsdMetric{name: sample.name, typ: sample.typ, labels: labels}
+		a.metrics[key] = m
+	}
+	m.updated = true
+	switch sample.typ {
+	case statsdCounter:
+		m.value += sample.value / sample.rate
+	case statsdGauge:
+		if strings.HasPrefix(sample.raw, "+") || strings.HasPrefix(sample.raw, "-") {
+			m.value += sample.value
+		} else {
+			m.value = sample.value
+		}
+	case statsdTimer:
+		m.values = append(m.values, sample.value)
+		m.count += 1 / sample.rate
+	case statsdSet:
+		if m.set == nil {
+			m.set = map[string]bool{}
+		}
+		m.set[sample.raw] = true
+	}
+}
+
+// Function will write the rows aggregated since the last flush and reset the
+// metrics.
+func (a *statsdAggregator) flush() {
+	now := time.Now()
+	ts := timeToUnix(now, a.server.timestampPrecision)
+
+	a.mu.Lock()
+	rows := []tstorage.Row{}
+	row := func(m *statsdMetric, suffix string, value float64) {
+		rows = append(rows, tstorage.Row{
+			Metric:    m.name + suffix,
+			Labels:    m.labels,
+			DataPoint: tstorage.DataPoint{Timestamp: ts, Value: value},
+		})
+	}
+	for key, m := range a.metrics {
+		if !m.updated {
+			// Only gauges are kept around once they were flushed.
+			continue
+		}
+		switch m.typ {
+		case statsdCounter:
+			row(m, ".count", m.value)
+			row(m, ".rate", m.value/a.interval.Seconds())
+		case statsdGauge:
+			row(m, "", m.value)
+		case statsdTimer:
+			sort.Float64s(m.values)
+			sum := 0.0
+			for _, v := range m.values {
+				sum += v
+			}
+			row(m, ".count", m.count)
+			row(m, ".sum", sum)
+			row(m, ".mean", sum/float64(len(m.values)))
+			row(m, ".min", m.values[0])
+			row(m, ".max", m.values[len(m.values)-1])
+			for _, p := range statsdPercentiles {
+				row(m, fmt.Sprintf(".p%v", p), percentile(m.values, p))
+			}
+		case statsdSet:
+			row(m, ".count", float64(len(m.set)))
+		}
+		if m.typ == statsdGauge {
+			m.updated = false
+		} else {
+			delete(a.metrics, key)
+		}
+	}
+	a.mu.Unlock()
+
+	if len(rows) > 0 {
+		a.batcher.add(rows)
+		a.batcher.flush()
+	}
+}
+
+// Function will write the remaining aggregated rows when the server is
+// stopped.
+func (a *statsdAggregator) Close() error {
+	a.flush()
+	return nil
+}
+
+// Function returns the percentile of the sorted values using the nearest rank
+// method.
+func percentile(sorted []float64, p float64) float64 {
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
+
+// Function will parse a line of the StatsD protocol, along with the sample
+// rate and tags of the DogStatsD extension, such as
+// `api.latency:320|ms|@0.1|#route:login,region:eu`. Histograms and
+// distributions are treated as timers, and tags without a value are ignored.
+func parseStatsdLine(line string) (*statsdSample, error) {
+	i := strings.LastIndex(line[:strings.IndexByte(line+"|", '|')], ":")
+	if i <= 0 {
+		return nil, fmt.Errorf("invalid line %q", line)
+	}
+	sample := &statsdSample{name: line[:i], rate: 1}
+	parts := strings.Split(line[i+1:], "|")
+	if len(parts) < 2 {
+		return nil, fmt.Errorf("invalid line %q", line)
+	}
+	sample.raw = parts[0]
+
+	switch parts[1] {
+	case "c", "g", "ms", "s":
+		sample.typ = parts[1]
+	case "h", "d":
+		sample.typ = statsdTimer
+	default:
+		return nil, fmt.Errorf("unsupported type %q of %q", parts[1], sample.name)
+	}
+
+	if sample.typ != statsdSet {
+		v, err := strconv.ParseFloat(sample.raw, 64)
+		if err != nil || math.IsNaN(v) || math.IsInf(v, 0) {
+			return nil, fmt.Errorf("invalid value %q of %q", sample.raw, sample.name)
+		}
+		sample.value = v
+	} else if sample.raw == "" {
+		return nil, fmt.Errorf("invalid value %q of %q", sample.raw, sample.name)
+	}
+
+	for _, part := range parts[2:] {
+		switch {
+		case strings.HasPrefix(part, "@"):
+			rate, err := strconv.ParseFloat(part[1:], 64)
+			if err != nil || rate <= 0 || rate > 1 {
+				return nil, fmt.Errorf("invalid sample rate %q of %q", part, sample.name)
+			}
+			sample.rate = rate
+		case strings.HasPrefix(part, "#"):
+			for _, tag := range strings.Split(part[1:], ",") {
+				kv := strings.SplitN(tag, ":", 2)
+				if len(kv) == 2 && kv[0] != "" && kv[1] != "" {
+					sample.labels = append(sample.labels, tstorage.Label{Name: kv[0], Value: kv[1]})
+				}
+			}
+		}
+	}
+	return sample, nil
+}
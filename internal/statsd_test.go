@@ -0,0 +1,136 @@
+package internal
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/nakabonne/tstorage"
+)
+
+func TestParseStatsdLine(t *testing.T) {
+	tests := []struct {
+		name    string
+		line    string
+		want    *statsdSample
+		wantErr bool
+	}{
+		{"counter", "api.requests:1|c", &statsdSample{name: "api.requests", typ: statsdCounter, raw: "1", value: 1, rate: 1}, false},
+		{"gauge change", "load:-5|g", &statsdSample{name: "load", typ: statsdGauge, raw: "-5", value: -5, rate: 1}, false},
+		{"timer with rate and tags", "api.latency:320|ms|@0.1|#route:login,region:eu", &statsdSample{
+			name: "api.latency", typ: statsdTimer, raw: "320", value: 320, rate: 0.1,
+			labels: []tstorage.Label{{Name: "route", Value: "login"}, {Name: "region", Value: "eu"}},
+		}, false},
+		{"histogram", "size:2.5|h", &statsdSample{name: "size", typ: statsdTimer, raw: "2.5", value: 2.5, rate: 1}, false},
+		{"distribution", "size:3|d", &statsdSample{name: "size", typ: statsdTimer, raw: "3", value: 3, rate: 1}, false},
+		{"set", "users:alice|s", &statsdSample{name: "users", typ: statsdSet, raw: "alice", rate: 1}, false},
+		{"colon in name", "a:b:1|c", &statsdSample{name: "a:b", typ: statsdCounter, raw: "1", value: 1, rate: 1}, false},
+		{"tags without value are ignored", "a:1|c|#b,c:,:d,e:f:g", &statsdSample{
+			name: "a", typ: statsdCounter, raw: "1", value: 1, rate: 1,
+			labels: []tstorage.Label{{Name: "e", Value: "f:g"}},
+		}, false},
+		{"unknown parts are ignored", "a:1|c|c:1", &statsdSample{name: "a", typ: statsdCounter, raw: "1", value: 1, rate: 1}, false},
+		{"empty", "", nil, true},
+		{"missing value", "a", nil, true},
+		{"missing name", ":1|c", nil, true},
+		{"missing type", "a:1", nil, true},
+		{"colon after the value", "a|c:1", nil, true},
+		{"unsupported type", "a:1|x", nil, true},
+		{"invalid value", "a:abc|c", nil, true},
+		{"NaN", "a:NaN|g", nil, true},
+		{"infinity", "a:Inf|ms", nil, true},
+		{"empty set value", "a:|s", nil, true},
+		{"zero sample rate", "a:1|c|@0", nil, true},
+		{"sample rate above one", "a:1|c|@2", nil, true},
+		{"invalid sample rate", "a:1|c|@abc", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseStatsdLine(tt.line)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPercentile(t *testing.T) {
+	tests := []struct {
+		name   string
+		sorted []float64
+		p      float64
+		want   float64
+	}{
+		{"single value", []float64{7}, 99, 7},
+		{"median", []float64{1, 2, 3, 4}, 50, 2},
+		{"nearest rank", []float64{1, 2, 3, 4}, 90, 4},
+		{"zero percentile", []float64{1, 2, 3, 4}, 0, 1},
+		{"maximum", []float64{1, 2, 3, 4}, 100, 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := percentile(tt.sorted, tt.p); got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStatsdAggregatorFlush(t *testing.T) {
+	s := newTestServer(t)
+	a := &statsdAggregator{
+		server:   s,
+		batcher:  &rowBatcher{server: s, name: "statsd", tenant: s.listenerTenant(), size: 1000},
+		interval: 10 * time.Second,
+		metrics:  map[string]*statsdMetric{},
+	}
+	a.handlePacket([]byte("hits:1|c\nhits:2|c|@0.5\n\nload:5|g\nload:+2|g\nload:-1|g\n" +
+		"lat:10|ms\nlat:20|ms|@0.5\nlat:30|h\nusers:a|s\nusers:b|s\nusers:a|s\ninvalid\nhits:1|c|#route:login\n"))
+	a.flush()
+
+	tests := []struct {
+		metric string
+		labels []tstorage.Label
+		want   float64
+	}{
+		{"hits.count", nil, 5},
+		{"hits.rate", nil, 0.5},
+		{"hits.count", []tstorage.Label{{Name: "route", Value: "login"}}, 1},
+		{"load", nil, 6},
+		{"lat.count", nil, 4},
+		{"lat.sum", nil, 60},
+		{"lat.mean", nil, 20},
+		{"lat.min", nil, 10},
+		{"lat.max", nil, 30},
+		{"lat.p50", nil, 20},
+		{"lat.p99", nil, 30},
+		{"users.count", nil, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.metric, func(t *testing.T) {
+			points := selectTestPoints(t, s, s.listenerTenant(), tt.metric, tt.labels...)
+			if len(points) != 1 || points[0].Value != tt.want {
+				t.Errorf("got %+v, want a single %v", points, tt.want)
+			}
+		})
+	}
+
+	// Only the gauge is kept, without being written again until it changes.
+	if len(a.metrics) != 1 {
+		t.Fatalf("got %v metrics after the flush, want 1", len(a.metrics))
+	}
+	for _, m := range a.metrics {
+		if m.name != "load" || m.updated {
+			t.Errorf("got %+v, want the gauge which was not updated", m)
+		}
+	}
+	a.handlePacket([]byte("load:+1|g"))
+	for _, m := range a.metrics {
+		if m.value != 7 || !m.updated {
+			t.Errorf("got %+v, want the gauge changed to 7", m)
+		}
+	}
+}
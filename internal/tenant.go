@@ -0,0 +1,358 @@
+package internal
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/nakabonne/tstorage"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// tenantMetadataKey is the gRPC metadata key clients select their tenant
+	// with when the tenant is not given by their access token.
+	tenantMetadataKey = "x-tenant-id"
+
+	// defaultMaxOpenTenants is how many tenants are kept open by default.
+	defaultMaxOpenTenants = 16
+)
+
+// tenantNameRegex matches the valid tenant names. The name is used as the
+// name of the directory of the tenant inside of the data path.
+var tenantNameRegex = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// tenantPool keeps the storage of the most recently used tenants open.
+//
+// DEVELOPERS NOTE:
+// Every tenant has its own `tstorage` instance, series catalog, tombstones
+// and rollups saved inside of its own directory of the data path, so the data
+// of one tenant can never be returned to another. Without multi-tenancy there
+// is a single tenant, with an empty name, which uses the data path itself.
+type tenantPool struct {
+	mu sync.Mutex
+
+	server *TStorageServer
+
+	// Whether the tenant of every request must be resolved, otherwise the
+	// single tenant with the empty name is used.
+	multiTenant bool
+
+	// How many idle tenants may be kept open at most.
+	maxOpen int
+
+	// The open tenants by name along with the order they were used in, from
+	// the most to the least recently used.
+	tenants map[string]*tenant
+	lru     *list.List
+
+	// Whether the pool was closed, after which no tenant can be opened. The
+	// condition is signaled every time a tenant is released.
+	closed   bool
+	released *sync.Cond
+}
+
+// tenant is a tenant whose storage is open.
+type tenant struct {
+	name string
+	impl *TStorageServerImpl
+
+	// How many requests or background tasks are using the tenant. A tenant is
+	// only closed once it is no longer used.
+	refs int
+	elem *list.Element
+}
+
+// Function will create our pool of tenants.
+func newTenantPool(server *TStorageServer, multiTenant bool, maxOpen int) *tenantPool {
+	p := &tenantPool{
+		server:      server,
+		multiTenant: multiTenant,
+		maxOpen:     maxOpen,
+		tenants:     map[string]*tenant{},
+		lru:         list.New(),
+	}
+	p.released = sync.NewCond(&p.mu)
+	return p
+}
+
+// Function returns the name of the tenant the request was made for. The
+// tenant of the access token is used if it has one, otherwise the tenant
+// given in the metadata of the request.
+func (p *tenantPool) resolve(ctx context.Context) (string, error) {
+	if !p.multiTenant {
+		return "", nil
+	}
+
+	var name string
+	md, _ := metadata.FromIncomingContext(ctx)
+	if values := md.Get(tenantMetadataKey); len(values) > 0 {
+		name = values[0]
+	}
+	if t, ok := tokenFromContext(ctx); ok && t.Tenant != "" {
+		if name != "" && name != t.Tenant {
+			return "", status.Errorf(codes.PermissionDenied, "token %q may not access the %q tenant", t.Name, name)
+		}
+		name = t.Tenant
+	}
+
+	if name == "" {
+		return "", status.Errorf(codes.InvalidArgument, "tenant must be set with the %v metadata", tenantMetadataKey)
+	}
+	if err := validateTenantName(name); err != nil {
+		return "", status.Error(codes.InvalidArgument, err.Error())
+	}
+	return name, nil
+}
+
+// Function returns an error if the tenant name can not be used.
+func validateTenantName(name string) error {
+	// DEVELOPERS NOTE:
+	// We do not allow names which look like the files and directories
+	// `tstorage` or our rollups create inside of a data path.
+	if !tenantNameRegex.MatchString(name) || strings.HasPrefix(name, "p-") || name == "wal" || name == rollupsDirName {
+		return fmt.Errorf("invalid tenant %q", name)
+	}
+	return nil
+}
+
+// Function returns the implementation of our gRPC service for the tenant,
+// opening the storage of the tenant if needed. The returned function must be
+// called once the tenant is no longer used.
+//
+// DEVELOPERS NOTE:
+// Tenants are opened while holding the lock, so requests for other tenants
+// wait while a tenant is being opened. Opening a tenant is rare compared to
+// the number of requests so we prefer keeping this simple.
+func (p *tenantPool) acquire(name string) (*TStorageServerImpl, func(), error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.closed {
+		return nil, nil, status.Error(codes.Unavailable, "server is shutting down")
+	}
+	t, ok := p.tenants[name]
+	if ok {
+		p.lru.MoveToFront(t.elem)
+	} else {
+		impl, err := p.server.openTenant(name)
+		if err != nil {
+			return nil, nil, status.Errorf(codes.Unavailable, "failed to open tenant: %v", err)
+		}
+		t = &tenant{name: name, impl: impl}
+		t.elem = p.lru.PushFront(t)
+		p.tenants[name] = t
+	}
+	t.refs++
+
+	release := func() {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		t.refs--
+		p.released.Broadcast()
+		p.evict()
+	}
+	return t.impl, release, nil
+}
+
+// Function will call the function for every open tenant.
+func (p *tenantPool) each(fn func(impl *TStorageServerImpl)) {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return
+	}
+	open := make([]*tenant, 0, len(p.tenants))
+	for _, t := range p.tenants {
+		t.refs++
+		open = append(open, t)
+	}
+	p.mu.Unlock()
+
+	for _, t := range open {
+		fn(t.impl)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, t := range open {
+		t.refs--
+	}
+	p.released.Broadcast()
+	p.evict()
+}
+
+// Function will close the least recently used tenants which are not used
+// anymore until no more than `maxOpen` tenants are open. The lock must be held
+// by the caller.
+func (p *tenantPool) evict() {
+	// The data of tenants kept in memory would be lost when closed, and once
+	// the pool is closed every tenant is closed by `closeAll`.
+	if p.server.dataPath == "" || p.closed {
+		return
+	}
+	for elem := p.lru.Back(); elem != nil && len(p.tenants) > p.maxOpen; {
+		t := elem.Value.(*tenant)
+		elem = elem.Prev()
+		if t.refs > 0 {
+			continue
+		}
+		p.close(t)
+	}
+}
+
+// Function will close every tenant once it is no longer used. No tenant can
+// be acquired anymore once this function was called.
+func (p *tenantPool) closeAll() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.closed = true
+	for _, t := range p.tenants {
+		for t.refs > 0 {
+			p.released.Wait()
+		}
+		p.close(t)
+	}
+}
+
+// Function will close the storage of the tenant and save its state. The lock
+// must be held by the caller.
+func (p *tenantPool) close(t *tenant) {
+	if err := t.impl.close(); err != nil {
+		log.Printf("failed to close tenant %q: %v", t.name, err)
+	} else if t.name != "" {
+		log.Printf("Closed tenant %q", t.name)
+	}
+	p.lru.Remove(t.elem)
+	delete(p.tenants, t.name)
+}
+
+// Function will open the storage and load the state of the tenant.
+func (s *TStorageServer) openTenant(name string) (*TStorageServerImpl, error) {
+	dataPath := s.dataPath
+	if name != "" && dataPath != "" {
+		dataPath = filepath.Join(s.dataPath, name)
+	}
+
+	// Initialize our fast time-series database.
+	storage, err := tstorage.NewStorage(
+		tstorage.WithDataPath(dataPath),
+		tstorage.WithTimestampPrecision(s.timestampPrecision),
+		tstorage.WithPartitionDuration(s.partitionDuration),
+		tstorage.WithWriteTimeout(s.writeTimeout),
+		tstorage.WithRetention(s.retention),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	// Load the catalog of series we are storing, the tombstones of the
+	// deleted data and how far the series were rolled up. All are only kept
+	// in memory if no data path was provided.
+	catalog, err := newSeriesCatalog(dataFilePath(dataPath, catalogFileName))
+	if err != nil {
+		storage.Close()
+		return nil, fmt.Errorf("failed to load series catalog: %w", err)
+	}
+	tombstones, err := newTombstoneStore(dataFilePath(dataPath, tombstonesFileName))
+	if err != nil {
+		storage.Close()
+		return nil, fmt.Errorf("failed to load tombstones: %w", err)
+	}
+	rollups, err := newRollupStore(dataFilePath(dataPath, rollupsFileName))
+	if err != nil {
+		storage.Close()
+		return nil, fmt.Errorf("failed to load rollups: %w", err)
+	}
+	rollupStorages, err := s.openRollupStorages(dataPath)
+	if err != nil {
+		storage.Close()
+		return nil, fmt.Errorf("failed to open rollups: %w", err)
+	}
+
+	impl := &TStorageServerImpl{
+		storage:            storage,
+		tenant:             name,
+		dataPath:           dataPath,
+		timestampPrecision: s.timestampPrecision,
+		partitionDuration:  s.partitionDuration,
+		insertBatchSize:    s.insertBatchSize,
+		catalog:            catalog,
+		tombstones:         tombstones,
+		policies:           s.policies,
+		rollups:            rollups,
+		rollupStorages:     rollupStorages,
+		metrics:            s.metrics,
+	}
+	if err := impl.refreshCatalog(); err != nil {
+		impl.close()
+		return nil, fmt.Errorf("failed to refresh series catalog: %w", err)
+	}
+
+	if name != "" {
+		log.Printf("Opened tenant %q", name)
+	}
+	return impl, nil
+}
+
+// Function will extend the time range of every series in our catalog to the
+// newest data point in the storage.
+//
+// DEVELOPERS NOTE:
+// The time ranges of the series are only saved with the catalog, so after a
+// crash they may end before the data points written since the last save,
+// which would hide the series from queries of the most recent data.
+func (s *TStorageServerImpl) refreshCatalog() error {
+	rows := []tstorage.Row{}
+	for _, ser := range s.catalog.find("", nil, math.MinInt64, math.MaxInt64) {
+		newest := s.catalog.newest(ser.metric, ser.labels)
+		points, err := s.selectPoints(ser.metric, ser.labels, newest+1, math.MaxInt64)
+		if err != nil {
+			return err
+		}
+		if len(points) > 0 {
+			rows = append(rows, tstorage.Row{Metric: ser.metric, Labels: ser.labels, DataPoint: *points[len(points)-1]})
+		}
+	}
+	return s.catalog.add(rows)
+}
+
+// Function will close the storage of the tenant and save its state.
+func (s *TStorageServerImpl) close() error {
+	if err := s.storage.Close(); err != nil {
+		return err
+	}
+	if err := closeRollupStorages(s.rollupStorages); err != nil {
+		return err
+	}
+	if err := s.catalog.close(); err != nil {
+		return err
+	}
+	if err := s.tombstones.flush(); err != nil {
+		return err
+	}
+	return s.rollups.save()
+}
+
+// Function returns the implementation of our gRPC service for the tenant of
+// the request along with the function to call once the request is done.
+//
+// DEVELOPERS NOTE:
+// Every handler starts by replacing its receiver with the implementation of
+// the tenant, so the rest of the handler is unaware of multi-tenancy.
+func (s *TStorageServerImpl) forTenant(ctx context.Context) (*TStorageServerImpl, func(), error) {
+	name, err := s.tenants.resolve(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	return s.tenants.acquire(name)
+}
@@ -0,0 +1,167 @@
+package internal
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/nakabonne/tstorage"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Function returns a pool keeping at most `maxOpen` idle tenants open whose
+// data is saved inside of a temporary directory.
+func newTestTenantPool(t *testing.T, maxOpen int) *tenantPool {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "tenants")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	server := &TStorageServer{
+		dataPath:           dir,
+		timestampPrecision: tstorage.Seconds,
+		partitionDuration:  time.Hour,
+		writeTimeout:       time.Second,
+		retention:          defaultRetention,
+		metrics:            newServerMetrics(),
+	}
+	return newTenantPool(server, true, maxOpen)
+}
+
+// Function returns the names of the open tenants from the most to the least
+// recently used.
+func openTenants(p *tenantPool) []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	names := []string{}
+	for elem := p.lru.Front(); elem != nil; elem = elem.Next() {
+		names = append(names, elem.Value.(*tenant).name)
+	}
+	return names
+}
+
+func assertOpenTenants(t *testing.T, p *tenantPool, want ...string) {
+	t.Helper()
+	got := openTenants(p)
+	if len(got) != len(want) {
+		t.Fatalf("open tenants = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("open tenants = %v, want %v", got, want)
+		}
+	}
+}
+
+func TestTenantPoolEvictsLeastRecentlyUsed(t *testing.T) {
+	p := newTestTenantPool(t, 2)
+	defer p.closeAll()
+
+	for _, name := range []string{"a", "b", "a", "c"} {
+		_, release, err := p.acquire(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		release()
+	}
+
+	// The tenant `b` was used least recently once `c` was opened.
+	assertOpenTenants(t, p, "c", "a")
+}
+
+func TestTenantPoolKeepsReferencedTenants(t *testing.T) {
+	p := newTestTenantPool(t, 1)
+	defer p.closeAll()
+
+	impl, releaseA, err := p.acquire("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, releaseB, err := p.acquire("b")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Both tenants are still used so neither can be closed.
+	assertOpenTenants(t, p, "b", "a")
+
+	// The tenant `a` is the least recently used but is still referenced, so
+	// the idle `b` is closed instead.
+	releaseB()
+	assertOpenTenants(t, p, "a")
+	row := tstorage.Row{Metric: "cpu", DataPoint: tstorage.DataPoint{Timestamp: 1, Value: 1}}
+	if err := impl.insertRows([]tstorage.Row{row}); err != nil {
+		t.Fatalf("tenant was closed while referenced: %v", err)
+	}
+
+	// Opening another tenant evicts `a` once it is released.
+	_, releaseC, err := p.acquire("c")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertOpenTenants(t, p, "c", "a")
+	releaseA()
+	assertOpenTenants(t, p, "c")
+	releaseC()
+	assertOpenTenants(t, p, "c")
+}
+
+func TestTenantPoolCloseAll(t *testing.T) {
+	p := newTestTenantPool(t, 2)
+	_, release, err := p.acquire("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Closing waits for the tenant to be released.
+	closed := make(chan struct{})
+	go func() {
+		p.closeAll()
+		close(closed)
+	}()
+	select {
+	case <-closed:
+		t.Fatal("closeAll() returned while a tenant was still used")
+	case <-time.After(50 * time.Millisecond):
+	}
+	release()
+	select {
+	case <-closed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("closeAll() did not return once the tenant was released")
+	}
+	assertOpenTenants(t, p)
+
+	// No tenant can be opened again once the pool is closed.
+	if _, _, err := p.acquire("a"); status.Code(err) != codes.Unavailable {
+		t.Fatalf("acquire() after closeAll() returned %v, want %v", err, codes.Unavailable)
+	}
+	assertOpenTenants(t, p)
+}
+
+func TestValidateTenantName(t *testing.T) {
+	tests := []struct {
+		name    string
+		wantErr bool
+	}{
+		{"site_a", false},
+		{"Site-01", false},
+		{"", true},
+		{"../etc", true},
+		{"a/b", true},
+		{"p-1600000000-1600003600", true},
+		{"wal", true},
+		{rollupsDirName, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := validateTenantName(tt.name); (err != nil) != tt.wantErr {
+				t.Errorf("got error %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,131 @@
+package internal
+
+import (
+	"math"
+	"time"
+
+	tspb "github.com/golang/protobuf/ptypes/timestamp"
+	"github.com/nakabonne/tstorage"
+)
+
+// Function will convert the protocol buffer timestamp into the unix timestamp
+// format expected by `tstorage` for the given precision. A missing timestamp
+// is returned as zero.
+func toUnixTimestamp(ts *tspb.Timestamp, precision tstorage.TimestampPrecision) int64 {
+	if ts == nil {
+		return 0
+	}
+	switch precision {
+	case tstorage.Nanoseconds:
+		return ts.Seconds*int64(time.Second) + int64(ts.Nanos)
+	case tstorage.Microseconds:
+		return ts.Seconds*int64(time.Second/time.Microsecond) + int64(ts.Nanos)/int64(time.Microsecond)
+	case tstorage.Milliseconds:
+		return ts.Seconds*int64(time.Second/time.Millisecond) + int64(ts.Nanos)/int64(time.Millisecond)
+	default:
+		return ts.Seconds
+	}
+}
+
+// Function will convert the optional protocol buffer time range into unix
+// timestamps. A missing start or end leaves that side of the range unbounded.
+func toUnixTimeRange(start *tspb.Timestamp, end *tspb.Timestamp, precision tstorage.TimestampPrecision) (int64, int64) {
+	from, to := int64(math.MinInt64), int64(math.MaxInt64)
+	if start != nil {
+		from = toUnixTimestamp(start, precision)
+	}
+	if end != nil {
+		to = toUnixTimestamp(end, precision)
+	}
+	return from, to
+}
+
+// Function will convert the unix timestamp returned by `tstorage` for the
+// given precision back into the protocol buffer timestamp format.
+func fromUnixTimestamp(v int64, precision tstorage.TimestampPrecision) *tspb.Timestamp {
+	unit := precisionUnit(precision)
+	perSecond := int64(time.Second / unit)
+
+	// DEVELOPERS NOTE:
+	// We use floored division so negative timestamps (before 1970) still
+	// produce a positive `Nanos` value as required by the protocol buffer spec.
+	seconds := v / perSecond
+	remainder := v % perSecond
+	if remainder < 0 {
+		seconds--
+		remainder += perSecond
+	}
+	return &tspb.Timestamp{
+		Seconds: seconds,
+		Nanos:   int32(remainder * int64(unit)),
+	}
+}
+
+// Function returns the duration of a single tick for the given precision.
+func precisionUnit(precision tstorage.TimestampPrecision) time.Duration {
+	switch precision {
+	case tstorage.Nanoseconds:
+		return time.Nanosecond
+	case tstorage.Microseconds:
+		return time.Microsecond
+	case tstorage.Milliseconds:
+		return time.Millisecond
+	default:
+		return time.Second
+	}
+}
+
+// Function will convert the time into a unix timestamp for the given
+// precision. Like `millisToUnix` the times before 1970 are rounded down.
+func timeToUnix(t time.Time, precision tstorage.TimestampPrecision) int64 {
+	return floorDiv(t.UnixNano(), int64(precisionUnit(precision)))
+}
+
+// Function will convert the unix timestamp in milliseconds, as used by
+// Prometheus, into a unix timestamp for the given precision.
+func millisToUnix(ms int64, precision tstorage.TimestampPrecision) int64 {
+	unit := precisionUnit(precision)
+	if unit >= time.Millisecond {
+		return floorDiv(ms, int64(unit/time.Millisecond))
+	}
+	return ms * int64(time.Millisecond/unit)
+}
+
+// Function is like `millisToUnix` but returns false if the timestamp does not
+// fit into an int64 once converted to the given precision.
+func millisToUnixChecked(ms int64, precision tstorage.TimestampPrecision) (int64, bool) {
+	unit := precisionUnit(precision)
+	if unit >= time.Millisecond {
+		return floorDiv(ms, int64(unit/time.Millisecond)), true
+	}
+	return multiplyTimestamp(ms, int64(time.Millisecond/unit))
+}
+
+// Function returns the timestamp multiplied by the positive factor and false
+// if the result does not fit into an int64.
+func multiplyTimestamp(v int64, factor int64) (int64, bool) {
+	if v > math.MaxInt64/factor || v < math.MinInt64/factor {
+		return 0, false
+	}
+	return v * factor, true
+}
+
+// Function will convert the unix timestamp of the given precision into a unix
+// timestamp in milliseconds, as used by Prometheus.
+func unixToMillis(v int64, precision tstorage.TimestampPrecision) int64 {
+	unit := precisionUnit(precision)
+	if unit >= time.Millisecond {
+		return v * int64(unit/time.Millisecond)
+	}
+	return floorDiv(v, int64(time.Millisecond/unit))
+}
+
+// Function returns the quotient rounded down, so timestamps before 1970 are
+// rounded the same way as the ones after it.
+func floorDiv(a int64, b int64) int64 {
+	q := a / b
+	if a%b != 0 && (a < 0) != (b < 0) {
+		q--
+	}
+	return q
+}
@@ -0,0 +1,121 @@
+package internal
+
+import (
+	"testing"
+	"time"
+
+	tspb "github.com/golang/protobuf/ptypes/timestamp"
+	"github.com/nakabonne/tstorage"
+)
+
+func TestTimestampRoundTrip(t *testing.T) {
+	tests := []struct {
+		name      string
+		precision tstorage.TimestampPrecision
+		ts        *tspb.Timestamp
+		unix      int64
+		back      *tspb.Timestamp
+	}{
+		{"ns", tstorage.Nanoseconds, &tspb.Timestamp{Seconds: 1625140800, Nanos: 123456789}, 1625140800123456789, &tspb.Timestamp{Seconds: 1625140800, Nanos: 123456789}},
+		{"us", tstorage.Microseconds, &tspb.Timestamp{Seconds: 1625140800, Nanos: 123456789}, 1625140800123456, &tspb.Timestamp{Seconds: 1625140800, Nanos: 123456000}},
+		{"ms", tstorage.Milliseconds, &tspb.Timestamp{Seconds: 1625140800, Nanos: 123456789}, 1625140800123, &tspb.Timestamp{Seconds: 1625140800, Nanos: 123000000}},
+		{"s", tstorage.Seconds, &tspb.Timestamp{Seconds: 1625140800, Nanos: 123456789}, 1625140800, &tspb.Timestamp{Seconds: 1625140800}},
+		{"ns sub-second", tstorage.Nanoseconds, &tspb.Timestamp{Nanos: 1}, 1, &tspb.Timestamp{Nanos: 1}},
+		{"us sub-second", tstorage.Microseconds, &tspb.Timestamp{Nanos: 999999}, 999, &tspb.Timestamp{Nanos: 999000}},
+		{"ms sub-second", tstorage.Milliseconds, &tspb.Timestamp{Nanos: 500000000}, 500, &tspb.Timestamp{Nanos: 500000000}},
+		{"s sub-second", tstorage.Seconds, &tspb.Timestamp{Nanos: 999999999}, 0, &tspb.Timestamp{}},
+		// -1.5s is represented as -2s plus 0.5s.
+		{"ns negative", tstorage.Nanoseconds, &tspb.Timestamp{Seconds: -2, Nanos: 500000000}, -1500000000, &tspb.Timestamp{Seconds: -2, Nanos: 500000000}},
+		{"us negative", tstorage.Microseconds, &tspb.Timestamp{Seconds: -2, Nanos: 500000000}, -1500000, &tspb.Timestamp{Seconds: -2, Nanos: 500000000}},
+		{"ms negative", tstorage.Milliseconds, &tspb.Timestamp{Seconds: -2, Nanos: 500000000}, -1500, &tspb.Timestamp{Seconds: -2, Nanos: 500000000}},
+		{"s negative", tstorage.Seconds, &tspb.Timestamp{Seconds: -2, Nanos: 500000000}, -2, &tspb.Timestamp{Seconds: -2}},
+		{"missing", tstorage.Milliseconds, nil, 0, &tspb.Timestamp{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			unix := toUnixTimestamp(tt.ts, tt.precision)
+			if unix != tt.unix {
+				t.Fatalf("toUnixTimestamp() = %v, want %v", unix, tt.unix)
+			}
+			back := fromUnixTimestamp(unix, tt.precision)
+			if back.Seconds != tt.back.Seconds || back.Nanos != tt.back.Nanos {
+				t.Fatalf("fromUnixTimestamp() = %v, want %v", back, tt.back)
+			}
+		})
+	}
+}
+
+func TestPrecisionUnit(t *testing.T) {
+	tests := []struct {
+		precision tstorage.TimestampPrecision
+		want      time.Duration
+	}{
+		{tstorage.Nanoseconds, time.Nanosecond},
+		{tstorage.Microseconds, time.Microsecond},
+		{tstorage.Milliseconds, time.Millisecond},
+		{tstorage.Seconds, time.Second},
+	}
+	for _, tt := range tests {
+		if got := precisionUnit(tt.precision); got != tt.want {
+			t.Errorf("precisionUnit(%v) = %v, want %v", tt.precision, got, tt.want)
+		}
+	}
+}
+
+func TestMillisRoundTrip(t *testing.T) {
+	tests := []struct {
+		precision tstorage.TimestampPrecision
+		ms        int64
+		unix      int64
+		back      int64
+	}{
+		{tstorage.Nanoseconds, 1625140800123, 1625140800123000000, 1625140800123},
+		{tstorage.Microseconds, 1625140800123, 1625140800123000, 1625140800123},
+		{tstorage.Milliseconds, 1625140800123, 1625140800123, 1625140800123},
+		{tstorage.Seconds, 1625140800123, 1625140800, 1625140800000},
+		{tstorage.Nanoseconds, -1500, -1500000000, -1500},
+		{tstorage.Milliseconds, -1500, -1500, -1500},
+		// Sub-second timestamps before 1970 are rounded down like positive ones.
+		{tstorage.Seconds, -1500, -2, -2000},
+	}
+	for _, tt := range tests {
+		unix := millisToUnix(tt.ms, tt.precision)
+		if unix != tt.unix {
+			t.Errorf("millisToUnix(%v, %v) = %v, want %v", tt.ms, tt.precision, unix, tt.unix)
+		}
+		if back := unixToMillis(unix, tt.precision); back != tt.back {
+			t.Errorf("unixToMillis(%v, %v) = %v, want %v", unix, tt.precision, back, tt.back)
+		}
+	}
+}
+
+func TestTimeToUnix(t *testing.T) {
+	tests := []struct {
+		ts        time.Time
+		precision tstorage.TimestampPrecision
+		want      int64
+	}{
+		{time.Unix(1625140800, 123456789), tstorage.Nanoseconds, 1625140800123456789},
+		{time.Unix(1625140800, 123456789), tstorage.Microseconds, 1625140800123456},
+		{time.Unix(1625140800, 123456789), tstorage.Milliseconds, 1625140800123},
+		{time.Unix(1625140800, 123456789), tstorage.Seconds, 1625140800},
+		{time.Unix(-2, 500000000), tstorage.Nanoseconds, -1500000000},
+		{time.Unix(-2, 500000000), tstorage.Microseconds, -1500000},
+		{time.Unix(-2, 500000000), tstorage.Milliseconds, -1500},
+		{time.Unix(-2, 500000000), tstorage.Seconds, -2},
+		{time.Unix(0, -1), tstorage.Milliseconds, -1},
+		{time.Unix(0, -1), tstorage.Seconds, -1},
+	}
+	for _, tt := range tests {
+		if got := timeToUnix(tt.ts, tt.precision); got != tt.want {
+			t.Errorf("timeToUnix(%v, %v) = %v, want %v", tt.ts.UnixNano(), tt.precision, got, tt.want)
+		}
+		// The ingest paths receiving milliseconds must agree on the timestamp.
+		if tt.precision == tstorage.Milliseconds || tt.precision == tstorage.Seconds {
+			ms := floorDiv(tt.ts.UnixNano(), int64(time.Millisecond))
+			if got := millisToUnix(ms, tt.precision); got != tt.want {
+				t.Errorf("millisToUnix(%v, %v) = %v, want %v", ms, tt.precision, got, tt.want)
+			}
+		}
+	}
+}
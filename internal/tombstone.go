@@ -0,0 +1,255 @@
+package internal
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"sync"
+
+	"github.com/nakabonne/tstorage"
+)
+
+// tombstonesFileName is the name of the file, saved inside the data path,
+// which holds the tombstones between restarts.
+const tombstonesFileName = "tombstones.json"
+
+// tombstoneStore keeps track of the deleted time ranges of every series and
+// of the raw data points older than the retention of their policy.
+//
+// DEVELOPERS NOTE:
+// The `tstorage` package does not support deleting data points, so instead
+// we record a tombstone for every deleted range and hide the data points
+// covered by a tombstone from every query. The data points are physically
+// removed from the disk once their partition expires, at which point the
+// tombstone is no longer needed and is purged by the janitor.
+type tombstoneStore struct {
+	mu sync.RWMutex
+
+	// The location of the file the tombstones are persisted to. If empty then
+	// the tombstones are only kept in memory.
+	path string
+
+	// The tombstones grouped by the unique key produced by `seriesID`.
+	tombstones map[string][]*tombstone
+
+	// The tombstone of every series, by the same key, hiding its raw data
+	// points older than the retention of its policy. These are kept apart
+	// since, unlike deleted data points, the rollups of the hidden data
+	// points must still be returned.
+	retained map[string]*tombstone
+
+	// Whether there are changes which were not saved to disk yet.
+	dirty bool
+}
+
+// tombstone is a deleted time range of a single series where `Start` is
+// inclusive and `End` is exclusive.
+type tombstone struct {
+	Metric    string           `json:"metric"`
+	Labels    []tstorage.Label `json:"labels"`
+	Start     int64            `json:"start"`
+	End       int64            `json:"end"`
+	Retention bool             `json:"retention,omitempty"`
+}
+
+// Function will create our tombstone store and load the previously saved
+// tombstones from the file path, if there are any.
+func newTombstoneStore(path string) (*tombstoneStore, error) {
+	t := &tombstoneStore{
+		path:       path,
+		tombstones: map[string][]*tombstone{},
+		retained:   map[string]*tombstone{},
+	}
+	if path == "" {
+		return t, nil
+	}
+
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return t, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read tombstones: %w", err)
+	}
+	saved := []*tombstone{}
+	if err := json.Unmarshal(b, &saved); err != nil {
+		return nil, fmt.Errorf("failed to decode tombstones: %w", err)
+	}
+	for _, ts := range saved {
+		key := seriesID(ts.Metric, ts.Labels)
+		if ts.Retention {
+			t.retained[key] = ts
+		} else {
+			t.tombstones[key] = append(t.tombstones[key], ts)
+		}
+	}
+	return t, nil
+}
+
+// Function will record the tombstones of the deleted time ranges and save
+// them to disk right away.
+func (t *tombstoneStore) add(tombstones []*tombstone) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for _, ts := range tombstones {
+		ts.Labels = sortedLabels(ts.Labels)
+		key := seriesID(ts.Metric, ts.Labels)
+		t.tombstones[key] = append(t.tombstones[key], ts)
+	}
+	return t.save()
+}
+
+// Function will make sure every raw data point of the series older than the
+// given timestamp is hidden. A single tombstone per series is used for this
+// purpose which gets extended on every call. The tombstones are not saved to
+// disk, the caller must call `flush` afterwards.
+func (t *tombstoneStore) retain(ser *series, before int64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	labels := sortedLabels(ser.labels)
+	key := seriesID(ser.metric, labels)
+	if ts, ok := t.retained[key]; ok {
+		if ts.End < before {
+			ts.End = before
+			t.dirty = true
+		}
+		return
+	}
+	t.retained[key] = &tombstone{
+		Metric:    ser.metric,
+		Labels:    labels,
+		Start:     math.MinInt64,
+		End:       before,
+		Retention: true,
+	}
+	t.dirty = true
+}
+
+// Function will save the tombstones to disk if they were changed by `retain`.
+func (t *tombstoneStore) flush() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if !t.dirty {
+		return nil
+	}
+	return t.save()
+}
+
+// Function returns the raw data points of the series which are neither
+// deleted nor older than the retention of its policy.
+func (t *tombstoneStore) filter(metric string, labels []tstorage.Label, points []*tstorage.DataPoint) []*tstorage.DataPoint {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	key := seriesID(metric, sortedLabels(labels))
+	tombstones := t.tombstones[key]
+	if ts, ok := t.retained[key]; ok {
+		tombstones = append([]*tombstone{ts}, tombstones...)
+	}
+	return hidePoints(points, tombstones)
+}
+
+// Function returns the data points of the series which were not deleted,
+// ignoring the retention of its policy. Used for the rollups of the series
+// since they are kept longer than its raw data points.
+func (t *tombstoneStore) filterDeleted(metric string, labels []tstorage.Label, points []*tstorage.DataPoint) []*tstorage.DataPoint {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	return hidePoints(points, t.tombstones[seriesID(metric, sortedLabels(labels))])
+}
+
+// Function returns the data points which are not covered by any of the
+// tombstones.
+func hidePoints(points []*tstorage.DataPoint, tombstones []*tombstone) []*tstorage.DataPoint {
+	if len(tombstones) == 0 {
+		return points
+	}
+
+	out := make([]*tstorage.DataPoint, 0, len(points))
+	for _, point := range points {
+		deleted := false
+		for _, ts := range tombstones {
+			if point.Timestamp >= ts.Start && point.Timestamp < ts.End {
+				deleted = true
+				break
+			}
+		}
+		if !deleted {
+			out = append(out, point)
+		}
+	}
+	return out
+}
+
+// Function will remove every tombstone which ends before the oldest data
+// point still stored, since the data points it was hiding have already been
+// removed from the disk.
+func (t *tombstoneStore) purge(oldest int64) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	purged := 0
+	for key, tombstones := range t.tombstones {
+		kept := tombstones[:0]
+		for _, ts := range tombstones {
+			if ts.End <= oldest {
+				purged++
+				continue
+			}
+			kept = append(kept, ts)
+		}
+		if len(kept) == 0 {
+			delete(t.tombstones, key)
+		} else {
+			t.tombstones[key] = kept
+		}
+	}
+	for key, ts := range t.retained {
+		if ts.End <= oldest {
+			delete(t.retained, key)
+			purged++
+		}
+	}
+	if purged == 0 {
+		return 0, nil
+	}
+	return purged, t.save()
+}
+
+// Function will save the tombstones to disk. The lock must be held by the
+// caller.
+func (t *tombstoneStore) save() error {
+	if t.path == "" {
+		return nil
+	}
+
+	saved := []*tombstone{}
+	for _, tombstones := range t.tombstones {
+		saved = append(saved, tombstones...)
+	}
+	for _, ts := range t.retained {
+		saved = append(saved, ts)
+	}
+	b, err := json.Marshal(saved)
+	if err != nil {
+		return fmt.Errorf("failed to encode tombstones: %w", err)
+	}
+
+	// Write to a temporary file first so a crash will never leave us with a
+	// partially written file.
+	tmp := t.path + ".tmp"
+	if err := ioutil.WriteFile(tmp, b, 0644); err != nil {
+		return fmt.Errorf("failed to write tombstones: %w", err)
+	}
+	if err := os.Rename(tmp, t.path); err != nil {
+		return fmt.Errorf("failed to write tombstones: %w", err)
+	}
+	t.dirty = false
+	return nil
+}
@@ -0,0 +1,25 @@
+package tstorage_server
+
+// The Aggregation values before they were prefixed with the enum name, kept
+// so existing Go clients still compile. Only the names are kept, the values
+// moved up by one to reserve zero for AGGREGATION_UNSPECIFIED.
+const (
+	// Deprecated: use Aggregation_AGGREGATION_AVG instead.
+	Aggregation_AVG = Aggregation_AGGREGATION_AVG
+	// Deprecated: use Aggregation_AGGREGATION_MIN instead.
+	Aggregation_MIN = Aggregation_AGGREGATION_MIN
+	// Deprecated: use Aggregation_AGGREGATION_MAX instead.
+	Aggregation_MAX = Aggregation_AGGREGATION_MAX
+	// Deprecated: use Aggregation_AGGREGATION_SUM instead.
+	Aggregation_SUM = Aggregation_AGGREGATION_SUM
+	// Deprecated: use Aggregation_AGGREGATION_COUNT instead.
+	Aggregation_COUNT = Aggregation_AGGREGATION_COUNT
+	// Deprecated: use Aggregation_AGGREGATION_STDDEV instead.
+	Aggregation_STDDEV = Aggregation_AGGREGATION_STDDEV
+	// Deprecated: use Aggregation_AGGREGATION_P50 instead.
+	Aggregation_P50 = Aggregation_AGGREGATION_P50
+	// Deprecated: use Aggregation_AGGREGATION_P90 instead.
+	Aggregation_P90 = Aggregation_AGGREGATION_P90
+	// Deprecated: use Aggregation_AGGREGATION_P99 instead.
+	Aggregation_P99 = Aggregation_AGGREGATION_P99
+)
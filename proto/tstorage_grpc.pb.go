@@ -21,7 +21,15 @@ const _ = grpc.SupportPackageIsVersion7
 type TStorageClient interface {
 	InsertRow(ctx context.Context, in *TimeSeriesDatum, opts ...grpc.CallOption) (*empty.Empty, error)
 	InsertRows(ctx context.Context, opts ...grpc.CallOption) (TStorage_InsertRowsClient, error)
+	StreamInsert(ctx context.Context, opts ...grpc.CallOption) (TStorage_StreamInsertClient, error)
 	Select(ctx context.Context, in *Filter, opts ...grpc.CallOption) (TStorage_SelectClient, error)
+	SelectSeries(ctx context.Context, in *Filter, opts ...grpc.CallOption) (TStorage_SelectSeriesClient, error)
+	ListMetrics(ctx context.Context, in *MetadataFilter, opts ...grpc.CallOption) (*ListMetricsResponse, error)
+	ListLabelNames(ctx context.Context, in *MetadataFilter, opts ...grpc.CallOption) (*ListLabelNamesResponse, error)
+	ListLabelValues(ctx context.Context, in *ListLabelValuesRequest, opts ...grpc.CallOption) (*ListLabelValuesResponse, error)
+	Aggregate(ctx context.Context, in *AggregateRequest, opts ...grpc.CallOption) (*AggregateResponse, error)
+	RangeQuery(ctx context.Context, in *RangeQueryRequest, opts ...grpc.CallOption) (TStorage_RangeQueryClient, error)
+	Delete(ctx context.Context, in *Filter, opts ...grpc.CallOption) (*DeleteResponse, error)
 }
 
 type tStorageClient struct {
@@ -52,7 +60,7 @@ func (c *tStorageClient) InsertRows(ctx context.Context, opts ...grpc.CallOption
 
 type TStorage_InsertRowsClient interface {
 	Send(*TimeSeriesDatum) error
-	CloseAndRecv() (*empty.Empty, error)
+	CloseAndRecv() (*InsertRowsResponse, error)
 	grpc.ClientStream
 }
 
@@ -64,11 +72,42 @@ func (x *tStorageInsertRowsClient) Send(m *TimeSeriesDatum) error {
 	return x.ClientStream.SendMsg(m)
 }
 
-func (x *tStorageInsertRowsClient) CloseAndRecv() (*empty.Empty, error) {
+func (x *tStorageInsertRowsClient) CloseAndRecv() (*InsertRowsResponse, error) {
 	if err := x.ClientStream.CloseSend(); err != nil {
 		return nil, err
 	}
-	m := new(empty.Empty)
+	m := new(InsertRowsResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *tStorageClient) StreamInsert(ctx context.Context, opts ...grpc.CallOption) (TStorage_StreamInsertClient, error) {
+	stream, err := c.cc.NewStream(ctx, &TStorage_ServiceDesc.Streams[1], "/proto.TStorage/StreamInsert", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &tStorageStreamInsertClient{stream}
+	return x, nil
+}
+
+type TStorage_StreamInsertClient interface {
+	Send(*InsertBatch) error
+	Recv() (*InsertAck, error)
+	grpc.ClientStream
+}
+
+type tStorageStreamInsertClient struct {
+	grpc.ClientStream
+}
+
+func (x *tStorageStreamInsertClient) Send(m *InsertBatch) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *tStorageStreamInsertClient) Recv() (*InsertAck, error) {
+	m := new(InsertAck)
 	if err := x.ClientStream.RecvMsg(m); err != nil {
 		return nil, err
 	}
@@ -76,7 +115,7 @@ func (x *tStorageInsertRowsClient) CloseAndRecv() (*empty.Empty, error) {
 }
 
 func (c *tStorageClient) Select(ctx context.Context, in *Filter, opts ...grpc.CallOption) (TStorage_SelectClient, error) {
-	stream, err := c.cc.NewStream(ctx, &TStorage_ServiceDesc.Streams[1], "/proto.TStorage/Select", opts...)
+	stream, err := c.cc.NewStream(ctx, &TStorage_ServiceDesc.Streams[2], "/proto.TStorage/Select", opts...)
 	if err != nil {
 		return nil, err
 	}
@@ -107,13 +146,130 @@ func (x *tStorageSelectClient) Recv() (*DataPoint, error) {
 	return m, nil
 }
 
+func (c *tStorageClient) SelectSeries(ctx context.Context, in *Filter, opts ...grpc.CallOption) (TStorage_SelectSeriesClient, error) {
+	stream, err := c.cc.NewStream(ctx, &TStorage_ServiceDesc.Streams[3], "/proto.TStorage/SelectSeries", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &tStorageSelectSeriesClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type TStorage_SelectSeriesClient interface {
+	Recv() (*Series, error)
+	grpc.ClientStream
+}
+
+type tStorageSelectSeriesClient struct {
+	grpc.ClientStream
+}
+
+func (x *tStorageSelectSeriesClient) Recv() (*Series, error) {
+	m := new(Series)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *tStorageClient) ListMetrics(ctx context.Context, in *MetadataFilter, opts ...grpc.CallOption) (*ListMetricsResponse, error) {
+	out := new(ListMetricsResponse)
+	err := c.cc.Invoke(ctx, "/proto.TStorage/ListMetrics", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *tStorageClient) ListLabelNames(ctx context.Context, in *MetadataFilter, opts ...grpc.CallOption) (*ListLabelNamesResponse, error) {
+	out := new(ListLabelNamesResponse)
+	err := c.cc.Invoke(ctx, "/proto.TStorage/ListLabelNames", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *tStorageClient) ListLabelValues(ctx context.Context, in *ListLabelValuesRequest, opts ...grpc.CallOption) (*ListLabelValuesResponse, error) {
+	out := new(ListLabelValuesResponse)
+	err := c.cc.Invoke(ctx, "/proto.TStorage/ListLabelValues", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *tStorageClient) Aggregate(ctx context.Context, in *AggregateRequest, opts ...grpc.CallOption) (*AggregateResponse, error) {
+	out := new(AggregateResponse)
+	err := c.cc.Invoke(ctx, "/proto.TStorage/Aggregate", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *tStorageClient) RangeQuery(ctx context.Context, in *RangeQueryRequest, opts ...grpc.CallOption) (TStorage_RangeQueryClient, error) {
+	stream, err := c.cc.NewStream(ctx, &TStorage_ServiceDesc.Streams[4], "/proto.TStorage/RangeQuery", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &tStorageRangeQueryClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type TStorage_RangeQueryClient interface {
+	Recv() (*Series, error)
+	grpc.ClientStream
+}
+
+type tStorageRangeQueryClient struct {
+	grpc.ClientStream
+}
+
+func (x *tStorageRangeQueryClient) Recv() (*Series, error) {
+	m := new(Series)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *tStorageClient) Delete(ctx context.Context, in *Filter, opts ...grpc.CallOption) (*DeleteResponse, error) {
+	out := new(DeleteResponse)
+	err := c.cc.Invoke(ctx, "/proto.TStorage/Delete", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // TStorageServer is the server API for TStorage service.
 // All implementations must embed UnimplementedTStorageServer
 // for forward compatibility
 type TStorageServer interface {
 	InsertRow(context.Context, *TimeSeriesDatum) (*empty.Empty, error)
 	InsertRows(TStorage_InsertRowsServer) error
+	StreamInsert(TStorage_StreamInsertServer) error
 	Select(*Filter, TStorage_SelectServer) error
+	SelectSeries(*Filter, TStorage_SelectSeriesServer) error
+	ListMetrics(context.Context, *MetadataFilter) (*ListMetricsResponse, error)
+	ListLabelNames(context.Context, *MetadataFilter) (*ListLabelNamesResponse, error)
+	ListLabelValues(context.Context, *ListLabelValuesRequest) (*ListLabelValuesResponse, error)
+	Aggregate(context.Context, *AggregateRequest) (*AggregateResponse, error)
+	RangeQuery(*RangeQueryRequest, TStorage_RangeQueryServer) error
+	Delete(context.Context, *Filter) (*DeleteResponse, error)
 	mustEmbedUnimplementedTStorageServer()
 }
 
@@ -127,9 +283,33 @@ func (UnimplementedTStorageServer) InsertRow(context.Context, *TimeSeriesDatum)
 func (UnimplementedTStorageServer) InsertRows(TStorage_InsertRowsServer) error {
 	return status.Errorf(codes.Unimplemented, "method InsertRows not implemented")
 }
+func (UnimplementedTStorageServer) StreamInsert(TStorage_StreamInsertServer) error {
+	return status.Errorf(codes.Unimplemented, "method StreamInsert not implemented")
+}
 func (UnimplementedTStorageServer) Select(*Filter, TStorage_SelectServer) error {
 	return status.Errorf(codes.Unimplemented, "method Select not implemented")
 }
+func (UnimplementedTStorageServer) SelectSeries(*Filter, TStorage_SelectSeriesServer) error {
+	return status.Errorf(codes.Unimplemented, "method SelectSeries not implemented")
+}
+func (UnimplementedTStorageServer) ListMetrics(context.Context, *MetadataFilter) (*ListMetricsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListMetrics not implemented")
+}
+func (UnimplementedTStorageServer) ListLabelNames(context.Context, *MetadataFilter) (*ListLabelNamesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListLabelNames not implemented")
+}
+func (UnimplementedTStorageServer) ListLabelValues(context.Context, *ListLabelValuesRequest) (*ListLabelValuesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListLabelValues not implemented")
+}
+func (UnimplementedTStorageServer) Aggregate(context.Context, *AggregateRequest) (*AggregateResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Aggregate not implemented")
+}
+func (UnimplementedTStorageServer) RangeQuery(*RangeQueryRequest, TStorage_RangeQueryServer) error {
+	return status.Errorf(codes.Unimplemented, "method RangeQuery not implemented")
+}
+func (UnimplementedTStorageServer) Delete(context.Context, *Filter) (*DeleteResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Delete not implemented")
+}
 func (UnimplementedTStorageServer) mustEmbedUnimplementedTStorageServer() {}
 
 // UnsafeTStorageServer may be embedded to opt out of forward compatibility for this service.
@@ -166,7 +346,7 @@ func _TStorage_InsertRows_Handler(srv interface{}, stream grpc.ServerStream) err
 }
 
 type TStorage_InsertRowsServer interface {
-	SendAndClose(*empty.Empty) error
+	SendAndClose(*InsertRowsResponse) error
 	Recv() (*TimeSeriesDatum, error)
 	grpc.ServerStream
 }
@@ -175,7 +355,7 @@ type tStorageInsertRowsServer struct {
 	grpc.ServerStream
 }
 
-func (x *tStorageInsertRowsServer) SendAndClose(m *empty.Empty) error {
+func (x *tStorageInsertRowsServer) SendAndClose(m *InsertRowsResponse) error {
 	return x.ServerStream.SendMsg(m)
 }
 
@@ -187,6 +367,32 @@ func (x *tStorageInsertRowsServer) Recv() (*TimeSeriesDatum, error) {
 	return m, nil
 }
 
+func _TStorage_StreamInsert_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(TStorageServer).StreamInsert(&tStorageStreamInsertServer{stream})
+}
+
+type TStorage_StreamInsertServer interface {
+	Send(*InsertAck) error
+	Recv() (*InsertBatch, error)
+	grpc.ServerStream
+}
+
+type tStorageStreamInsertServer struct {
+	grpc.ServerStream
+}
+
+func (x *tStorageStreamInsertServer) Send(m *InsertAck) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *tStorageStreamInsertServer) Recv() (*InsertBatch, error) {
+	m := new(InsertBatch)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
 func _TStorage_Select_Handler(srv interface{}, stream grpc.ServerStream) error {
 	m := new(Filter)
 	if err := stream.RecvMsg(m); err != nil {
@@ -208,6 +414,138 @@ func (x *tStorageSelectServer) Send(m *DataPoint) error {
 	return x.ServerStream.SendMsg(m)
 }
 
+func _TStorage_SelectSeries_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(Filter)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(TStorageServer).SelectSeries(m, &tStorageSelectSeriesServer{stream})
+}
+
+type TStorage_SelectSeriesServer interface {
+	Send(*Series) error
+	grpc.ServerStream
+}
+
+type tStorageSelectSeriesServer struct {
+	grpc.ServerStream
+}
+
+func (x *tStorageSelectSeriesServer) Send(m *Series) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _TStorage_ListMetrics_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(MetadataFilter)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TStorageServer).ListMetrics(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/proto.TStorage/ListMetrics",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TStorageServer).ListMetrics(ctx, req.(*MetadataFilter))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TStorage_ListLabelNames_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(MetadataFilter)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TStorageServer).ListLabelNames(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/proto.TStorage/ListLabelNames",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TStorageServer).ListLabelNames(ctx, req.(*MetadataFilter))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TStorage_ListLabelValues_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListLabelValuesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TStorageServer).ListLabelValues(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/proto.TStorage/ListLabelValues",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TStorageServer).ListLabelValues(ctx, req.(*ListLabelValuesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TStorage_Aggregate_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AggregateRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TStorageServer).Aggregate(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/proto.TStorage/Aggregate",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TStorageServer).Aggregate(ctx, req.(*AggregateRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TStorage_RangeQuery_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(RangeQueryRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(TStorageServer).RangeQuery(m, &tStorageRangeQueryServer{stream})
+}
+
+type TStorage_RangeQueryServer interface {
+	Send(*Series) error
+	grpc.ServerStream
+}
+
+type tStorageRangeQueryServer struct {
+	grpc.ServerStream
+}
+
+func (x *tStorageRangeQueryServer) Send(m *Series) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _TStorage_Delete_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Filter)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TStorageServer).Delete(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/proto.TStorage/Delete",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TStorageServer).Delete(ctx, req.(*Filter))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // TStorage_ServiceDesc is the grpc.ServiceDesc for TStorage service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -219,6 +557,26 @@ var TStorage_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "InsertRow",
 			Handler:    _TStorage_InsertRow_Handler,
 		},
+		{
+			MethodName: "ListMetrics",
+			Handler:    _TStorage_ListMetrics_Handler,
+		},
+		{
+			MethodName: "ListLabelNames",
+			Handler:    _TStorage_ListLabelNames_Handler,
+		},
+		{
+			MethodName: "ListLabelValues",
+			Handler:    _TStorage_ListLabelValues_Handler,
+		},
+		{
+			MethodName: "Aggregate",
+			Handler:    _TStorage_Aggregate_Handler,
+		},
+		{
+			MethodName: "Delete",
+			Handler:    _TStorage_Delete_Handler,
+		},
 	},
 	Streams: []grpc.StreamDesc{
 		{
@@ -226,11 +584,27 @@ var TStorage_ServiceDesc = grpc.ServiceDesc{
 			Handler:       _TStorage_InsertRows_Handler,
 			ClientStreams: true,
 		},
+		{
+			StreamName:    "StreamInsert",
+			Handler:       _TStorage_StreamInsert_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
 		{
 			StreamName:    "Select",
 			Handler:       _TStorage_Select_Handler,
 			ServerStreams: true,
 		},
+		{
+			StreamName:    "SelectSeries",
+			Handler:       _TStorage_SelectSeries_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "RangeQuery",
+			Handler:       _TStorage_RangeQuery_Handler,
+			ServerStreams: true,
+		},
 	},
 	Metadata: "proto/tstorage.proto",
 }